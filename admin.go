@@ -0,0 +1,89 @@
+package coil
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// adminResponse is the document served by AdminHTTPHandler
+type adminResponse struct {
+	Config        map[string]interface{} `json:"config"`
+	ConfigFile    string                 `json:"config_file,omitempty"`
+	SchemaVersion string                 `json:"schema_version,omitempty"`
+	LoadedAt      time.Time              `json:"loaded_at"`
+}
+
+// AdminHTTPHandler serves the live config values as JSON at GET /config,
+// with fields tagged secret:"true" redacted, along with the config file
+// path, schema version and time of the last load. POST /config/reload
+// calls Reload when WithAdminReload is given, and WithAdminAuth requires a
+// bearer token on both
+func (c *Config) AdminHTTPHandler() http.Handler {
+	c.mu.RLock()
+	o := c.opts
+	c.mu.RUnlock()
+	if o == nil {
+		o = newOptions(nil)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /config", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, c.adminResponse())
+	})
+	if o.adminReload {
+		mux.HandleFunc(
+			"POST /config/reload",
+			func(w http.ResponseWriter, r *http.Request) {
+				if err := c.Reload(); err != nil {
+					writeAdminJSON(
+						w,
+						http.StatusInternalServerError,
+						map[string]string{"error": err.Error()},
+					)
+					return
+				}
+				writeAdminJSON(w, http.StatusOK, c.adminResponse())
+			},
+		)
+	}
+	if o.adminToken == "" {
+		return mux
+	}
+	want := []byte("Bearer " + o.adminToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// adminResponse captures the redacted values and load metadata
+func (c *Config) adminResponse() adminResponse {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	resp := adminResponse{
+		SchemaVersion: c.SchemaVersion,
+		LoadedAt:      c.loadedAt,
+	}
+	if c.viper != nil {
+		resp.ConfigFile = c.viper.ConfigFileUsed()
+	}
+	if c.root != nil {
+		resp.Config = toMap(c.root, &exportOptions{})
+	}
+	return resp
+}
+
+// writeAdminJSON encodes v as the response body
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,93 @@
+package coil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// AdminCfg for admin handler testing
+type AdminCfg struct {
+	Config
+	DatabaseConfig
+}
+
+func TestAdminHTTPHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("dbhost: file.example.com\ndbpass: hunter2\n"), 0o644)
+	c, err := NewConfig(
+		&AdminCfg{Config: Config{SchemaVersion: "2"}},
+		WithMerge(false),
+		WithArgs([]string{"--config=" + path}),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	h := c.(*AdminCfg).AdminHTTPHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /config status = %d", rec.Code)
+	}
+	var resp adminResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	if resp.Config["dbhost"] != "file.example.com" {
+		t.Errorf("dbhost = %v, want the file value", resp.Config["dbhost"])
+	}
+	if resp.Config["dbpass"] != Redacted {
+		t.Errorf("dbpass = %v, want it redacted", resp.Config["dbpass"])
+	}
+	if resp.ConfigFile != path || resp.SchemaVersion != "2" {
+		t.Errorf("metadata = %q, %q", resp.ConfigFile, resp.SchemaVersion)
+	}
+	if resp.LoadedAt.IsZero() {
+		t.Error("loaded_at is not set")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/config/reload", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("POST /config/reload without WithAdminReload = %d", rec.Code)
+	}
+}
+
+func TestAdminHTTPHandlerAuthReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("dbhost: old.example.com\n"), 0o644)
+	c, err := NewConfig(
+		&AdminCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--config=" + path}),
+		WithAdminAuth("s3cret"),
+		WithAdminReload(true),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg := c.(*AdminCfg)
+	h := cfg.AdminHTTPHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/config", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /config without a token = %d, want 401", rec.Code)
+	}
+
+	os.WriteFile(path, []byte("dbhost: new.example.com\n"), 0o644)
+	req := httptest.NewRequest("POST", "/config/reload", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /config/reload status = %d: %s", rec.Code, rec.Body)
+	}
+	if cfg.DBHost != "new.example.com" {
+		t.Errorf("DBHost = %q after reload, want new.example.com", cfg.DBHost)
+	}
+}
@@ -0,0 +1,461 @@
+// Package analysis provides ConfigLinter, a go/analysis pass that checks
+// the struct tags of coil configs at compile time rather than when the
+// config is loaded
+package analysis
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+
+	"github.com/cvlstack/coil"
+)
+
+// coilPath is the import path of the coil package
+const coilPath = "github.com/cvlstack/coil"
+
+// ConfigLinter reports struct tags on types embedding coil.Config that
+// would misbehave at runtime: malformed coil tags, unknown types, type
+// tags without a name or not matching the field's Go type, duplicate
+// names, defaults that do not parse as their type and prefix tags on
+// fields that are not structs
+var ConfigLinter = &analysis.Analyzer{
+	Name:     "coillint",
+	Doc:      "check the struct tags of types embedding coil.Config",
+	Run:      run,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+}
+
+// knownTypes lists the values accepted by the type tag
+var knownTypes = map[string]bool{
+	"string":    true,
+	"[]string":  true,
+	"[]int64":   true,
+	"[]float64": true,
+	"int":       true,
+	"uint":      true,
+	"uint8":     true,
+	"octal":     true,
+	"bool":      true,
+	"float32":   true,
+	"float64":   true,
+	"duration":  true,
+	"time":      true,
+	"map":       true,
+	"ip":        true,
+	"cidr":      true,
+	"bytes":     true,
+	"url":       true,
+	"text":      true,
+	"json":      true,
+	"custom":    true,
+}
+
+// run checks every struct type declared in the package that embeds
+// coil.Config
+func run(pass *analysis.Pass) (interface{}, error) {
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	ins.Preorder([]ast.Node{(*ast.TypeSpec)(nil)}, func(n ast.Node) {
+		obj := pass.TypesInfo.Defs[n.(*ast.TypeSpec).Name]
+		if obj == nil {
+			return
+		}
+		st, ok := obj.Type().Underlying().(*types.Struct)
+		if !ok || !embedsConfig(st) {
+			return
+		}
+		l := &linter{pass: pass, names: make(map[string]bool)}
+		l.checkStruct(st, "", token.NoPos)
+	})
+	return nil, nil
+}
+
+// linter checks one config hierarchy, tracking the names it declares
+type linter struct {
+	pass  *analysis.Pass
+	names map[string]bool
+}
+
+// checkStruct checks the fields of a struct whose names are prefixed with
+// prefix. Fields of structs declared in other packages are reported at pos,
+// the field of this package that leads to them
+func (l *linter) checkStruct(st *types.Struct, prefix string, pos token.Pos) {
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		if isCoilType(field.Type(), "Config") {
+			continue
+		}
+		// Private fields are skipped, unlike embedded structs
+		_, isStruct := field.Type().Underlying().(*types.Struct)
+		if !field.Exported() && !(field.Anonymous() && isStruct) {
+			continue
+		}
+		fieldPos := pos
+		if fieldPos == token.NoPos {
+			fieldPos = field.Pos()
+		}
+		tags, err := coil.ParseTags(reflect.StructTag(st.Tag(i)))
+		if err != nil {
+			l.pass.Reportf(
+				fieldPos,
+				"field %s has an invalid coil tag: %v",
+				field.Name(),
+				err,
+			)
+			continue
+		}
+		if tags["skip"] == "true" {
+			continue
+		}
+		if nested, ok := nestedStruct(field.Type()); ok &&
+			tags["type"] != "json" {
+			next := pos
+			if next == token.NoPos && !l.local(field.Type()) {
+				next = fieldPos
+			}
+			l.checkStruct(nested, joinPrefix(prefix, tags["prefix"]), next)
+			continue
+		}
+		if tags["prefix"] != "" {
+			l.pass.Reportf(
+				fieldPos,
+				"field %s has a prefix tag but is not a struct",
+				field.Name(),
+			)
+		}
+		l.checkField(field, tags, prefix, fieldPos)
+	}
+}
+
+// checkField checks the tags of a field holding a single config value
+func (l *linter) checkField(
+	field *types.Var,
+	tags map[string]string,
+	prefix string,
+	pos token.Pos,
+) {
+	name, typ := tags["name"], tags["type"]
+	if typ == "" && isTextType(field.Type()) {
+		typ = "text"
+	}
+	if name == "" {
+		if typ != "" {
+			l.pass.Reportf(
+				pos,
+				"field %s has a type tag but no name tag",
+				field.Name(),
+			)
+		}
+		return
+	}
+	name = joinPrefix(prefix, name)
+	if l.names[name] {
+		l.pass.Reportf(pos, "duplicate flag name %q", name)
+	}
+	l.names[name] = true
+	if !knownTypes[typ] {
+		l.pass.Reportf(pos, "field %s has unknown type %q", field.Name(), typ)
+		return
+	}
+	if !typeMatches(typ, field.Type()) {
+		l.pass.Reportf(
+			pos,
+			"field %s has type tag %q but is a %s",
+			field.Name(),
+			typ,
+			types.TypeString(field.Type(), types.RelativeTo(l.pass.Pkg)),
+		)
+		return
+	}
+	_, isPtr := field.Type().(*types.Pointer)
+	if err := checkDefault(typ, tags, isPtr); err != nil {
+		l.pass.Reportf(
+			pos,
+			"field %s has default %q, which is not a valid %s: %v",
+			field.Name(),
+			tags["default"],
+			typ,
+			err,
+		)
+	}
+}
+
+// typeMatches reports whether a field of type t can hold values of the
+// type tag typ, checking pointer fields by the type they point to
+func typeMatches(typ string, t types.Type) bool {
+	switch typ {
+	case "ip":
+		return isNamed(t, "net", "IP")
+	case "cidr":
+		ptr, ok := t.(*types.Pointer)
+		return ok && isNamed(ptr.Elem(), "net", "IPNet")
+	case "bytes":
+		s, ok := t.(*types.Slice)
+		return ok && types.Identical(s.Elem(), types.Typ[types.Byte])
+	case "text":
+		return isTextType(t)
+	case "custom", "json":
+		return true
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	switch typ {
+	case "duration":
+		return isNamed(t, "time", "Duration")
+	case "time":
+		return isNamed(t, "time", "Time")
+	case "url":
+		return isNamed(t, "net/url", "URL")
+	case "map":
+		m, ok := t.Underlying().(*types.Map)
+		return ok && hasInfo(m.Key(), types.IsString) &&
+			hasInfo(m.Elem(), types.IsString)
+	case "[]string", "[]int64", "[]float64":
+		s, ok := t.Underlying().(*types.Slice)
+		if !ok {
+			return false
+		}
+		if typ == "[]string" {
+			return hasInfo(s.Elem(), types.IsString)
+		}
+		return !isNamed(s.Elem(), "time", "Duration") &&
+			(hasInfo(s.Elem(), types.IsInteger) &&
+				!hasInfo(s.Elem(), types.IsUnsigned) ||
+				hasInfo(s.Elem(), types.IsFloat))
+	case "string":
+		return hasInfo(t, types.IsString)
+	case "int":
+		return hasInfo(t, types.IsInteger) && !hasInfo(t, types.IsUnsigned)
+	case "uint", "octal":
+		return hasInfo(t, types.IsUnsigned) && !isBasic(t, types.Uintptr)
+	case "uint8":
+		return isBasic(t, types.Uint8)
+	case "bool":
+		return hasInfo(t, types.IsBoolean)
+	case "float32", "float64":
+		return hasInfo(t, types.IsFloat)
+	}
+	return true
+}
+
+// hasInfo reports whether the underlying type of t is a basic type with
+// the given property
+func hasInfo(t types.Type, info types.BasicInfo) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&info != 0
+}
+
+// isBasic reports whether the underlying type of t is the given basic kind
+func isBasic(t types.Type, kind types.BasicKind) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == kind
+}
+
+// isNamed reports whether t is the named type of the package at path
+func isNamed(t types.Type, path, name string) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil &&
+		named.Obj().Pkg().Path() == path && named.Obj().Name() == name
+}
+
+// local reports whether a type is declared in the package being analysed
+func (l *linter) local(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return !ok || named.Obj().Pkg() == l.pass.Pkg
+}
+
+// checkDefault parses the default tag as the declared type. Pointer fields
+// may leave it empty
+func checkDefault(typ string, tags map[string]string, isPtr bool) error {
+	val := tags["default"]
+	if val == "" && (isPtr || typ == "bool" || typ == "time" ||
+		typ == "ip" || typ == "cidr" || typ == "url" || typ == "uint8" ||
+		typ == "octal") {
+		return nil
+	}
+	sep := tags["sep"]
+	if sep == "" {
+		sep = ","
+	}
+	var err error
+	switch typ {
+	case "int":
+		_, err = strconv.ParseInt(val, 10, 64)
+	case "uint":
+		_, err = strconv.ParseUint(val, 0, 64)
+	case "uint8":
+		_, err = strconv.ParseUint(val, 0, 8)
+	case "octal":
+		val = strings.TrimPrefix(strings.TrimPrefix(val, "0o"), "0O")
+		_, err = strconv.ParseUint(val, 8, 64)
+	case "bool":
+		_, err = strconv.ParseBool(val)
+	case "float32":
+		_, err = strconv.ParseFloat(val, 32)
+	case "float64":
+		_, err = strconv.ParseFloat(val, 64)
+	case "duration":
+		_, err = coil.ParseDurationLenient(val)
+	case "time":
+		layout := tags["layout"]
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		_, err = time.Parse(layout, val)
+	case "[]int64", "[]float64":
+		for _, item := range strings.Split(val, sep) {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			if typ == "[]int64" {
+				_, err = strconv.ParseInt(item, 10, 64)
+			} else {
+				_, err = strconv.ParseFloat(item, 64)
+			}
+			if err != nil {
+				break
+			}
+		}
+	case "ip":
+		if net.ParseIP(val) == nil {
+			err = errors.New("not an IP address")
+		}
+	case "cidr":
+		_, _, err = net.ParseCIDR(val)
+	case "bytes":
+		err = checkBytesDefault(val, tags["encoding"] == "url")
+	case "url":
+		err = checkURLDefault(val, tags["secure"] == "true")
+	case "map":
+		err = checkMapDefault(val)
+	case "json":
+		if val != "" && !json.Valid([]byte(val)) {
+			err = errors.New("not valid JSON")
+		}
+	}
+	return err
+}
+
+// checkURLDefault accepts an absolute URL, which must use https if secure
+// is set
+func checkURLDefault(val string, secure bool) error {
+	u, err := url.Parse(val)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" && u.Opaque != "" {
+		return errors.New("no scheme")
+	}
+	if secure && u.Scheme != "https" {
+		return errors.New("not https")
+	}
+	return nil
+}
+
+// checkBytesDefault accepts base64, in the URL safe alphabet if urlSafe is
+// set
+func checkBytesDefault(val string, urlSafe bool) error {
+	enc := base64.StdEncoding
+	if urlSafe {
+		enc = base64.URLEncoding
+	}
+	_, err := enc.DecodeString(val)
+	return err
+}
+
+// checkMapDefault accepts a JSON object or a comma separated list of
+// key=value pairs
+func checkMapDefault(val string) error {
+	if val == "" {
+		return nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(val), "{") {
+		return json.Unmarshal([]byte(val), &map[string]string{})
+	}
+	for _, pair := range strings.Split(val, ",") {
+		if !strings.Contains(pair, "=") {
+			return fmt.Errorf("%q is not a key=value pair", pair)
+		}
+	}
+	return nil
+}
+
+// embedsConfig reports whether a struct embeds coil.Config
+func embedsConfig(st *types.Struct) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i).Anonymous() && isCoilType(st.Field(i).Type(), "Config") {
+			return true
+		}
+	}
+	return false
+}
+
+// isCoilType reports whether t is the named type of the coil package
+func isCoilType(t types.Type, name string) bool {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return named.Obj().Pkg().Path() == coilPath && named.Obj().Name() == name
+}
+
+// nestedStruct returns the struct of a field whose own fields are config
+// fields, which excludes time.Time, url.URL and types with an
+// UnmarshalText method
+func nestedStruct(t types.Type) (*types.Struct, bool) {
+	if isTextType(t) {
+		return nil, false
+	}
+	if named, ok := t.(*types.Named); ok {
+		obj := named.Obj()
+		if obj.Pkg() != nil && (obj.Pkg().Path() == "time" &&
+			obj.Name() == "Time" || obj.Pkg().Path() == "net/url" &&
+			obj.Name() == "URL") {
+			return nil, false
+		}
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	return st, ok
+}
+
+// isTextType reports whether a field type, or the type it points to, has
+// an UnmarshalText method and so needs no type tag
+func isTextType(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	obj, _, _ := types.LookupFieldOrMethod(
+		types.NewPointer(t),
+		true,
+		nil,
+		"UnmarshalText",
+	)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// joinPrefix prepends a non-empty prefix to name
+func joinPrefix(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	if name == "" {
+		return prefix
+	}
+	return prefix + "_" + name
+}
@@ -0,0 +1,11 @@
+package analysis
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestConfigLinter(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), ConfigLinter, "a")
+}
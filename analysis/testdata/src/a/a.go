@@ -0,0 +1,82 @@
+package a
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/cvlstack/coil"
+)
+
+type Valid struct {
+	coil.Config
+	Host    string              `type:"string"    name:"host"    default:"localhost"`
+	Port    int                 `type:"int"       name:"port"    default:"8080"`
+	Ports   []int64             `type:"[]int64"   name:"ports"   default:"1;2"       sep:";"`
+	Unset   *int                `type:"int"       name:"unset"`
+	Started time.Time           `type:"time"      name:"started" default:"2024-01-02" layout:"2006-01-02"`
+	Labels  map[string]string   `type:"map"       name:"labels"  default:"a=1,b=2"`
+	Primary coil.DatabaseConfig `prefix:"primary"`
+	Replica coil.DatabaseConfig `prefix:"replica"`
+	Bind    net.IP              `type:"ip"        name:"bind"    default:"::1"`
+	Unified string              `coil:"name=unified,type=string,default='a,b'"`
+	API     url.URL             `type:"url"       name:"api"     default:"https://api.example.com" secure:"true"`
+	Level   Level               `name:"level"     default:"info"`
+	Key     []byte              `type:"bytes"     name:"key"     default:"c2VjcmV0" minlen:"6"`
+	Rules   Rules               `type:"json"      name:"rules"   default:"{\"max\":3}"`
+	Mode    os.FileMode         `type:"octal"     name:"mode"    default:"0644"`
+	Red     uint8               `type:"uint8"     name:"red"     default:"0xff"`
+	settings
+	// hidden is private, so its tags are not checked
+	hidden string `type:"strnig" name:"hidden"`
+}
+
+// settings is embedded, so its fields are config fields despite the type
+// being unexported
+type settings struct {
+	Workers int `type:"int" name:"workers" default:"4"`
+}
+
+type EmbeddedInvalid struct {
+	coil.Config
+	settings
+	Workers2 int `type:"int" name:"workers" default:"2"` // want `duplicate flag name "workers"`
+}
+
+// Level decodes itself, so it needs no type tag
+type Level int
+
+// Rules is decoded from JSON rather than holding config fields of its own
+type Rules struct {
+	Max  int    `json:"max"`
+	Host string `type:"string" name:"host"`
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	return nil
+}
+
+type Invalid struct {
+	coil.Config
+	Kind     string        `type:"strnig"   name:"kind"` // want `unknown type "strnig"`
+	Nameless string        `type:"string"`               // want `no name tag`
+	Port     int           `type:"int"      name:"port"    default:"80"`
+	Other    int           `type:"int"      name:"port"    default:"81"`          // want `duplicate flag name "port"`
+	Timeout  time.Duration `type:"duration" name:"timeout" default:"5 secs ago"`  // want `not a valid duration`
+	Count    int           `type:"int"      name:"count"   default:""`            // want `not a valid int`
+	Host     string        `type:"string"   name:"host"    prefix:"db"`           // want `not a struct`
+	Broken   string        `coil:"name=broken,colour=red"`                        // want `invalid coil tag`
+	Subnet   *net.IPNet    `type:"cidr"     name:"subnet"  default:"10.0.0.1"`    // want `not a valid cidr`
+	API      url.URL       `type:"url"      name:"api"     default:"example.com"` // want `not a valid url`
+	Debug    bool          `type:"string"   name:"debug"   default:""`            // want `type tag "string" but is a bool`
+	Retries  *uint         `type:"int"      name:"retries"`                       // want `type tag "int" but is a \*uint`
+	Key      []byte        `type:"bytes"    name:"key"     default:"c2VjcmV0!"`   // want `not a valid bytes`
+	Rules    Rules         `type:"json"     name:"rules"   default:"{max:3}"`     // want `not a valid json`
+	Mode     uint32        `type:"octal"    name:"mode"    default:"0o648"`       // want `not a valid octal`
+	Red      uint16        `type:"uint8"    name:"red"`                           // want `type tag "uint8" but is a uint16`
+	DB       coil.DatabaseConfig
+	DB2      coil.DatabaseConfig // want `duplicate flag name "dbhost"`
+	Skipped  string              `type:"strnig" name:"kind" skip:"true"`
+	Replica  coil.DatabaseConfig `coil:"-"`
+}
@@ -0,0 +1,9 @@
+package coil
+
+type Config struct {
+	SchemaVersion string
+}
+
+type DatabaseConfig struct {
+	DBHost string `type:"string" name:"dbhost" default:"localhost"`
+}
@@ -0,0 +1,246 @@
+// Package aws implements a coil remote source backed by AWS Secrets
+// Manager
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+
+	"github.com/cvlstack/coil"
+	"github.com/cvlstack/coil/remote"
+)
+
+const (
+	defaultCacheTTL     = 5 * time.Minute
+	defaultPollInterval = 30 * time.Second
+)
+
+// Option customises a Secrets Manager source
+type Option func(*secretSource)
+
+// WithCacheTTL sets how long a fetched secret is used before Get reads it
+// again. Defaults to five minutes
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(s *secretSource) {
+		s.cacheTTL = ttl
+	}
+}
+
+// WithPollInterval sets how often Watch checks the secret for rotation, as
+// Secrets Manager has no change notifications. Defaults to 30 seconds
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *secretSource) {
+		s.pollInterval = interval
+	}
+}
+
+// WithContext stops Watch polling once ctx is done, closing the channels
+// it returned. Without it the poller runs as long as the program
+func WithContext(ctx context.Context) Option {
+	return func(s *secretSource) {
+		s.ctx = ctx
+	}
+}
+
+// secretSource reads the keys of a JSON secret
+type secretSource struct {
+	client       *secretsmanager.Client
+	secretID     string
+	cacheTTL     time.Duration
+	pollInterval time.Duration
+	ctx          context.Context
+
+	mu      sync.Mutex
+	values  map[string]string
+	fetched time.Time
+
+	watchMu  sync.Mutex
+	watchers []*watcher
+}
+
+// watcher is a key passed to Watch and the last value sent for it
+type watcher struct {
+	key  string
+	last string
+	ch   chan string
+}
+
+// send hands val to the watcher without waiting for it to be received,
+// replacing a value still waiting, so one idle receiver cannot hold up
+// the others. Only the poller sends
+func (w *watcher) send(val string) {
+	select {
+	case <-w.ch:
+	default:
+	}
+	w.ch <- val
+}
+
+// SecretsManagerSource returns a source reading the secret secretID, whose
+// value is a JSON object such as {"dbpass": "hunter2"}. Keys are matched
+// to flag names case-insensitively. The secret is fetched once per cache
+// TTL rather than on every Get
+func SecretsManagerSource(
+	secretID string,
+	cfg aws.Config,
+	opts ...Option,
+) remote.RemoteSource {
+	s := &secretSource{
+		client:       secretsmanager.NewFromConfig(cfg),
+		secretID:     secretID,
+		cacheTTL:     defaultCacheTTL,
+		pollInterval: defaultPollInterval,
+		ctx:          context.Background(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithSecretsManagerSource reads the keys of the JSON secret secretID,
+// like coil.WithSecretsSource(SecretsManagerSource(secretID, cfg)). The
+// secret's values sit at the same priority as env vars: they override
+// dotenv and config files, but a set env var or a flag wins
+func WithSecretsManagerSource(
+	secretID string,
+	cfg aws.Config,
+	opts ...Option,
+) coil.Option {
+	return coil.WithSecretsSource(SecretsManagerSource(secretID, cfg, opts...))
+}
+
+// fetch reads the current version of the secret, keyed by lower case
+// name, giving up once ctx is done
+func (s *secretSource) fetch(ctx context.Context) (map[string]string, error) {
+	out, err := s.client.GetSecretValue(
+		ctx,
+		&secretsmanager.GetSecretValueInput{SecretId: aws.String(s.secretID)},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if out.SecretString == nil {
+		return nil, fmt.Errorf("secret %s has no string value", s.secretID)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(*out.SecretString), &data); err != nil {
+		return nil, fmt.Errorf(
+			"secret %s is not a JSON object: %w",
+			s.secretID,
+			err,
+		)
+	}
+	values := make(map[string]string, len(data))
+	for key, val := range data {
+		values[strings.ToLower(key)] = fmt.Sprint(val)
+	}
+	return values, nil
+}
+
+// cached returns the secret's values, fetching them again once the cache
+// TTL has passed
+func (s *secretSource) cached(ctx context.Context) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values != nil && time.Since(s.fetched) < s.cacheTTL {
+		return s.values, nil
+	}
+	values, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.values, s.fetched = values, time.Now()
+	return values, nil
+}
+
+// Get returns the value of key in the secret
+func (s *secretSource) Get(key string) (string, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext returns the value of key in the secret, giving up once ctx
+// is done
+func (s *secretSource) GetContext(
+	ctx context.Context,
+	key string,
+) (string, error) {
+	values, err := s.cached(ctx)
+	if err != nil {
+		return "", err
+	}
+	val, ok := values[strings.ToLower(key)]
+	if !ok {
+		return "", remote.ErrNotFound
+	}
+	return val, nil
+}
+
+// Watch returns a channel receiving the value of key each time the secret
+// is rotated to a new value. A single poller per source checks the secret
+// every poll interval for all the watched keys, retrying failed polls. A
+// value not yet received is replaced by the next one
+func (s *secretSource) Watch(key string) (<-chan string, error) {
+	last, err := s.Get(key)
+	if err != nil && !errors.Is(err, remote.ErrNotFound) {
+		return nil, err
+	}
+	ch := make(chan string, 1)
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	s.watchers = append(s.watchers, &watcher{
+		key:  strings.ToLower(key),
+		last: last,
+		ch:   ch,
+	})
+	if len(s.watchers) == 1 {
+		go s.poll()
+	}
+	return ch, nil
+}
+
+// poll fetches the secret every poll interval and sends the changed values
+// to the watchers, until the source's context is done
+func (s *secretSource) poll() {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.watchMu.Lock()
+			for _, w := range s.watchers {
+				close(w.ch)
+			}
+			s.watchers = nil
+			s.watchMu.Unlock()
+			return
+		case <-ticker.C:
+		}
+		values, err := s.fetch(s.ctx)
+		if err != nil {
+			continue
+		}
+		s.mu.Lock()
+		s.values, s.fetched = values, time.Now()
+		s.mu.Unlock()
+		s.watchMu.Lock()
+		watchers := append([]*watcher(nil), s.watchers...)
+		s.watchMu.Unlock()
+		for _, w := range watchers {
+			val, ok := values[w.key]
+			if !ok || val == w.last {
+				continue
+			}
+			w.last = val
+			w.send(val)
+		}
+	}
+}
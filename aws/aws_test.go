@@ -0,0 +1,202 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/cvlstack/coil"
+	"github.com/cvlstack/coil/remote"
+)
+
+// secretServer serves the secret from the returned value, counting the
+// GetSecretValue calls
+func secretServer(t *testing.T) (*httptest.Server, *atomic.Value, *int32) {
+	t.Helper()
+	var secret atomic.Value
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			var in struct{ SecretId string }
+			json.NewDecoder(r.Body).Decode(&in)
+			w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+			if in.SecretId != "app/prod" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"ResourceNotFoundException"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{
+				"Name":         in.SecretId,
+				"SecretString": secret.Load().(string),
+			})
+		},
+	))
+	t.Cleanup(srv.Close)
+	return srv, &secret, &calls
+}
+
+func testConfig(url string) aws.Config {
+	return aws.Config{
+		Region:       "us-east-1",
+		Credentials:  aws.AnonymousCredentials{},
+		BaseEndpoint: aws.String(url),
+	}
+}
+
+func TestSecretsManagerSourceGet(t *testing.T) {
+	srv, secret, calls := secretServer(t)
+	secret.Store(`{"DBPass": "hunter2", "dbport": 6543}`)
+
+	src := SecretsManagerSource("app/prod", testConfig(srv.URL))
+	for key, want := range map[string]string{
+		"dbpass": "hunter2",
+		"dbport": "6543",
+	} {
+		val, err := src.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%q) error = %v", key, err)
+		}
+		if val != want {
+			t.Errorf("Get(%q) = %q, want %q", key, val, want)
+		}
+	}
+	if _, err := src.Get("missing"); !errors.Is(err, remote.ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("secret fetched %d times, want 1 within the cache TTL", n)
+	}
+
+	missing := SecretsManagerSource("app/none", testConfig(srv.URL))
+	if _, err := missing.Get("dbpass"); err == nil {
+		t.Error("Get() on a missing secret should fail")
+	}
+}
+
+func TestSecretsManagerSourceGetContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		},
+	))
+	defer srv.Close()
+	defer close(release)
+
+	src := SecretsManagerSource(
+		"app/prod",
+		testConfig(srv.URL),
+	)
+	cs, ok := src.(remote.ContextSource)
+	if !ok {
+		t.Fatal("SecretsManagerSource() does not implement remote.ContextSource")
+	}
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		20*time.Millisecond,
+	)
+	defer cancel()
+	_, err := cs.GetContext(ctx, "dbpass")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf(
+			"GetContext() error = %v, want %v",
+			err,
+			context.DeadlineExceeded,
+		)
+	}
+}
+
+func TestSecretsManagerSourceWatch(t *testing.T) {
+	srv, secret, _ := secretServer(t)
+	secret.Store(`{"dbpass": "hunter2", "dbuser": "app"}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := SecretsManagerSource(
+		"app/prod",
+		testConfig(srv.URL),
+		WithCacheTTL(0),
+		WithPollInterval(10*time.Millisecond),
+		WithContext(ctx),
+	)
+	pass, err := src.Watch("dbpass")
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	user, err := src.Watch("DBUser")
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	secret.Store(`{"dbpass": "rotated", "dbuser": "app2"}`)
+	for ch, want := range map[<-chan string]string{
+		pass: "rotated",
+		user: "app2",
+	} {
+		select {
+		case val := <-ch:
+			if val != want {
+				t.Errorf("Watch() = %q, want %q", val, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Watch() did not report the rotated secret")
+		}
+	}
+
+	cancel()
+	for _, ch := range []<-chan string{pass, user} {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				t.Error("Watch() sent a value after ctx was cancelled")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Watch() channel not closed after ctx was cancelled")
+		}
+	}
+}
+
+// DBCfg for loading through Secrets Manager
+type DBCfg struct {
+	coil.Config
+	coil.DatabaseConfig
+}
+
+func TestWithSecretsManagerSource(t *testing.T) {
+	t.Setenv("DBHOST", "env.example.com")
+	srv, secret, _ := secretServer(t)
+	secret.Store(`{"DBPass": "hunter2", "dbhost": "secret.example.com"}`)
+	c, err := coil.NewConfig(
+		&DBCfg{},
+		coil.WithMerge(false),
+		coil.WithArgs([]string{}),
+		WithSecretsManagerSource("app/prod", testConfig(srv.URL)),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg := c.(*DBCfg)
+	if cfg.DBPass != "hunter2" {
+		t.Errorf("DBPass = %q, want the secret value", cfg.DBPass)
+	}
+	if cfg.DBHost != "env.example.com" {
+		t.Errorf("DBHost = %q, want the env value", cfg.DBHost)
+	}
+
+	secret.Store("not json")
+	_, err = coil.NewConfig(
+		&DBCfg{},
+		coil.WithMerge(false),
+		coil.WithArgs([]string{}),
+		WithSecretsManagerSource("app/prod", testConfig(srv.URL)),
+	)
+	if err == nil {
+		t.Error("NewConfig() should fail when the secret is not JSON")
+	}
+}
@@ -0,0 +1,155 @@
+package coil
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ConfigBuilder declares configuration keys in code rather than through
+// struct tags. The built config resolves values with the same CLI, env,
+// config file and default precedence as NewConfig
+type ConfigBuilder struct {
+	fields map[string]builderField
+	opts   []Option
+	err    error
+	config *DynamicConfig
+}
+
+// builderField pairs a key definition with its typed default value
+type builderField struct {
+	def   fieldDef
+	value interface{}
+}
+
+// DynamicConfig is the config produced by a ConfigBuilder
+type DynamicConfig struct {
+	Config
+	values map[string]interface{}
+}
+
+// Get returns the resolved value of a key, or nil if it was not declared
+func (c *DynamicConfig) Get(name string) interface{} {
+	return c.values[name]
+}
+
+// NewConfigBuilder creates an empty builder. The options are applied when
+// the config is built
+func NewConfigBuilder(opts ...Option) *ConfigBuilder {
+	return &ConfigBuilder{
+		fields: make(map[string]builderField),
+		opts:   opts,
+	}
+}
+
+// AddString declares a string key
+func (b *ConfigBuilder) AddString(name, def, desc string) *ConfigBuilder {
+	return b.add(name, "string", def, def, desc)
+}
+
+// AddInt declares an integer key
+func (b *ConfigBuilder) AddInt(
+	name string,
+	def int64,
+	desc string,
+) *ConfigBuilder {
+	return b.add(name, "int", strconv.FormatInt(def, 10), def, desc)
+}
+
+// AddBool declares a boolean key
+func (b *ConfigBuilder) AddBool(
+	name string,
+	def bool,
+	desc string,
+) *ConfigBuilder {
+	return b.add(name, "bool", strconv.FormatBool(def), def, desc)
+}
+
+// AddDuration declares a duration key
+func (b *ConfigBuilder) AddDuration(
+	name string,
+	def time.Duration,
+	desc string,
+) *ConfigBuilder {
+	return b.add(name, "duration", def.String(), def, desc)
+}
+
+// add records a key definition, keeping the first error encountered so the
+// fluent chain can report it from Build
+func (b *ConfigBuilder) add(
+	name, typ, def string,
+	value interface{},
+	desc string,
+) *ConfigBuilder {
+	if b.err != nil {
+		return b
+	}
+	if name == "" {
+		b.err = fmt.Errorf("config key of type %s has no name", typ)
+		return b
+	}
+	if _, ok := b.fields[name]; ok {
+		b.err = fmt.Errorf("duplicate config key %q", name)
+		return b
+	}
+	b.fields[name] = builderField{
+		def: fieldDef{
+			Name:    name,
+			Type:    typ,
+			Default: def,
+			Desc:    desc,
+		},
+		value: value,
+	}
+	return b
+}
+
+// Build registers the declared keys as flags, parses every source and
+// returns the resulting *DynamicConfig
+func (b *ConfigBuilder) Build() (Configer, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	c := &DynamicConfig{values: make(map[string]interface{})}
+	fs := newFlagSet()
+	for _, f := range b.fields {
+		if err := defineFlag(fs, f.def); err != nil {
+			return nil, err
+		}
+	}
+	o := newOptions(b.opts)
+	if err := parseFlags(c, fs, o); err != nil {
+		return nil, err
+	}
+	parser := c.getParser()
+	if err := loadEnvFile(parser, o.envFile); err != nil {
+		return nil, err
+	}
+	for name, f := range b.fields {
+		if !parser.IsSet(name) {
+			c.values[name] = f.value
+			continue
+		}
+		switch f.def.Type {
+		case "string":
+			c.values[name] = parser.GetString(name)
+		case "int":
+			c.values[name] = parser.GetInt64(name)
+		case "bool":
+			c.values[name] = parser.GetBool(name)
+		case "duration":
+			c.values[name] = parser.GetDuration(name)
+		}
+	}
+	b.config = c
+	return c, nil
+}
+
+// Get returns the resolved value of a key from the last Build, or nil if
+// the config has not been built or the key was not declared
+func (b *ConfigBuilder) Get(name string) interface{} {
+	if b.config == nil {
+		return nil
+	}
+	return b.config.Get(name)
+}
@@ -0,0 +1,57 @@
+package coil
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestConfigBuilder(t *testing.T) {
+	origVal := os.Getenv("BUILDER_INT")
+	os.Setenv("BUILDER_INT", "7")
+	defer restoreEnv("BUILDER_INT", origVal)
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app", "--builder_bool=true"}
+
+	b := NewConfigBuilder(WithMerge(false)).
+		AddString("builder_string", "fallback", "A string key").
+		AddInt("builder_int", 3, "An int key").
+		AddBool("builder_bool", false, "A bool key").
+		AddDuration("builder_duration", 5*time.Second, "A duration key")
+	if b.Get("builder_string") != nil {
+		t.Error("Get() before Build() should return nil")
+	}
+	cfg, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want interface{}
+	}{
+		{"builder_string", "fallback"},
+		{"builder_int", int64(7)},
+		{"builder_bool", true},
+		{"builder_duration", 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := b.Get(tt.name); got != tt.want {
+			t.Errorf("Get(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := cfg.(*DynamicConfig).Get("builder_int"); got != int64(7) {
+		t.Errorf("DynamicConfig.Get() = %v, want %v", got, int64(7))
+	}
+}
+
+func TestConfigBuilderDuplicateKey(t *testing.T) {
+	_, err := NewConfigBuilder(WithMerge(false)).
+		AddString("builder_dup", "", "First").
+		AddInt("builder_dup", 0, "Second").
+		Build()
+	if err == nil {
+		t.Error("Build() with duplicate keys should return an error")
+	}
+}
@@ -0,0 +1,27 @@
+// Command coil-migrate rewrites a config file written for an old schema
+// version, applying the migrations registered with coil.RegisterMigration:
+//
+//	coil-migrate --to-version 2 --input old.yaml --output new.yaml
+//	coil-migrate --to-version 2 --input old.yaml --dry-run
+//
+// Migrations are registered by the service that owns the config, so a
+// service builds its own copy of this command importing them, e.g.
+//
+//	func main() {
+//		myapp.RegisterMigrations()
+//		os.Exit(migrate.Run(&myapp.Config{}, os.Args[1:], os.Stdout, os.Stderr))
+//	}
+//
+// Passing the service's config also warns about keys it does not register.
+// Built as is, only files already at the target version pass
+package main
+
+import (
+	"os"
+
+	"github.com/cvlstack/coil/migrate"
+)
+
+func main() {
+	os.Exit(migrate.Run(nil, os.Args[1:], os.Stdout, os.Stderr))
+}
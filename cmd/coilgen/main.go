@@ -0,0 +1,23 @@
+// Command coilgen generates typed accessors, a Keys method and a Validate
+// stub for the coil configs of a package. Run it through go generate:
+//
+//	//go:generate go run github.com/cvlstack/coil/cmd/coilgen
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cvlstack/coil/gen"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "Directory of the package to generate for")
+	output := flag.String("o", gen.DefaultOutput, "Name of the generated file")
+	flag.Parse()
+	if err := gen.Write(*dir, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "coilgen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,16 @@
+// Command coillint checks the struct tags of coil configs. Run it on its
+// own or through go vet:
+//
+//	go build -o coillint github.com/cvlstack/coil/cmd/coillint
+//	go vet -vettool=./coillint ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/cvlstack/coil/analysis"
+)
+
+func main() {
+	singlechecker.Main(analysis.ConfigLinter)
+}
@@ -0,0 +1,61 @@
+// Package cobra loads coil configs for cobra commands. The flags of a
+// config are declared on the command they belong to, and the config is
+// populated once cobra has selected the command and parsed its flags
+// rather than at package init
+package cobra
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cvlstack/coil"
+)
+
+// binding is the config bound to a command by BindToCommand
+type binding struct {
+	config coil.Configer
+	opts   []coil.Option
+}
+
+// bindings maps each bound *cobra.Command to its binding
+var bindings sync.Map
+
+// BindToCommand declares the flags of c on cmd.Flags(), never on the
+// global pflag set, so they are parsed and listed in the help of cmd
+// alone. The config is populated with the options by a RunE wrapped with
+// WrapRunE
+func BindToCommand(
+	cmd *cobra.Command,
+	c coil.Configer,
+	opts ...coil.Option,
+) error {
+	opts = append([]coil.Option{coil.WithMerge(false)}, opts...)
+	if err := coil.DefineFlags(c, cmd.Flags(), opts...); err != nil {
+		return err
+	}
+	bindings.Store(cmd, binding{config: c, opts: opts})
+	return nil
+}
+
+// WrapRunE returns a RunE that populates the config bound to the command
+// from the flags cobra parsed, the environment and every other source, and
+// then calls fn with it. Load and validation errors are returned without
+// calling fn
+func WrapRunE(
+	fn func(cmd *cobra.Command, args []string, c coil.Configer) error,
+) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		val, ok := bindings.Load(cmd)
+		if !ok {
+			return fmt.Errorf("no config bound to command %q", cmd.Name())
+		}
+		b := val.(binding)
+		err := coil.LoadFlags(b.config, cmd.Flags(), b.opts...)
+		if err != nil {
+			return err
+		}
+		return fn(cmd, args, b.config)
+	}
+}
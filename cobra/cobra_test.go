@@ -0,0 +1,113 @@
+package cobra
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/cvlstack/coil"
+)
+
+// ServeCfg for the serve command
+type ServeCfg struct {
+	coil.Config
+	coil.APIServiceConfig
+}
+
+// MigrateCfg for the migrate command
+type MigrateCfg struct {
+	coil.Config
+	DB    coil.DatabaseConfig
+	Steps int `type:"int" name:"steps" default:"1" min:"1"`
+}
+
+// newRoot builds a command tree whose subcommands load their own configs
+func newRoot(t *testing.T, ran *string) *cobra.Command {
+	root := &cobra.Command{Use: "app", SilenceUsage: true, SilenceErrors: true}
+	root.SetOut(io.Discard)
+	serve := &cobra.Command{
+		Use: "serve",
+		RunE: WrapRunE(func(cmd *cobra.Command, args []string, c coil.Configer) error {
+			*ran = "serve:" + c.(*ServeCfg).ListenAddr()
+			return nil
+		}),
+	}
+	migrate := &cobra.Command{
+		Use: "migrate",
+		RunE: WrapRunE(func(cmd *cobra.Command, args []string, c coil.Configer) error {
+			*ran = "migrate:" + c.(*MigrateCfg).DB.DBHost
+			return nil
+		}),
+	}
+	if err := BindToCommand(serve, &ServeCfg{}); err != nil {
+		t.Fatalf("BindToCommand() error = %v", err)
+	}
+	if err := BindToCommand(migrate, &MigrateCfg{}); err != nil {
+		t.Fatalf("BindToCommand() error = %v", err)
+	}
+	root.AddCommand(serve, migrate)
+	return root
+}
+
+func TestBindToCommand(t *testing.T) {
+	var ran string
+	root := newRoot(t, &ran)
+	root.SetArgs([]string{"serve", "--host=0.0.0.0", "--port=9000"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if ran != "serve:0.0.0.0:9000" {
+		t.Errorf("ran %q, want %q", ran, "serve:0.0.0.0:9000")
+	}
+
+	root = newRoot(t, &ran)
+	root.SetArgs([]string{"migrate", "--dbhost=db.internal"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if ran != "migrate:db.internal" {
+		t.Errorf("ran %q, want %q", ran, "migrate:db.internal")
+	}
+
+	// Flags belong to their command alone
+	root = newRoot(t, &ran)
+	root.SetArgs([]string{"migrate", "--port=9000"})
+	if err := root.Execute(); err == nil {
+		t.Error("Execute() accepted a flag of another command")
+	}
+	if pflag.CommandLine.Lookup("dbhost") != nil {
+		t.Error("BindToCommand() declared flags on the global flag set")
+	}
+}
+
+func TestWrapRunEErrors(t *testing.T) {
+	var ran string
+	root := newRoot(t, &ran)
+	root.SetArgs([]string{"migrate", "--steps=0"})
+	err := root.Execute()
+	var errs coil.ValidationErrors
+	if !errors.As(err, &errs) || !strings.Contains(err.Error(), "steps") {
+		t.Errorf("Execute() error = %v, want ValidationErrors", err)
+	}
+	if ran != "" {
+		t.Errorf("ran %q despite the invalid config", ran)
+	}
+
+	unbound := &cobra.Command{
+		Use:          "unbound",
+		SilenceUsage: true,
+		RunE: WrapRunE(func(*cobra.Command, []string, coil.Configer) error {
+			return nil
+		}),
+	}
+	unbound.SetArgs([]string{})
+	unbound.SetErr(io.Discard)
+	err = unbound.Execute()
+	if err == nil || !strings.Contains(err.Error(), "no config bound") {
+		t.Errorf("Execute() of an unbound command error = %v", err)
+	}
+}
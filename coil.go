@@ -1,10 +1,25 @@
 package coil
 
 import (
+	"context"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/pflag"
@@ -13,13 +28,44 @@ import (
 
 // Configer provides an identifier interface for all configuration types
 type Configer interface {
-	generate()
+	generate(root Configer, fs *pflag.FlagSet, configType string) error
+	setParser(root Configer, v *viper.Viper)
 	getParser() *viper.Viper
+	base() *Config
+	Snapshot() Snapshot
+	RestoreSnapshot(s Snapshot)
 }
 
 // Config is a standard definition for config interfaces
 type Config struct {
-	viper *viper.Viper
+	// SchemaVersion is the schema version the config struct is written
+	// for. Config files declaring another schema_version are migrated to
+	// it, see RegisterMigration
+	SchemaVersion string
+
+	viper   *viper.Viper
+	root    Configer
+	opts    *options
+	profile string
+	// prefix is prepended to every key, see WithPrefix
+	prefix string
+	// fieldPrefixes replace the prefix tags of top level fields, see
+	// WithFieldPrefix
+	fieldPrefixes map[string]string
+	// flags is the parsed command line, which outranks remote sources
+	flags *pflag.FlagSet
+	// mu guards the config values while they are written
+	mu sync.RWMutex
+	// reloadMu serialises reloads, which share the parser
+	reloadMu     sync.Mutex
+	beforeReload []func()
+	afterReload  []func()
+	subscribers  []chan ConfigEvent
+	dropped      atomic.Int64
+	// loadedAt is when the values were last populated
+	loadedAt time.Time
+	// stopWatch stops the watcher started by WatchFile
+	stopWatch context.CancelFunc
 }
 
 // getParser returns the current parser instance
@@ -27,282 +73,1918 @@ func (c *Config) getParser() *viper.Viper {
 	return c.viper
 }
 
-// HasConfig checks if a specific config type is embedded in the Config struct
+// RLock holds the config's read lock so several fields can be read
+// without a concurrent Reload changing them in between. Parse and Validate
+// methods run while the values are written and must not call it
+func (c *Config) RLock() {
+	c.mu.RLock()
+}
+
+// RUnlock releases the read lock taken by RLock
+func (c *Config) RUnlock() {
+	c.mu.RUnlock()
+}
+
+// base returns the embedded Config of an outer config struct
+func (c *Config) base() *Config {
+	return c
+}
+
+// String renders the config values as sorted key=value pairs, with fields
+// tagged secret:"true" redacted
+func (c *Config) String() string {
+	if c.root == nil {
+		return ""
+	}
+	values := ToMap(c.root)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", key, values[key])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// HasConfig checks if a specific config type is part of the outer config
+// struct once it has been loaded
+//
+// Deprecated: use the package level HasConfig, which also works on configs
+// that have not been loaded
 func (c *Config) HasConfig(checkType any) bool {
-	// Get the type we're looking for
-	targetType := reflect.TypeOf(checkType)
+	if c.root == nil {
+		return false
+	}
+	return HasConfig(c.root, checkType)
+}
+
+// HasConfig reports whether a config contains a field of the target type,
+// e.g. HasConfig(cfg, DatabaseConfig{}). It searches every level of nested
+// and embedded structs, following pointer fields
+func HasConfig(c Configer, target interface{}) bool {
+	targetType := reflect.TypeOf(target)
+	if targetType == nil {
+		return false
+	}
 	if targetType.Kind() == reflect.Ptr {
 		targetType = targetType.Elem()
 	}
-	// Check all fields in the Config struct
-	configType := reflect.TypeOf(*c)
-	for i := 0; i < configType.NumField(); i++ {
-		field := configType.Field(i)
-		if field.Type == targetType {
+	return hasFieldType(
+		reflect.TypeOf(c).Elem(),
+		targetType,
+		make(map[reflect.Type]bool),
+	)
+}
+
+// hasFieldType performs a deep recurse into the specified struct type to
+// find a field of the target type
+func hasFieldType(t, target reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		if !isConfigField(t.Field(i)) {
+			continue
+		}
+		fieldType := t.Field(i).Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType == target {
+			return true
+		}
+		if isNestedStruct(fieldType) &&
+			fieldTags(t.Field(i))["type"] != "json" &&
+			hasFieldType(fieldType, target, seen) {
 			return true
 		}
 	}
 	return false
 }
 
-// generate adds generators to the register
-func (c *Config) generate() {
+// setParser assigns an existing parser to the outer config struct
+func (c *Config) setParser(root Configer, v *viper.Viper) {
+	c.root = root
+	c.viper = v
+}
+
+// generate creates the parser from the given flagset for the outer config
+// struct. A nil flagset falls back to the legacy global command line. The
+// error reading the config file, if any, is returned
+func (c *Config) generate(
+	root Configer,
+	fs *pflag.FlagSet,
+	configType string,
+) error {
+	c.root = root
+	if fs != nil {
+		c.flags = fs
+		v, err := createViper(fs, configType)
+		c.viper = v
+		return err
+	}
 	// Create a local flagset for the config flag
-	fs := pflag.NewFlagSet("config", pflag.ContinueOnError)
-	fs.String("config", "", "Path for a configuration file to load")
+	cfs := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	defineConfigFlag(cfs)
 	// Add to global command line if not already defined
 	if pflag.CommandLine.Lookup("config") == nil {
-		pflag.CommandLine.AddFlagSet(fs)
+		pflag.CommandLine.AddFlagSet(cfs)
+	}
+	c.flags = pflag.CommandLine
+	v, err := createViper(nil, configType)
+	c.viper = v
+	return err
+}
+
+// fieldDef holds the resolved tag definition of a single config field
+type fieldDef struct {
+	Name     string
+	Type     string
+	Default  string
+	Desc     string
+	Env      string
+	Layout   string
+	Required bool
+	Secret   bool
+	// Secure requires url fields to use https
+	Secure bool
+	Sep    string
+	// EnvAliases lists older env vars read, in order, when the primary
+	// one is unset
+	EnvAliases []string
+	// Deprecated holds the warning shown when the key is used and NewName
+	// the key its value is copied to
+	Deprecated string
+	NewName    string
+	// ProfileDefault holds profile=value defaults for named profiles
+	ProfileDefault string
+	// OneOf lists the values allowed for the key, if restricted
+	OneOf []string
+	// Min and Max bound the value of int, uint, float and duration keys,
+	// if set
+	Min string
+	Max string
+	// DefaultFn names the resolver computing the default, see
+	// RegisterDefaultFn
+	DefaultFn string
+	// Encoding selects the base64 alphabet of bytes keys, "std" or "url",
+	// and MinLen and MaxLen bound their decoded length, if set
+	Encoding string
+	MinLen   string
+	MaxLen   string
+	// Kind is the kind of the field, or of its element for pointers, which
+	// sets the bit width of int and uint flags. Keys without a field, such
+	// as those of a ConfigBuilder, leave it reflect.Invalid
+	Kind reflect.Kind
+}
+
+// newFieldDef reads the tags of a struct field, applying the given prefix to
+// the flag name
+func newFieldDef(field reflect.StructField, prefix string) fieldDef {
+	tags := fieldTags(field)
+	def := fieldDef{
+		Name:     tags["name"],
+		Type:     tags["type"],
+		Default:  tags["default"],
+		Desc:     tags["desc"],
+		Env:      tags["env"],
+		Layout:   tags["layout"],
+		Required: tags["required"] == "true",
+		Secret:   tags["secret"] == "true",
+		Secure:   tags["secure"] == "true",
+		Sep:      tags["sep"],
+
+		EnvAliases: splitList(tags["envaliases"]),
+
+		Deprecated: tags["deprecated"],
+		NewName:    tags["newname"],
+
+		ProfileDefault: tags["profile_default"],
+
+		OneOf:     strings.Fields(tags["oneof"]),
+		Min:       tags["min"],
+		Max:       tags["max"],
+		DefaultFn: tags["defaultfn"],
+
+		Encoding: tags["encoding"],
+		MinLen:   tags["minlen"],
+		MaxLen:   tags["maxlen"],
+	}
+	if field.Type.Kind() == reflect.Ptr {
+		def.Kind = field.Type.Elem().Kind()
+	} else {
+		def.Kind = field.Type.Kind()
+	}
+	// Types decoding themselves, e.g. a log level enum, need no type tag
+	if def.Type == "" && isTextType(field.Type) {
+		def.Type = "text"
+	}
+	if def.Sep == "" {
+		def.Sep = ","
+	}
+	if def.Layout == "" {
+		def.Layout = time.RFC3339
+	}
+	if prefix != "" && def.Name != "" {
+		def.Name = prefix + "_" + def.Name
+	}
+	if prefix != "" && def.NewName != "" {
+		def.NewName = prefix + "_" + def.NewName
+	}
+	return def
+}
+
+// defaultItems splits the default tag of a slice field on its separator.
+// An empty default is an empty list rather than a single empty item
+func defaultItems(def fieldDef) []string {
+	if def.Default == "" {
+		return []string{}
+	}
+	return strings.Split(def.Default, def.Sep)
+}
+
+// splitList splits a comma separated tag value, dropping empty entries
+func splitList(tag string) []string {
+	var out []string
+	for _, item := range strings.Split(tag, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// timeType and urlType are the types of time.Time and url.URL fields,
+// which hold a single value despite being structs
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	urlType  = reflect.TypeOf(url.URL{})
+)
+
+// isConfigField reports whether a struct field can hold config keys.
+// Unexported fields, such as the internals of Config, are skipped, except
+// for embedded structs whose exported fields are promoted and settable
+// even when the embedded type itself is unexported. Fields tagged to be
+// skipped are left alone along with everything beneath them
+func isConfigField(field reflect.StructField) bool {
+	if !field.IsExported() &&
+		!(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+		return false
+	}
+	return fieldTags(field)["skip"] != "true"
+}
+
+// isNestedStruct reports whether a field type is a struct whose fields are
+// config fields of their own
+func isNestedStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && t != urlType &&
+		!isTextType(t)
+}
+
+// isNestedField reports whether a struct field holds nested config fields,
+// unlike a struct tagged type:"json", which is a single value
+func isNestedField(field reflect.StructField) bool {
+	return isNestedStruct(field.Type) && fieldTags(field)["type"] != "json"
+}
+
+// textUnmarshalerType is the type of encoding.TextUnmarshaler
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isTextType reports whether a field type, or the type it points to,
+// decodes itself through encoding.TextUnmarshaler
+func isTextType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// parseText decodes val with the UnmarshalText method of t, which may be a
+// pointer type. Empty values yield the zero value
+func parseText(t reflect.Type, val string) (reflect.Value, error) {
+	if t.Kind() == reflect.Ptr {
+		ptr, err := parseText(t.Elem(), val)
+		if err != nil {
+			return reflect.Zero(t), err
+		}
+		return ptr.Addr(), nil
+	}
+	ptr := reflect.New(t)
+	if val == "" {
+		return ptr.Elem(), nil
+	}
+	u := ptr.Interface().(encoding.TextUnmarshaler)
+	if err := u.UnmarshalText([]byte(val)); err != nil {
+		return reflect.Zero(t), err
+	}
+	return ptr.Elem(), nil
+}
+
+// parseTime converts a time value from the parser or a default using
+// layout. Empty values yield the zero time
+func parseTime(val interface{}, layout string) (time.Time, error) {
+	if t, ok := val.(time.Time); ok {
+		return t, nil
+	}
+	s := ""
+	if val != nil {
+		s = strings.TrimSpace(fmt.Sprint(val))
+	}
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(layout, s)
+}
+
+// ipType and ipNetType are the field types of ip and cidr fields
+var (
+	ipType    = reflect.TypeOf(net.IP(nil))
+	ipNetType = reflect.TypeOf((*net.IPNet)(nil))
+)
+
+// bytesType is the field type of bytes fields
+var bytesType = reflect.TypeOf([]byte(nil))
+
+// parseIP parses an IP address. Empty values yield a nil IP
+func parseIP(val interface{}) (net.IP, error) {
+	s := ""
+	if val != nil {
+		s = strings.TrimSpace(fmt.Sprint(val))
+	}
+	if s == "" {
+		return nil, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("%q is not a valid IP address", s)
+	}
+	return ip, nil
+}
+
+// parseCIDR parses a CIDR range such as 10.0.0.0/8. Empty values yield a
+// nil range
+func parseCIDR(val interface{}) (*net.IPNet, error) {
+	s := ""
+	if val != nil {
+		s = strings.TrimSpace(fmt.Sprint(val))
+	}
+	if s == "" {
+		return nil, nil
+	}
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a valid CIDR range", s)
+	}
+	return ipNet, nil
+}
+
+// parseURL parses an absolute URL, stripping trailing slashes from its
+// path. A scheme is required, and must be https if secure is set. Empty
+// values yield the zero URL
+func parseURL(val interface{}, secure bool) (url.URL, error) {
+	s := ""
+	if val != nil {
+		s = strings.TrimSpace(fmt.Sprint(val))
+	}
+	if s == "" {
+		return url.URL{}, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return url.URL{}, fmt.Errorf("%q is not a valid URL", s)
+	}
+	if u.Scheme == "" || u.Host == "" && u.Opaque != "" {
+		return url.URL{}, fmt.Errorf("%q has no scheme", s)
+	}
+	if secure && u.Scheme != "https" {
+		return url.URL{}, fmt.Errorf("%q does not use https", s)
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+	return *u, nil
+}
+
+// parseBytes decodes a base64 value, using the URL safe alphabet if
+// alphabet is "url". Empty values yield nil
+func parseBytes(val interface{}, alphabet string) ([]byte, error) {
+	s := ""
+	if val != nil {
+		s = strings.TrimSpace(fmt.Sprint(val))
+	}
+	if s == "" {
+		return nil, nil
+	}
+	enc := base64.StdEncoding
+	if alphabet == "url" {
+		enc = base64.URLEncoding
+	}
+	b, err := enc.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not valid base64", s)
+	}
+	return b, nil
+}
+
+// parseJSON decodes a JSON value into a new value of type t. Values that
+// are not strings, such as an object read from a YAML or JSON config file,
+// are encoded to JSON first. Empty values yield the zero value
+func parseJSON(val interface{}, t reflect.Type) (reflect.Value, error) {
+	var data []byte
+	switch v := val.(type) {
+	case nil:
+	case string:
+		data = []byte(strings.TrimSpace(v))
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return reflect.Zero(t), err
+		}
+		data = b
+	}
+	if len(data) == 0 {
+		return reflect.Zero(t), nil
+	}
+	ptr := reflect.New(t)
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return reflect.Zero(t), fmt.Errorf("invalid JSON: %v", err)
+	}
+	return ptr.Elem(), nil
+}
+
+// joinPrefix combines the current prefix with the prefix tag of a struct
+// field, if any. Anonymous and named fields are treated alike: a tag on
+// either applies to every key beneath it, on top of the prefixes of the
+// structs enclosing it, while the field's name never becomes part of a key
+func joinPrefix(prefix string, field reflect.StructField) string {
+	fieldPrefix := fieldTags(field)["prefix"]
+	if fieldPrefix == "" {
+		return prefix
+	}
+	if prefix != "" {
+		return prefix + "_" + fieldPrefix
+	}
+	return fieldPrefix
+}
+
+// fieldPrefix returns the prefix of the keys beneath a nested struct
+// field, taking it from prefixes, keyed by field name, rather than from
+// the field's prefix tag when the field is listed
+func fieldPrefix(
+	prefix string,
+	field reflect.StructField,
+	prefixes map[string]string,
+) string {
+	override, ok := prefixes[field.Name]
+	if !ok {
+		return joinPrefix(prefix, field)
+	}
+	if prefix != "" && override != "" {
+		return prefix + "_" + override
+	}
+	return prefix + override
+}
+
+// checkFieldPrefixes reports the names given to WithFieldPrefix that are
+// not nested struct fields at the top level of t
+func checkFieldPrefixes(t reflect.Type, prefixes map[string]string) error {
+	for name := range prefixes {
+		field, ok := t.FieldByName(name)
+		if !ok || len(field.Index) != 1 ||
+			!isConfigField(field) || !isNestedField(field) {
+			return fmt.Errorf(
+				"WithFieldPrefix: %s has no nested struct field %q",
+				t.Name(),
+				name,
+			)
+		}
+	}
+	return nil
+}
+
+// walkConfig calls fn for every field of a config that declares a flag
+// name, with the prefixes the config was loaded with
+func walkConfig(
+	c Configer,
+	fn func(def fieldDef, field reflect.StructField, fv reflect.Value),
+) {
+	b := c.base()
+	walkFieldsWithPrefixes(
+		reflect.ValueOf(c).Elem(),
+		b.prefix,
+		b.fieldPrefixes,
+		fn,
+	)
+}
+
+// walkFields performs a deep recurse into the specified struct value and
+// calls fn for every field that declares a flag name
+func walkFields(
+	v reflect.Value,
+	prefix string,
+	fn func(def fieldDef, field reflect.StructField, fv reflect.Value),
+) {
+	walkFieldsWithPrefixes(v, prefix, nil, fn)
+}
+
+// walkFieldsWithPrefixes is like walkFields, replacing the prefix tags of
+// the struct's own fields with those in prefixes
+func walkFieldsWithPrefixes(
+	v reflect.Value,
+	prefix string,
+	prefixes map[string]string,
+	fn func(def fieldDef, field reflect.StructField, fv reflect.Value),
+) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !isConfigField(field) {
+			continue
+		}
+		if isNestedField(field) {
+			walkFields(v.Field(i), fieldPrefix(prefix, field, prefixes), fn)
+			continue
+		}
+		def := newFieldDef(field, prefix)
+		if def.Name == "" {
+			continue
+		}
+		fn(def, field, v.Field(i))
 	}
-	c.viper = CreateViper()
 }
 
 // defineFlagsFromStruct performs a deep recurse into the specified object
 // to find tags and declare them against a flagset
-func defineFlagsFromStruct(t reflect.Type, fs *pflag.FlagSet) {
-	defineFlagsFromStructWithPrefix(t, fs, "")
+func defineFlagsFromStruct(t reflect.Type, fs *pflag.FlagSet) error {
+	return defineFlagsFromStructWithPrefix(t, fs, "", nil)
 }
 
 // defineFlagsFromStructWithPrefix performs a deep recurse into the specified
 // object
-// to find tags and declare them against a flagset, with an optional prefix
+// to find tags and declare them against a flagset, with an optional prefix.
+// Defaults that do not parse are returned as ValidationErrors naming the
+// struct and field, and their flags are registered with the zero value. A
+// flag name already on the flagset is reported as ErrDuplicateFlag, naming
+// both fields, instead of letting pflag panic. prefixes replace the prefix
+// tags of t's own fields, see WithFieldPrefix
 func defineFlagsFromStructWithPrefix(
 	t reflect.Type,
 	fs *pflag.FlagSet,
 	prefix string,
-) {
+	prefixes map[string]string,
+) error {
+	var errs ValidationErrors
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		if field.Type.Kind() == reflect.Struct {
-			// Check if this struct field has a prefix tag
-			fieldPrefix := field.Tag.Get("prefix")
-			newPrefix := prefix
-			if fieldPrefix != "" {
-				if newPrefix != "" {
-					newPrefix = newPrefix + "_" + fieldPrefix
-				} else {
-					newPrefix = fieldPrefix
-				}
+		if !isConfigField(field) {
+			continue
+		}
+		if isNestedField(field) {
+			err := defineFlagsFromStructWithPrefix(
+				field.Type,
+				fs,
+				fieldPrefix(prefix, field, prefixes),
+				nil,
+			)
+			if err != nil {
+				errs = append(errs, err.(ValidationErrors)...)
 			}
-			defineFlagsFromStructWithPrefix(field.Type, fs, newPrefix)
 			continue
 		}
-		flagName := field.Tag.Get("name")
-		if flagName == "" {
+		def := newFieldDef(field, prefix)
+		if def.Name == "" {
 			continue
 		}
-		// Apply prefix to flag name
-		if prefix != "" {
-			flagName = prefix + "_" + flagName
-		}
-		flagType := field.Tag.Get("type")
-		// Define flags based on their types
-		switch flagType {
-		case "string":
-			fs.String(flagName, field.Tag.Get("default"), field.Tag.Get("desc"))
-		case "[]string":
-			fs.StringSlice(
-				flagName,
-				strings.Split(field.Tag.Get("default"), ","),
-				field.Tag.Get("desc"),
-			)
-		case "int":
-			i, err := strconv.Atoi(field.Tag.Get("default"))
-			if err == nil {
-				fs.Int64(flagName, int64(i), field.Tag.Get("desc"))
+		path := t.Name() + "." + field.Name
+		if f := fs.Lookup(def.Name); f != nil {
+			other := "another flag"
+			if owner := f.Annotations[fieldAnnotation]; len(owner) > 0 {
+				other = owner[0]
 			}
-		case "bool":
-			var val bool = false
-			if field.Tag.Get("default") == "true" {
-				val = true
-			}
-			fs.Bool(flagName, val, field.Tag.Get("desc"))
-		case "float32":
-			i, err := strconv.ParseFloat(field.Tag.Get("default"), 32)
-			if err == nil {
-				fs.Float32(flagName, float32(i), field.Tag.Get("desc"))
+			errs = append(errs, fmt.Errorf(
+				"%w %q: defined in %s and %s",
+				ErrDuplicateFlag,
+				def.Name,
+				other,
+				path,
+			))
+			continue
+		}
+		// Pointer fields are left nil until a value is supplied, so an empty
+		// default simply registers the zero value
+		if def.Default == "" && field.Type.Kind() == reflect.Ptr {
+			def.Default = zeroDefaults[def.Type]
+		}
+		err := defineFlag(fs, def)
+		fs.SetAnnotation(def.Name, fieldAnnotation, []string{path})
+		if err == nil && !typeMatches(def.Type, field.Type) {
+			err = fmt.Errorf(
+				"%w: %s has type tag %q",
+				ErrTypeMismatch,
+				field.Type,
+				def.Type,
+			)
+		}
+		var perr error
+		switch {
+		case err != nil:
+		case def.Type == "text":
+			_, perr = parseText(field.Type, def.Default)
+		case def.Type == "json":
+			_, perr = parseJSON(def.Default, field.Type)
+		}
+		if perr != nil {
+			err = fmt.Errorf(
+				"%w %q for %s: %v",
+				ErrInvalidDefault,
+				def.Default,
+				def.Name,
+				perr,
+			)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ErrDuplicateFlag is wrapped by the error reported for two fields of a
+// config, or of configs sharing a flagset, declaring the same flag name
+var ErrDuplicateFlag = errors.New("duplicate flag name")
+
+// fieldAnnotation is the flag annotation recording the struct field that
+// declared a flag, for reporting duplicates
+const fieldAnnotation = "coil_field"
+
+// ErrInvalidDefault is wrapped by the error reported for a default tag
+// that does not parse as the field's type
+var ErrInvalidDefault = errors.New("invalid default")
+
+// ErrTypeMismatch is wrapped by the error reported for a type tag the
+// field's Go type cannot hold, e.g. type:"string" on a bool field
+var ErrTypeMismatch = errors.New("type tag does not match field")
+
+// typeMatches reports whether a field of type t can hold values of the
+// type tag typ. Pointer fields are checked by the type they point to, and
+// unknown type tags are left to defineFlag
+func typeMatches(typ string, t reflect.Type) bool {
+	switch typ {
+	case "ip":
+		return t == ipType
+	case "cidr":
+		return t == ipNetType
+	case "bytes":
+		return t == bytesType
+	case "text":
+		return isTextType(t)
+	case "custom", "json":
+		return true
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch typ {
+	case "string":
+		return t.Kind() == reflect.String
+	case "[]string":
+		return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String
+	case "[]int64", "[]float64":
+		return t.Kind() == reflect.Slice && isNumberSlice(t)
+	case "int":
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+			reflect.Int64:
+			return true
+		}
+		return false
+	case "uint", "octal":
+		switch t.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+			reflect.Uint64:
+			return true
+		}
+		return false
+	case "uint8":
+		return t.Kind() == reflect.Uint8
+	case "bool":
+		return t.Kind() == reflect.Bool
+	case "float32", "float64":
+		return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+	case "duration":
+		return t == reflect.TypeOf(time.Duration(0))
+	case "time":
+		return t == timeType
+	case "url":
+		return t == urlType
+	case "map":
+		return t.Kind() == reflect.Map &&
+			t.Key().Kind() == reflect.String &&
+			t.Elem().Kind() == reflect.String
+	}
+	return true
+}
+
+// defineFlag declares a single flag against a flagset based on its type. A
+// default that does not parse is reported as ErrInvalidDefault, and the
+// flag is registered with the zero value so it is still recognised
+func defineFlag(fs *pflag.FlagSet, def fieldDef) error {
+	flagName := def.Name
+	var err error
+	// Define flags based on their types
+	switch def.Type {
+	case "string":
+		if len(def.OneOf) == 0 {
+			fs.String(flagName, def.Default, def.Desc)
+			break
+		}
+		val := &oneOfValue{allowed: def.OneOf}
+		if err = checkOneOf(def.Default, def.OneOf); err == nil {
+			val.val = def.Default
+		}
+		fs.Var(val, flagName, def.Desc)
+	case "[]string":
+		fs.StringSlice(
+			flagName,
+			defaultItems(def),
+			def.Desc,
+		)
+	case "[]int64":
+		var val reflect.Value
+		val, err = parseNumberSlice(
+			defaultItems(def),
+			reflect.TypeOf([]int64(nil)),
+		)
+		var items []int64
+		if err == nil {
+			items = val.Interface().([]int64)
+		}
+		fs.Int64Slice(flagName, items, def.Desc)
+	case "[]float64":
+		var val reflect.Value
+		val, err = parseNumberSlice(
+			defaultItems(def),
+			reflect.TypeOf([]float64(nil)),
+		)
+		var items []float64
+		if err == nil {
+			items = val.Interface().([]float64)
+		}
+		fs.Float64Slice(flagName, items, def.Desc)
+	case "int":
+		err = defineIntFlag(fs, def)
+	case "uint":
+		err = defineUintFlag(fs, def)
+	case "bool":
+		var val bool = false
+		if def.Default == "true" {
+			val = true
+		}
+		fs.Bool(flagName, val, def.Desc)
+	case "float32":
+		var f float64
+		f, err = strconv.ParseFloat(def.Default, 32)
+		fs.Float32(flagName, float32(f), def.Desc)
+	case "float64":
+		var f float64
+		f, err = strconv.ParseFloat(def.Default, 64)
+		fs.Float64(flagName, f, def.Desc)
+	case "duration":
+		var duration time.Duration
+		duration, err = ParseDurationLenient(def.Default)
+		fs.Duration(flagName, duration, durationUsage(def.Desc))
+	case "time", "text", "custom", "json":
+		fs.String(flagName, def.Default, def.Desc)
+	case "bytes":
+		_, err = parseBytes(def.Default, def.Encoding)
+		fs.String(flagName, def.Default, def.Desc)
+	case "uint8", "octal":
+		_, err = parseUnsigned(def.Default, def.Type, uintBits(def.Kind))
+		fs.String(flagName, def.Default, unsignedUsage(def.Type, def.Desc))
+	case "ip", "cidr", "url":
+		switch def.Type {
+		case "ip":
+			_, err = parseIP(def.Default)
+		case "cidr":
+			_, err = parseCIDR(def.Default)
+		case "url":
+			_, err = parseURL(def.Default, def.Secure)
+		}
+		val := def.Default
+		if err != nil {
+			val = ""
+		}
+		fs.String(flagName, val, def.Desc)
+	case "map":
+		var m map[string]string
+		m, err = parseStringMap(def.Default)
+		fs.StringToString(flagName, m, def.Desc)
+	}
+	if err != nil {
+		return fmt.Errorf(
+			"%w %q for %s: %v",
+			ErrInvalidDefault,
+			def.Default,
+			flagName,
+			err,
+		)
+	}
+	return nil
+}
+
+// oneOfValue is a string flag that rejects values not listed by its oneof
+// tag as soon as they are parsed
+type oneOfValue struct {
+	val     string
+	allowed []string
+}
+
+func (v *oneOfValue) String() string {
+	return v.val
+}
+
+func (v *oneOfValue) Set(val string) error {
+	if err := checkOneOf(val, v.allowed); err != nil {
+		return err
+	}
+	v.val = val
+	return nil
+}
+
+func (v *oneOfValue) Type() string {
+	return "string"
+}
+
+// defineIntFlag declares an int flag whose bit width matches the field,
+// so --help shows e.g. int rather than int64 for int fields
+func defineIntFlag(fs *pflag.FlagSet, def fieldDef) error {
+	name := def.Name
+	switch def.Kind {
+	case reflect.Int:
+		i, err := strconv.ParseInt(def.Default, 10, strconv.IntSize)
+		fs.Int(name, int(i), def.Desc)
+		return err
+	case reflect.Int32:
+		i, err := strconv.ParseInt(def.Default, 10, 32)
+		fs.Int32(name, int32(i), def.Desc)
+		return err
+	case reflect.Int16:
+		i, err := strconv.ParseInt(def.Default, 10, 16)
+		fs.Int16(name, int16(i), def.Desc)
+		return err
+	case reflect.Int8:
+		i, err := strconv.ParseInt(def.Default, 10, 8)
+		fs.Int8(name, int8(i), def.Desc)
+		return err
+	default:
+		i, err := strconv.ParseInt(def.Default, 10, 64)
+		fs.Int64(name, i, def.Desc)
+		return err
+	}
+}
+
+// defineUintFlag declares a uint flag whose bit width matches the field
+func defineUintFlag(fs *pflag.FlagSet, def fieldDef) error {
+	name := def.Name
+	switch def.Kind {
+	case reflect.Uint:
+		i, err := strconv.ParseUint(def.Default, 0, strconv.IntSize)
+		fs.Uint(name, uint(i), def.Desc)
+		return err
+	case reflect.Uint32:
+		i, err := strconv.ParseUint(def.Default, 0, 32)
+		fs.Uint32(name, uint32(i), def.Desc)
+		return err
+	case reflect.Uint16:
+		i, err := strconv.ParseUint(def.Default, 0, 16)
+		fs.Uint16(name, uint16(i), def.Desc)
+		return err
+	case reflect.Uint8:
+		i, err := strconv.ParseUint(def.Default, 0, 8)
+		fs.Uint8(name, uint8(i), def.Desc)
+		return err
+	default:
+		i, err := strconv.ParseUint(def.Default, 0, 64)
+		fs.Uint64(name, i, def.Desc)
+		return err
+	}
+}
+
+// zeroDefaults holds the flag default registered for pointer fields that
+// declare no default, keyed by type tag
+var zeroDefaults = map[string]string{
+	"int":      "0",
+	"uint":     "0",
+	"float32":  "0",
+	"float64":  "0",
+	"duration": "0s",
+}
+
+// parseStringMap parses a map value given either as a JSON object or as a
+// comma separated list of key=value pairs
+func parseStringMap(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return m, nil
+	}
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid map entry %q", pair)
+		}
+		m[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return m, nil
+}
+
+// getStringMap retrieves a map value from the parser, parsing raw string
+// values (i.e. from env vars) the same way as defaults. Malformed values
+// yield nil
+func getStringMap(viper *viper.Viper, key string) map[string]string {
+	if val, ok := viper.Get(key).(string); ok {
+		m, _ := parseStringMap(val)
+		return m
+	}
+	return viper.GetStringMapString(key)
+}
+
+// getStringSlice retrieves a list value from the parser, splitting raw string
+// values (i.e. from env vars) on sep so they match the flag default handling
+func getStringSlice(viper *viper.Viper, key, sep string) []string {
+	switch val := viper.Get(key).(type) {
+	case string:
+		return strings.Split(val, sep)
+	case []string:
+		// CLI values are already split on commas by pflag
+		if sep == "," {
+			return val
+		}
+		var out []string
+		for _, s := range val {
+			out = append(out, strings.Split(s, sep)...)
+		}
+		return out
+	default:
+		return viper.GetStringSlice(key)
+	}
+}
+
+// numberSliceItems returns the unparsed items of a numeric slice value
+func numberSliceItems(viper *viper.Viper, key, sep string) []string {
+	val, ok := viper.Get(key).(string)
+	if ok && strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]") {
+		// pflag reports numeric slice flags to viper as [1,2,3]
+		return strings.Split(strings.Trim(val, "[]"), ",")
+	}
+	return getStringSlice(viper, key, sep)
+}
+
+// parseNumberSlice parses the items into a slice of type t, whose element
+// kind is an int or float. Blank items are skipped
+func parseNumberSlice(items []string, t reflect.Type) (reflect.Value, error) {
+	out := reflect.MakeSlice(t, 0, len(items))
+	elem := t.Elem()
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		v := reflect.New(elem).Elem()
+		switch elem.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+			reflect.Int64:
+			i, err := strconv.ParseInt(item, 10, elem.Bits())
+			if err != nil {
+				return reflect.Value{}, err
 			}
-		case "float64":
-			i, err := strconv.ParseFloat(field.Tag.Get("default"), 64)
-			if err == nil {
-				fs.Float64(flagName, i, field.Tag.Get("desc"))
+			v.SetInt(i)
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(item, elem.Bits())
+			if err != nil {
+				return reflect.Value{}, err
 			}
-		case "duration":
-			duration, err := time.ParseDuration(field.Tag.Get("default"))
-			if err == nil {
-				fs.Duration(flagName, duration, field.Tag.Get("desc"))
+			v.SetFloat(f)
+		default:
+			return reflect.Value{}, fmt.Errorf("unsupported slice type %s", t)
+		}
+		out = reflect.Append(out, v)
+	}
+	return out, nil
+}
+
+// isNumberSlice reports whether t is a slice of ints or floats
+func isNumberSlice(t reflect.Type) bool {
+	switch t.Elem().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Float32, reflect.Float64:
+		return t.Elem() != reflect.TypeOf(time.Duration(0))
+	}
+	return false
+}
+
+// fieldValue returns the value supplied for a field, or its default
+func fieldValue(viper *viper.Viper, def fieldDef) interface{} {
+	if viper.IsSet(def.Name) {
+		return viper.Get(def.Name)
+	}
+	return def.Default
+}
+
+// setParsedValue assigns the value held by the parser for the field to fv
+// based on its kind
+func setParsedValue(fv reflect.Value, def fieldDef, viper *viper.Viper) {
+	key := def.Name
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(viper.GetString(key))
+	case reflect.Bool:
+		fv.SetBool(viper.GetBool(key))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+			fv.SetInt(int64(viper.GetDuration(key)))
+		} else {
+			fv.SetInt(viper.GetInt64(key))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		fv.SetUint(viper.GetUint64(key))
+	case reflect.Float32, reflect.Float64:
+		fv.SetFloat(viper.GetFloat64(key))
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.String {
+			val := getStringSlice(viper, key, def.Sep)
+			fv.Set(reflect.ValueOf(val).Convert(fv.Type()))
+		} else if isNumberSlice(fv.Type()) {
+			items := numberSliceItems(viper, key, def.Sep)
+			if val, err := parseNumberSlice(items, fv.Type()); err == nil {
+				fv.Set(val)
 			}
 		}
 	}
 }
 
+// setIntField assigns a signed integer field of any width, leaving it
+// unchanged when the value does not fit, which invalidValues reports
+func setIntField(fv reflect.Value, def fieldDef, viper *viper.Viper) {
+	val, err := strconv.ParseInt(def.Default, 10, 64)
+	if viper.IsSet(def.Name) {
+		val, err = viper.GetInt64(def.Name), nil
+	}
+	if err == nil && !fv.OverflowInt(val) {
+		fv.SetInt(val)
+	}
+}
+
+// setUnsignedField assigns a uint8 or octal field, leaving pointers nil
+// unless a source supplies a value and the field unchanged when the value
+// does not parse
+func setUnsignedField(fv reflect.Value, def fieldDef, viper *viper.Viper) {
+	if fv.Kind() == reflect.Ptr {
+		if !viper.IsSet(def.Name) {
+			fv.Set(reflect.Zero(fv.Type()))
+			return
+		}
+		ptr := reflect.New(fv.Type().Elem())
+		setUnsignedField(ptr.Elem(), def, viper)
+		fv.Set(ptr)
+		return
+	}
+	val := fieldValue(viper, def)
+	n, err := parseUnsigned(val, def.Type, uintBits(def.Kind))
+	if err == nil {
+		fv.SetUint(n)
+	}
+}
+
 // setPropertiesFromFlags performs a deep recurse into the specified object
 // to retrieve and bind them to the struct
-func setPropertiesFromFlags(vp reflect.Value, viper *viper.Viper) {
-	setPropertiesFromFlagsWithPrefix(vp, viper, "")
+func setPropertiesFromFlags(c Configer) {
+	b := c.base()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	setPropertiesFromFlagsWithPrefix(
+		reflect.ValueOf(c),
+		c.getParser(),
+		b.prefix,
+		b.fieldPrefixes,
+	)
+	b.loadedAt = time.Now()
 }
 
 // setPropertiesFromFlagsWithPrefix performs a deep recurse into the specified
 // object
-// to retrieve and bind them to the struct, with an optional prefix
+// to retrieve and bind them to the struct, with an optional prefix.
+// prefixes replace the prefix tags of the struct's own fields, see
+// WithFieldPrefix
 func setPropertiesFromFlagsWithPrefix(
 	vp reflect.Value,
 	viper *viper.Viper,
 	prefix string,
+	prefixes map[string]string,
 ) {
 	v := vp.Elem()
 	t := v.Type()
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
+		if !isConfigField(field) {
+			continue
+		}
+		def := newFieldDef(field, prefix)
+		flagName := def.Name
+		// Untagged fields, such as Config.SchemaVersion, are not config keys
+		if flagName == "" && !isNestedField(field) {
+			continue
+		}
+		// Custom fields are assigned by their WithCustomParser parser
+		if def.Type == "custom" {
+			continue
+		}
+		if def.Type == "json" {
+			parsed, err := parseJSON(fieldValue(viper, def), field.Type)
+			if err == nil {
+				v.Field(i).Set(parsed)
+			}
+			continue
+		}
+		if def.Type == "uint8" || def.Type == "octal" {
+			setUnsignedField(v.Field(i), def, viper)
+			continue
+		}
+		if def.Type == "text" {
+			// Pointers stay nil unless a source supplies a value
+			if field.Type.Kind() == reflect.Ptr && !viper.IsSet(flagName) {
+				v.Field(i).Set(reflect.Zero(field.Type))
+				continue
+			}
+			val := def.Default
+			if viper.IsSet(flagName) {
+				val = viper.GetString(flagName)
+			}
+			if parsed, err := parseText(field.Type, val); err == nil {
+				v.Field(i).Set(parsed)
+			}
+			continue
+		}
 		switch field.Type.Kind() {
 		case reflect.Struct:
-			// Check if this struct field has a prefix tag
-			fieldPrefix := field.Tag.Get("prefix")
-			newPrefix := prefix
-			if fieldPrefix != "" {
-				if newPrefix != "" {
-					newPrefix = newPrefix + "_" + fieldPrefix
+			if field.Type == timeType {
+				var val time.Time
+				if viper.IsSet(flagName) {
+					val, _ = parseTime(viper.Get(flagName), def.Layout)
 				} else {
-					newPrefix = fieldPrefix
+					val, _ = parseTime(def.Default, def.Layout)
 				}
+				v.Field(i).Set(reflect.ValueOf(val))
+				continue
+			}
+			if field.Type == urlType {
+				u, _ := parseURL(fieldValue(viper, def), def.Secure)
+				v.Field(i).Set(reflect.ValueOf(u))
+				continue
 			}
 			setPropertiesFromFlagsWithPrefix(
 				v.Field(i).Addr(),
 				viper,
-				newPrefix,
+				fieldPrefix(prefix, field, prefixes),
+				nil,
 			)
 		case reflect.String:
-			flagName := field.Tag.Get("name")
-			if prefix != "" && flagName != "" {
-				flagName = prefix + "_" + flagName
-			}
 			val := viper.GetString(flagName)
 			if val == "" {
-				val = field.Tag.Get("default")
+				val = def.Default
 			}
 			v.Field(i).SetString(val)
 		case reflect.Bool:
-			flagName := field.Tag.Get("name")
-			if prefix != "" && flagName != "" {
-				flagName = prefix + "_" + flagName
-			}
 			if viper.IsSet(flagName) {
 				v.Field(i).SetBool(viper.GetBool(flagName))
 			} else {
-				v.Field(i).SetBool(field.Tag.Get("default") == "true")
+				v.Field(i).SetBool(def.Default == "true")
 			}
-		case reflect.Int:
-			flagName := field.Tag.Get("name")
-			if prefix != "" && flagName != "" {
-				flagName = prefix + "_" + flagName
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+			setIntField(v.Field(i), def, viper)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+			reflect.Uint64:
+			val, err := strconv.ParseUint(def.Default, 0, 64)
+			if viper.IsSet(flagName) {
+				val, err = viper.GetUint64(flagName), nil
+			}
+			if err == nil && !v.Field(i).OverflowUint(val) {
+				v.Field(i).SetUint(val)
+			}
+		case reflect.Int64:
+			if field.Type != reflect.TypeOf(time.Duration(0)) {
+				setIntField(v.Field(i), def, viper)
+				continue
 			}
 			if viper.IsSet(flagName) {
-				v.Field(i).SetInt(viper.GetInt64(flagName))
-			} else {
-				if defaultVal, err := strconv.ParseInt(field.Tag.Get("default"), 10, 64); err == nil {
-					v.Field(i).SetInt(defaultVal)
-				}
+				v.Field(i).SetInt(int64(viper.GetDuration(flagName)))
+			} else if d, err := ParseDurationLenient(def.Default); err == nil {
+				v.Field(i).SetInt(int64(d))
 			}
 		case reflect.Float32:
-			flagName := field.Tag.Get("name")
-			if prefix != "" && flagName != "" {
-				flagName = prefix + "_" + flagName
-			}
 			if viper.IsSet(flagName) {
 				v.Field(i).SetFloat(viper.GetFloat64(flagName))
 			} else {
-				if defaultVal, err := strconv.ParseFloat(field.Tag.Get("default"), 32); err == nil {
+				if defaultVal, err := strconv.ParseFloat(def.Default, 32); err == nil {
 					v.Field(i).SetFloat(defaultVal)
 				}
 			}
 		case reflect.Float64:
-			flagName := field.Tag.Get("name")
-			if prefix != "" && flagName != "" {
-				flagName = prefix + "_" + flagName
-			}
 			if viper.IsSet(flagName) {
 				v.Field(i).SetFloat(viper.GetFloat64(flagName))
 			} else {
-				if defaultVal, err := strconv.ParseFloat(field.Tag.Get("default"), 64); err == nil {
+				if defaultVal, err := strconv.ParseFloat(def.Default, 64); err == nil {
 					v.Field(i).SetFloat(defaultVal)
 				}
 			}
+		case reflect.Slice:
+			if field.Type == ipType {
+				ip, _ := parseIP(fieldValue(viper, def))
+				v.Field(i).Set(reflect.ValueOf(ip))
+				continue
+			}
+			if def.Type == "bytes" {
+				b, _ := parseBytes(fieldValue(viper, def), def.Encoding)
+				v.Field(i).SetBytes(b)
+				continue
+			}
+			if isNumberSlice(field.Type) {
+				items := defaultItems(def)
+				if viper.IsSet(flagName) {
+					items = numberSliceItems(viper, flagName, def.Sep)
+				}
+				val, err := parseNumberSlice(items, field.Type)
+				if err == nil {
+					v.Field(i).Set(val)
+				}
+				continue
+			}
+			if field.Type.Elem().Kind() != reflect.String {
+				continue
+			}
+			var val []string
+			if viper.IsSet(flagName) {
+				val = getStringSlice(viper, flagName, def.Sep)
+			} else {
+				val = defaultItems(def)
+			}
+			v.Field(i).Set(reflect.ValueOf(val).Convert(field.Type))
+		case reflect.Map:
+			if field.Type.Key().Kind() != reflect.String ||
+				field.Type.Elem().Kind() != reflect.String {
+				continue
+			}
+			var val map[string]string
+			if viper.IsSet(flagName) {
+				val = getStringMap(viper, flagName)
+			} else {
+				val, _ = parseStringMap(def.Default)
+			}
+			if val != nil {
+				v.Field(i).Set(reflect.ValueOf(val).Convert(field.Type))
+			}
+		case reflect.Ptr:
+			// Pointers stay nil unless a source supplies a value, which lets
+			// callers tell an explicit zero apart from an omitted key
+			if flagName == "" {
+				continue
+			}
+			if field.Type == ipNetType {
+				ipNet, _ := parseCIDR(fieldValue(viper, def))
+				v.Field(i).Set(reflect.ValueOf(ipNet))
+				continue
+			}
+			if !viper.IsSet(flagName) {
+				v.Field(i).Set(reflect.Zero(field.Type))
+				continue
+			}
+			ptr := reflect.New(field.Type.Elem())
+			setParsedValue(ptr.Elem(), def, viper)
+			v.Field(i).Set(ptr)
 		}
 	}
-	// Finally detect if a parse method exists and trigger it
+	// Finally detect if a parse method exists and trigger it. Methods of an
+	// unexported embedded struct cannot be called through reflection, but
+	// are promoted to the parent
 	method := vp.MethodByName("Parse")
-	if method.IsValid() {
+	if method.IsValid() && method.CanInterface() {
 		method.Call([]reflect.Value{reflect.ValueOf(viper)})
 	}
 }
 
-// NewConfig generates a new configuration setup
-func NewConfig(c Configer, merge ...bool) Configer {
-	fs := pflag.NewFlagSet("config", pflag.ContinueOnError)
-	defineFlagsFromStruct(reflect.TypeOf(c).Elem(), fs)
-	// Only merge local flagset into global command line if requested
-	shouldMerge := true // Default to true to maintain original behavior
-	if len(merge) > 0 {
-		shouldMerge = merge[0]
+// NewConfig generates a new configuration setup. Flags are parsed from the
+// process arguments using a flagset scoped to the config, leaving
+// pflag.CommandLine unparsed unless WithGlobalParse is given. The populated
+// config is returned along with any ValidationErrors
+func NewConfig(c Configer, opts ...Option) (Configer, error) {
+	o := newOptions(opts)
+	fs := newFlagSet()
+	if err := defineFlags(c, fs, o); err != nil {
+		return c, err
 	}
-	if shouldMerge {
-		pflag.CommandLine.AddFlagSet(fs)
+	return c, load(context.Background(), c, fs, o)
+}
+
+// contextKey is the type of ContextKey, so it cannot collide with keys
+// from other packages
+type contextKey string
+
+// ContextKey is the key under which NewConfigWithContext stores the config
+// being loaded in the context given to remote sources implementing
+// remote.ContextSource, e.g. for a source shared by several configs to
+// tell which one it is loading
+const ContextKey contextKey = "coil.config"
+
+// NewConfigWithContext is like NewConfig, but reads remote sources, secret
+// stores and Vault with ctx, so a deadline such as one set with
+// context.WithTimeout bounds a load waiting on the network. ctx.Err() is
+// returned if ctx is done before the config is loaded. The sources are
+// given ctx with the config stored under ContextKey. Reloads do not use
+// ctx, which may end once the config is loaded
+func NewConfigWithContext(
+	ctx context.Context,
+	c Configer,
+	opts ...Option,
+) (Configer, error) {
+	if err := ctx.Err(); err != nil {
+		return c, err
 	}
-	c.generate()
-	setPropertiesFromFlags(reflect.ValueOf(c), c.getParser())
-	return c
+	o := newOptions(opts)
+	fs := newFlagSet()
+	if err := defineFlags(c, fs, o); err != nil {
+		return c, err
+	}
+	ctx = context.WithValue(ctx, ContextKey, c)
+	err := load(ctx, c, fs, o)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return c, ctxErr
+	}
+	return c, err
+}
+
+// DefineFlags declares the flags of a config on fs, e.g. that of a cobra
+// command, without parsing it. Once the owner of fs has parsed it,
+// LoadFlags populates the config. The options must match those given to
+// LoadFlags
+func DefineFlags(c Configer, fs *pflag.FlagSet, opts ...Option) error {
+	return defineFlags(c, fs, newOptions(opts))
+}
+
+// LoadFlags populates a config from fs, on which DefineFlags declared its
+// flags, along with the environment, config files and every other source,
+// like NewConfig. fs must already be parsed
+func LoadFlags(c Configer, fs *pflag.FlagSet, opts ...Option) error {
+	if !fs.Parsed() {
+		return errors.New("flagset has not been parsed")
+	}
+	o := newOptions(opts)
+	if err := checkFieldPrefixes(
+		reflect.TypeOf(c).Elem(),
+		o.fieldPrefixes,
+	); err != nil {
+		return err
+	}
+	c.base().prefix = o.prefix
+	c.base().fieldPrefixes = o.fieldPrefixes
+	return load(context.Background(), c, fs, o)
+}
+
+// defineFlags declares the flags of a config, with the prefix given by
+// WithPrefix, and the config file flags on fs
+func defineFlags(c Configer, fs *pflag.FlagSet, o *options) error {
+	t := reflect.TypeOf(c).Elem()
+	if err := checkFieldPrefixes(t, o.fieldPrefixes); err != nil {
+		return err
+	}
+	c.base().prefix = o.prefix
+	c.base().fieldPrefixes = o.fieldPrefixes
+	err := defineFlagsFromStructWithPrefix(
+		t,
+		fs,
+		o.prefix,
+		o.fieldPrefixes,
+	)
+	if err != nil {
+		return err
+	}
+	defineConfigFlag(fs)
+	return nil
+}
+
+// load parses fs, unless already parsed, creates the parser and populates
+// the config
+func load(
+	ctx context.Context,
+	c Configer,
+	fs *pflag.FlagSet,
+	o *options,
+) error {
+	if err := parseFlags(c, fs, o); err != nil {
+		return err
+	}
+	if err := populate(ctx, c, o); err != nil {
+		return err
+	}
+	if o.vault != nil && o.vaultRenewal != nil {
+		go renewVault(o.vaultRenewal, c, o.vault)
+	}
+	return nil
+}
+
+// MustNewConfig is like NewConfig but panics if the config cannot be
+// loaded, for programs that cannot start without it
+func MustNewConfig(c Configer, opts ...Option) Configer {
+	cfg, err := NewConfig(c, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("coil: failed to load config: %v", err))
+	}
+	return cfg
+}
+
+// NewConfigLegacy keeps the signature and behaviour NewConfig had before
+// it took options, for callers migrating gradually: the global
+// pflag.CommandLine is parsed, so flags declared on it elsewhere are still
+// set, and merge maps to WithMerge, defaulting to true. Like MustNewConfig
+// it panics if the config cannot be loaded
+//
+// Deprecated: use NewConfig with WithMerge, which returns the error
+func NewConfigLegacy(c Configer, merge ...bool) Configer {
+	opts := []Option{WithGlobalParse(true), WithMerge(true)}
+	if len(merge) > 0 {
+		opts = append(opts, WithMerge(merge[0]))
+	}
+	return MustNewConfig(c, opts...)
 }
 
 // NewConfigWithFlagSet generates a new configuration setup with a custom
 // flagset
 // This is useful for testing or when you want to use a specific flagset
-func NewConfigWithFlagSet(c Configer, fs *pflag.FlagSet) Configer {
-	defineFlagsFromStruct(reflect.TypeOf(c).Elem(), fs)
-	c.generate()
-	setPropertiesFromFlags(reflect.ValueOf(c), c.getParser())
-	return c
+func NewConfigWithFlagSet(
+	c Configer,
+	fs *pflag.FlagSet,
+) (Configer, error) {
+	if err := defineFlagsFromStruct(reflect.TypeOf(c).Elem(), fs); err != nil {
+		return c, err
+	}
+	defineConfigFlag(fs)
+	if err := c.generate(c, fs, ""); err != nil {
+		return c, err
+	}
+	o := newOptions(nil)
+	c.getParser().SetEnvKeyReplacer(o.envKeyReplacer)
+	return c, populate(context.Background(), c, o)
+}
+
+// NewConfigWithDefaults generates a new configuration setup like NewConfig,
+// with the given defaults, keyed by flag name, replacing those of the
+// default tags. The precedence becomes flags, env vars, config files, the
+// given defaults and then the default tags
+func NewConfigWithDefaults(
+	defaults map[string]interface{},
+	c Configer,
+) (Configer, error) {
+	return NewConfig(c, WithDefaults(defaults))
+}
+
+// NewConfigFromMap populates a config from the given values, keyed by flag
+// name, instead of the command line, environment or config files. Keys
+// that are not registered by the config return ErrUnknownKey. It is meant
+// for tests that need many value combinations without global state, and
+// backs the coil/yaml package
+func NewConfigFromMap(
+	values map[string]interface{},
+	c Configer,
+) (Configer, error) {
+	v := viper.New()
+	for key, val := range values {
+		if _, _, ok := lookupField(c, key); !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKey, key)
+		}
+		v.Set(key, val)
+	}
+	c.setParser(c, v)
+	return c, populate(context.Background(), c, newOptions(nil))
+}
+
+// ParseArgs populates a config from the given arguments using a flagset of
+// its own, never touching pflag.CommandLine or os.Args. Env vars and the
+// config file named by --config still apply. Unknown flags and unreadable
+// config files are returned as errors, along with the config as NewConfig
+// does
+func ParseArgs(c Configer, args []string) (Configer, error) {
+	fs := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := defineFlagsFromStruct(reflect.TypeOf(c).Elem(), fs); err != nil {
+		return c, err
+	}
+	defineConfigFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+	o := newOptions(nil)
+	v := viper.New()
+	v.SetEnvKeyReplacer(o.envKeyReplacer)
+	v.AutomaticEnv()
+	v.BindPFlags(fs)
+	if err := loadConfigFile(v, ""); err != nil {
+		return c, fmt.Errorf("could not read configuration file: %w", err)
+	}
+	c.setParser(c, v)
+	c.base().flags = fs
+	bindEnvOverrides(c, o)
+	return c, populate(context.Background(), c, o)
+}
+
+// newFlagSet creates a flagset scoped to a single config
+func newFlagSet() *pflag.FlagSet {
+	fs := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	// Flags owned by other packages or the test runner are not ours to reject
+	fs.ParseErrorsWhitelist.UnknownFlags = true
+	return fs
+}
+
+// parseFlags declares the config flag, merges the flagset into the global
+// command line if requested and creates the parser for the config
+func parseFlags(c Configer, fs *pflag.FlagSet, o *options) error {
+	defineConfigFlag(fs)
+	// Only merge local flagset into global command line if requested
+	if o.merge {
+		pflag.CommandLine.AddFlagSet(fs)
+	}
+	if o.viper != nil {
+		// The flagset is parsed once even when several configs share it
+		if !fs.Parsed() {
+			args := os.Args[1:]
+			if o.hasArgs {
+				args = o.args
+			}
+			if err := fs.Parse(args); err != nil {
+				return err
+			}
+		}
+		o.viper.AutomaticEnv()
+		o.viper.BindPFlags(fs)
+		if o.viper.ConfigFileUsed() == "" {
+			if err := loadConfigFile(o.viper, o.configType); err != nil {
+				return fmt.Errorf("could not read configuration file: %w", err)
+			}
+		}
+		c.setParser(c, o.viper)
+		c.base().flags = fs
+	} else {
+		var err error
+		switch {
+		case o.hasArgs:
+			// A parsed flagset is left alone by CreateViper
+			if err := fs.Parse(o.args); err != nil {
+				return err
+			}
+			err = c.generate(c, fs, o.configType)
+		case o.globalParse:
+			err = c.generate(c, nil, o.configType)
+		default:
+			err = c.generate(c, fs, o.configType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := searchConfigFile(c.getParser(), o); err != nil {
+		return fmt.Errorf("could not read configuration file: %w", err)
+	}
+	if o.envPrefix != "" {
+		c.getParser().SetEnvPrefix(o.envPrefix)
+	}
+	if o.envKeyReplacer != nil {
+		c.getParser().SetEnvKeyReplacer(o.envKeyReplacer)
+	}
+	bindEnvOverrides(c, o)
+	return nil
+}
+
+// bindEnvOverrides reads fields tagged env from the named variable, which
+// is used as is without the env prefix, and falls back to the variables
+// tagged envaliases in order when the primary one is unset
+func bindEnvOverrides(c Configer, o *options) {
+	parser := c.getParser()
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			if len(def.EnvAliases) > 0 {
+				names := append(
+					[]string{def.Name, primaryEnv(def, o)},
+					def.EnvAliases...,
+				)
+				parser.BindEnv(names...)
+			} else if def.Env != "" {
+				parser.BindEnv(def.Name, def.Env)
+			}
+		},
+	)
+}
+
+// populate assigns the parsed values to the config struct and validates
+// the result, including that required keys were supplied
+func populate(ctx context.Context, c Configer, o *options) error {
+	b := c.base()
+	profile := activeProfile(c.getParser(), o)
+	b.mu.Lock()
+	b.opts, b.profile = o, profile
+	b.mu.Unlock()
+	if err := migrate(c); err != nil {
+		return err
+	}
+	// The parser is written under the write lock, as Print and the admin
+	// handler read it while a reload runs
+	b.mu.Lock()
+	err := applyLocalSources(c, o, profile)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := applyDefaultFns(c); err != nil {
+		return err
+	}
+	if err := applySecretsManager(ctx, c, o.secretsManager, o); err != nil {
+		return err
+	}
+	if err := applyRemote(ctx, c, o.remote); err != nil {
+		return err
+	}
+	if err := applyVault(ctx, c, o.vault); err != nil {
+		return err
+	}
+	applyDeprecations(c, o.deprecationHandler)
+	warnEnvAliases(c, o)
+	setPropertiesFromFlags(c)
+	errs := applyParsers(c, o.parsers)
+	errs = append(errs, missingRequired(c)...)
+	errs = append(errs, invalidValues(c)...)
+	if o.strict {
+		errs = append(errs, unknownEnvVars(c, o)...)
+	}
+	if err := Validate(c); err != nil {
+		errs = append(errs, err.(ValidationErrors)...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// applyLocalSources layers the defaults, profile and dotenv files given in
+// the options, and the fallback config, onto the parser
+func applyLocalSources(c Configer, o *options, profile string) error {
+	if err := applyDefaults(c, o.defaults); err != nil {
+		return err
+	}
+	if err := applyProfile(c, profile); err != nil {
+		return err
+	}
+	if err := loadEnvFile(c.getParser(), o.envFile); err != nil {
+		return err
+	}
+	if err := applyEnvFiles(c.getParser(), o.envFiles); err != nil {
+		return err
+	}
+	applyFallback(c, o.fallback)
+	return nil
+}
+
+// applyDefaults registers the defaults given to WithDefaults with the
+// parser, beneath every source
+func applyDefaults(c Configer, defaults map[string]interface{}) error {
+	parser := c.getParser()
+	for key, val := range defaults {
+		if _, _, ok := lookupField(c, key); !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownKey, key)
+		}
+		parser.SetDefault(key, val)
+	}
+	return nil
+}
+
+// defineConfigFlag declares the config file, env file and profile flags
+// against a flagset
+func defineConfigFlag(fs *pflag.FlagSet) {
+	if fs.Lookup("config") == nil {
+		fs.String("config", "", "Path for a configuration file to load")
+	}
+	if fs.Lookup("env_file") == nil {
+		fs.String("env_file", "", "Path for a dotenv file to load")
+	}
+	if fs.Lookup("profile") == nil {
+		fs.String("profile", "", "Profile selecting per environment defaults")
+	}
+}
+
+// lookupField finds the field registered under the given flag name
+func lookupField(c Configer, key string) (fieldDef, reflect.Value, bool) {
+	var (
+		found fieldDef
+		value reflect.Value
+	)
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, fv reflect.Value) {
+			if def.Name == key {
+				found, value = def, fv
+			}
+		},
+	)
+	return found, value, value.IsValid()
+}
+
+// Key describes a configuration key registered by a config struct
+type Key struct {
+	Name        string
+	Type        string
+	Default     string
+	Description string
+	Required    bool
+}
+
+// Keys returns every configuration key registered by the config struct, with
+// prefixes applied, sorted by name
+func Keys(c Configer) []Key {
+	var keys []Key
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			keys = append(keys, Key{
+				Name:        def.Name,
+				Type:        def.Type,
+				Default:     def.Default,
+				Description: def.Desc,
+				Required:    def.Required,
+			})
+		},
+	)
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
 }
 
 // CreateViper creates a parser instance to configure CLI.
-// It can be used for packages that re-implement the command line flags
-func CreateViper() (v *viper.Viper) {
+// It can be used for packages that re-implement the command line flags.
+// When a flagset is given only that set is parsed from the process arguments.
+// Calling it without one parses the global pflag.CommandLine, which is
+// deprecated as it clashes with any other owner of the command line. It
+// panics if the arguments do not parse or the config file cannot be read;
+// NewConfig returns those errors
+func CreateViper(fs ...*pflag.FlagSet) *viper.Viper {
+	var flags *pflag.FlagSet
+	if len(fs) > 0 {
+		flags = fs[0]
+	}
+	v, err := createViper(flags, "")
+	if err != nil {
+		panic(fmt.Sprintf("coil: %v", err))
+	}
+	return v
+}
+
+// createViper creates a parser bound to fs, or to the global flagset when
+// fs is nil, reading the config file as configType if it is set. The
+// parser is returned along with any error parsing the arguments or
+// reading the config file
+func createViper(
+	fs *pflag.FlagSet,
+	configType string,
+) (*viper.Viper, error) {
 	// Read configurations and assign them
-	v = viper.New()
+	v := viper.New()
 	v.AutomaticEnv()
-	pflag.Parse()
-	v.BindPFlags(pflag.CommandLine)
-	// Override values if they exist already
-	if v.GetString("config") != "" {
-		v.SetConfigFile(v.GetString("config"))
-		if err := v.ReadInConfig(); err != nil {
-			if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-				panic("Could not find configuration file")
-			} else {
-				fmt.Println(err)
-				panic("Could not parse configuration file")
+	if fs != nil {
+		if !fs.Parsed() {
+			if err := fs.Parse(os.Args[1:]); err != nil {
+				return v, err
 			}
 		}
+		v.BindPFlags(fs)
+	} else {
+		pflag.Parse()
+		v.BindPFlags(pflag.CommandLine)
 	}
-	return
+	if err := loadConfigFile(v, configType); err != nil {
+		return v, fmt.Errorf("could not read configuration file: %w", err)
+	}
+	return v, nil
 }
 
 // CreateViperWithFlagSet creates a parser instance with a custom flagset
-// This is useful for testing
-func CreateViperWithFlagSet(fs *pflag.FlagSet) (v *viper.Viper) {
-	v = viper.New()
+// This is useful for testing. It panics if the config file cannot be read
+func CreateViperWithFlagSet(fs *pflag.FlagSet) *viper.Viper {
+	v := viper.New()
 	v.AutomaticEnv()
 	fs.Parse([]string{}) // Parse with empty args for testing
 	v.BindPFlags(fs)
-	if v.GetString("config") != "" {
-		v.SetConfigFile(v.GetString("config"))
-		if err := v.ReadInConfig(); err != nil {
-			if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-				panic("Could not find configuration file")
-			} else {
-				fmt.Println(err)
-				panic("Could not parse configuration file")
-			}
+	if err := loadConfigFile(v, ""); err != nil {
+		panic(fmt.Sprintf("coil: could not read configuration file: %v", err))
+	}
+	return v
+}
+
+// loadConfigFile loads the file named by the config flag, if any, as
+// configType. Without a type the format comes from the file's extension,
+// or is detected from its content when the extension is not one viper
+// supports, e.g. a Kubernetes ConfigMap mounted as a file
+func loadConfigFile(v *viper.Viper, configType string) error {
+	// Override values if they exist already
+	path := v.GetString("config")
+	if path == "" {
+		return nil
+	}
+	v.SetConfigFile(path)
+	if configType == "" && !slices.Contains(
+		viper.SupportedExts,
+		strings.TrimPrefix(filepath.Ext(path), "."),
+	) {
+		if configType = detectConfigType(path); configType != "" {
+			slog.Debug(
+				"coil: detected config file type",
+				"path", path,
+				"type", configType,
+			)
 		}
 	}
-	return
+	if configType != "" {
+		v.SetConfigType(configType)
+	}
+	return v.ReadInConfig()
+}
+
+// detectConfigType guesses the format of a config file from its first
+// characters: { for JSON, --- for YAML and [ for a TOML table. Other files
+// return an empty type, for viper to reject
+func detectConfigType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	text := strings.TrimLeft(string(head[:n]), "\ufeff \t\r\n")
+	switch {
+	case strings.HasPrefix(text, "{"):
+		return "json"
+	case strings.HasPrefix(text, "---"):
+		return "yaml"
+	case strings.HasPrefix(text, "["):
+		return "toml"
+	}
+	return ""
+}
+
+// searchConfigFile reads the first config file found in the
+// WithConfigSearchPaths directories, unless a file was already read
+func searchConfigFile(v *viper.Viper, o *options) error {
+	if len(o.configPaths) == 0 || v.ConfigFileUsed() != "" {
+		return nil
+	}
+	for _, path := range o.configPaths {
+		v.AddConfigPath(path)
+	}
+	name := o.configName
+	if name == "" {
+		name = "config"
+	}
+	v.SetConfigName(name)
+	if o.configType != "" {
+		v.SetConfigType(o.configType)
+	}
+	err := v.ReadInConfig()
+	if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+		return nil
+	}
+	return err
+}
+
+// XDGConfigPaths returns the directories a config file for app is
+// conventionally searched in: $XDG_CONFIG_HOME/app when the variable is
+// set, ~/.config/app and /etc/app
+func XDGConfigPaths(app string) []string {
+	var paths []string
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		paths = append(paths, filepath.Join(dir, app))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".config", app))
+	}
+	return append(paths, filepath.Join("/etc", app))
 }
@@ -1,8 +1,20 @@
 package coil
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
 )
 
 // Config represents your app's local config
@@ -18,7 +30,7 @@ type MyCustomConfig struct {
 
 // NewConfig is a factory generator for your configuration
 func NewConfigTest() *ConfigTest1 {
-	cfg := NewConfig(&ConfigTest1{}, false)
+	cfg := MustNewConfig(&ConfigTest1{}, WithMerge(false))
 	return cfg.(*ConfigTest1)
 }
 
@@ -140,7 +152,7 @@ type ConfigWithPrefix struct {
 
 // NewConfigWithPrefix is a factory generator for prefix testing
 func NewConfigWithPrefix() *ConfigWithPrefix {
-	cfg := NewConfig(&ConfigWithPrefix{}, false)
+	cfg := MustNewConfig(&ConfigWithPrefix{}, WithMerge(false))
 	return cfg.(*ConfigWithPrefix)
 }
 
@@ -220,6 +232,86 @@ func TestConfigWithPrefixDefaults(t *testing.T) {
 	}
 }
 
+// StoreConfig carries its prefix on an embedded struct
+type StoreConfig struct {
+	DatabaseConfig `prefix:"primary"`
+}
+
+// ConfigWithEmbeddedPrefix nests prefixes through embedded and named fields
+type ConfigWithEmbeddedPrefix struct {
+	Config
+	StoreConfig `prefix:"orders"`
+	Archive     StoreConfig
+}
+
+// Test prefixes accumulate through embedded structs as through named ones,
+// and field names never contribute to keys
+func TestEmbeddedPrefix(t *testing.T) {
+	cfg := MustNewConfig(
+		&ConfigWithEmbeddedPrefix{},
+		WithMerge(false),
+		WithArgs([]string{
+			"--orders_primary_dbhost=orders.internal",
+			"--primary_dbport=6000",
+		}),
+	).(*ConfigWithEmbeddedPrefix)
+	if cfg.DBHost != "orders.internal" {
+		t.Errorf("DBHost = %q, want %q", cfg.DBHost, "orders.internal")
+	}
+	if cfg.Archive.DBPort != 6000 {
+		t.Errorf("Archive.DBPort = %d, want %d", cfg.Archive.DBPort, 6000)
+	}
+	if cfg.DBPort != 5432 || cfg.Archive.DBHost != "localhost" {
+		t.Error("prefixed keys leaked between embedded and named fields")
+	}
+}
+
+func TestWithFieldPrefix(t *testing.T) {
+	t.Setenv("APP_STANDBY_DBPORT", "5434")
+	cfg := MustNewConfig(
+		&ConfigWithPrefix{},
+		WithMerge(false),
+		WithPrefix("app"),
+		WithFieldPrefix("ReplicaDB", "standby"),
+		WithFieldPrefix("PrimaryDB", ""),
+		WithArgs([]string{
+			"--app_standby_dbhost=standby.internal",
+			"--app_dbhost=primary.internal",
+		}),
+	).(*ConfigWithPrefix)
+	if cfg.ReplicaDB.DBHost != "standby.internal" {
+		t.Errorf("ReplicaDB.DBHost = %q, want standby.internal",
+			cfg.ReplicaDB.DBHost)
+	}
+	if cfg.PrimaryDB.DBHost != "primary.internal" {
+		t.Errorf("PrimaryDB.DBHost = %q, want primary.internal",
+			cfg.PrimaryDB.DBHost)
+	}
+	if cfg.ReplicaDB.DBPort != 5434 {
+		t.Errorf("ReplicaDB.DBPort = %d, want 5434 from APP_STANDBY_DBPORT",
+			cfg.ReplicaDB.DBPort)
+	}
+	values := ToMap(cfg)
+	if _, ok := values["app_standby_dbhost"]; !ok {
+		t.Errorf("ToMap() = %v, want the overridden key", values)
+	}
+	if _, ok := values["app_replica_dbhost"]; ok {
+		t.Errorf("ToMap() = %v, should not use the tag's prefix", values)
+	}
+
+	for _, name := range []string{"Missing", "SchemaVersion"} {
+		_, err := NewConfig(
+			&ConfigWithPrefix{},
+			WithMerge(false),
+			WithFieldPrefix(name, "x"),
+			WithArgs(nil),
+		)
+		if err == nil {
+			t.Errorf("WithFieldPrefix(%q) should return an error", name)
+		}
+	}
+}
+
 func restoreEnv(key, value string) {
 	if value != "" {
 		os.Setenv(key, value)
@@ -244,7 +336,7 @@ type AllTypesStruct struct {
 }
 
 func NewAllTypesConfig() *AllTypesConfig {
-	cfg := NewConfig(&AllTypesConfig{}, false)
+	cfg := MustNewConfig(&AllTypesConfig{}, WithMerge(false))
 	return cfg.(*AllTypesConfig)
 }
 
@@ -392,7 +484,7 @@ type Level3Struct struct {
 }
 
 func NewNestedConfig() *NestedConfig {
-	cfg := NewConfig(&NestedConfig{}, false)
+	cfg := MustNewConfig(&NestedConfig{}, WithMerge(false))
 	return cfg.(*NestedConfig)
 }
 
@@ -480,7 +572,7 @@ type InnerStruct struct {
 }
 
 func NewNestedPrefixConfig() *NestedPrefixConfig {
-	cfg := NewConfig(&NestedPrefixConfig{}, false)
+	cfg := MustNewConfig(&NestedPrefixConfig{}, WithMerge(false))
 	return cfg.(*NestedPrefixConfig)
 }
 
@@ -563,7 +655,7 @@ type NoTagStruct struct {
 }
 
 func NewNoTagConfig() *NoTagConfig {
-	cfg := NewConfig(&NoTagConfig{}, false)
+	cfg := MustNewConfig(&NoTagConfig{}, WithMerge(false))
 	return cfg.(*NoTagConfig)
 }
 
@@ -597,7 +689,7 @@ type RegularStruct struct {
 }
 
 func NewMixedPrefixConfig() *MixedPrefixConfig {
-	cfg := NewConfig(&MixedPrefixConfig{}, false)
+	cfg := MustNewConfig(&MixedPrefixConfig{}, WithMerge(false))
 	return cfg.(*MixedPrefixConfig)
 }
 
@@ -647,7 +739,7 @@ type EmptyDefaultsStruct struct {
 }
 
 func NewEmptyDefaultConfig() *EmptyDefaultConfig {
-	cfg := NewConfig(&EmptyDefaultConfig{}, false)
+	cfg := MustNewConfig(&EmptyDefaultConfig{}, WithMerge(false))
 	return cfg.(*EmptyDefaultConfig)
 }
 
@@ -703,7 +795,7 @@ func TestNewConfigWithMerge(t *testing.T) {
 	defer restoreEnv("MERGE_TEST_FIELD", origVal)
 
 	// Test with explicit merge=true
-	cfg := NewConfig(&SimpleCfg{}, true)
+	cfg := MustNewConfig(&SimpleCfg{}, WithMerge(true))
 	simpleCfg := cfg.(*SimpleCfg)
 	if simpleCfg.Simple.Field != "merge_default" {
 		t.Errorf("Field = %q, want %q", simpleCfg.Simple.Field, "merge_default")
@@ -727,7 +819,7 @@ func TestEnvVarCaseSensitivity(t *testing.T) {
 	os.Setenv("MIXED_CASE_FIELD", "uppercase_env")
 	defer restoreEnv("MIXED_CASE_FIELD", origVal)
 
-	cfg := NewConfig(&CaseCfg{}, false)
+	cfg := MustNewConfig(&CaseCfg{}, WithMerge(false))
 	caseCfg := cfg.(*CaseCfg)
 	if caseCfg.Case.MixedCase != "uppercase_env" {
 		t.Errorf(
@@ -738,23 +830,1742 @@ func TestEnvVarCaseSensitivity(t *testing.T) {
 	}
 }
 
-// Benchmark for prefix config creation
-func BenchmarkNewConfigWithPrefix(b *testing.B) {
-	for b.Loop() {
-		_ = NewConfigWithPrefix()
+// ListCfg for []string separator testing
+type ListCfg struct {
+	Config
+	Lists ListStruct
+}
+
+type ListStruct struct {
+	Hosts   []string `type:"[]string" name:"list_hosts"   default:"a,b,c"                       desc:"Comma separated hosts"`
+	Origins []string `type:"[]string" name:"list_origins" default:"http://a.com;http://b.com" desc:"Allowed origins" sep:";"`
+}
+
+// Test []string defaults and env vars produce identical values
+func TestStringSliceRoundTrip(t *testing.T) {
+	envVars := []string{"LIST_HOSTS", "LIST_ORIGINS"}
+	origVals := make(map[string]string)
+	for _, env := range envVars {
+		origVals[env] = os.Getenv(env)
+		os.Unsetenv(env)
+	}
+	defer func() {
+		for _, env := range envVars {
+			restoreEnv(env, origVals[env])
+		}
+	}()
+
+	fromDefault := MustNewConfig(&ListCfg{}, WithMerge(false)).(*ListCfg)
+	os.Setenv("LIST_HOSTS", "a,b,c")
+	os.Setenv("LIST_ORIGINS", "http://a.com;http://b.com")
+	fromEnv := MustNewConfig(&ListCfg{}, WithMerge(false)).(*ListCfg)
+
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(fromDefault.Lists.Hosts, want) {
+		t.Errorf("Hosts default = %q, want %q", fromDefault.Lists.Hosts, want)
+	}
+	if !reflect.DeepEqual(fromEnv.Lists.Hosts, want) {
+		t.Errorf("Hosts from env = %q, want %q", fromEnv.Lists.Hosts, want)
+	}
+	want = []string{"http://a.com", "http://b.com"}
+	if !reflect.DeepEqual(fromDefault.Lists.Origins, want) {
+		t.Errorf(
+			"Origins default = %q, want %q",
+			fromDefault.Lists.Origins,
+			want,
+		)
+	}
+	if !reflect.DeepEqual(fromEnv.Lists.Origins, want) {
+		t.Errorf("Origins from env = %q, want %q", fromEnv.Lists.Origins, want)
 	}
 }
 
-// Benchmark for nested config creation
-func BenchmarkNewNestedConfig(b *testing.B) {
-	for b.Loop() {
-		_ = NewNestedConfig()
+// Test an empty []string default is an empty list, not one empty item
+func TestStringSliceEmptyDefault(t *testing.T) {
+	type allowCfg struct {
+		Config
+		AllowedHosts []string `type:"[]string" name:"allowed_hosts" default:""`
+	}
+	cfg, err := ParseArgs(&allowCfg{}, nil)
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	hosts := cfg.(*allowCfg).AllowedHosts
+	if hosts == nil || len(hosts) != 0 {
+		t.Errorf("AllowedHosts = %q, want an empty list", hosts)
+	}
+
+	cfg, err = ParseArgs(&allowCfg{}, []string{"--allowed_hosts=a.com"})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	want := []string{"a.com"}
+	if hosts := cfg.(*allowCfg).AllowedHosts; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("AllowedHosts = %q, want %q", hosts, want)
 	}
 }
 
-// Benchmark for all types config creation
-func BenchmarkNewAllTypesConfig(b *testing.B) {
-	for b.Loop() {
-		_ = NewAllTypesConfig()
+// UintCfg for unsigned integer testing
+type UintCfg struct {
+	Config
+	Workers uint   `type:"uint" name:"uint_workers"  default:"4"    desc:"Workers"`
+	MaxConn uint32 `type:"uint" name:"uint_max_conn" default:"100"  desc:"Max connections"`
+	Mode    uint32 `type:"uint" name:"uint_mode"     default:"0644" desc:"File mode"`
+	Small   uint8  `type:"uint" name:"uint_small"    default:"1"    desc:"Small"`
+}
+
+func TestUintFields(t *testing.T) {
+	c, err := ParseArgs(&UintCfg{}, []string{"--uint_max_conn=250"})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	cfg := c.(*UintCfg)
+	if cfg.Workers != 4 || cfg.MaxConn != 250 || cfg.Mode != 0o644 {
+		t.Errorf(
+			"Workers, MaxConn, Mode = %d, %d, %o, want 4, 250, 644",
+			cfg.Workers,
+			cfg.MaxConn,
+			cfg.Mode,
+		)
+	}
+
+	c, err = NewConfigFromMap(
+		map[string]interface{}{"uint_workers": 8},
+		&UintCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	if cfg := c.(*UintCfg); cfg.Workers != 8 {
+		t.Errorf("Workers = %d, want 8", cfg.Workers)
+	}
+
+	_, err = NewConfigFromMap(
+		map[string]interface{}{"uint_small": 300},
+		&UintCfg{},
+	)
+	if err == nil || !strings.Contains(
+		err.Error(),
+		"uint_small: 300 does not fit in 8 bits",
+	) {
+		t.Errorf("NewConfigFromMap() error = %v, want uint_small overflow", err)
+	}
+}
+
+// OctalCfg for uint8 and octal field testing
+type OctalCfg struct {
+	Config
+	Perm  os.FileMode `type:"octal" name:"oct_perm"  default:"644"  desc:"File mode"`
+	Dir   *uint32     `type:"octal" name:"oct_dir"                  desc:"Directory mode"`
+	Red   uint8       `type:"uint8" name:"oct_red"   default:"0xff" desc:"Red"`
+	Green uint8       `type:"uint8" name:"oct_green" default:"0"    desc:"Green"`
+}
+
+func TestOctalFields(t *testing.T) {
+	t.Setenv("OCT_GREEN", "0o17")
+	c, err := ParseArgs(&OctalCfg{}, []string{"--oct_dir=0o750"})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	cfg := c.(*OctalCfg)
+	if cfg.Perm != 0o644 {
+		t.Errorf("Perm = %o, want 644", cfg.Perm)
+	}
+	if cfg.Dir == nil || *cfg.Dir != 0o750 {
+		t.Errorf("Dir = %v, want 750", cfg.Dir)
+	}
+	if cfg.Red != 255 || cfg.Green != 15 {
+		t.Errorf("Red, Green = %d, %d, want 255, 15", cfg.Red, cfg.Green)
+	}
+	want := map[string]interface{}{
+		"oct_perm":  "0644",
+		"oct_dir":   "0750",
+		"oct_red":   uint8(255),
+		"oct_green": uint8(15),
+	}
+	if got := ToMap(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToMap() = %v, want %v", got, want)
+	}
+
+	// Numbers from a config file are taken as they are
+	c, err = NewConfigFromMap(
+		map[string]interface{}{"oct_perm": 0o600},
+		&OctalCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	if perm := c.(*OctalCfg).Perm; perm != 0o600 {
+		t.Errorf("Perm = %o, want 600", perm)
+	}
+	if dir := c.(*OctalCfg).Dir; dir != nil {
+		t.Errorf("Dir = %v, want nil without a value", dir)
+	}
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	defineFlagsFromStruct(reflect.TypeOf(OctalCfg{}), fs)
+	if usage := fs.Lookup("oct_perm").Usage; !strings.Contains(usage, "0644") {
+		t.Errorf("oct_perm usage = %q, want the octal syntax", usage)
+	}
+}
+
+func TestParseArgsErrorReturnsConfig(t *testing.T) {
+	for _, args := range [][]string{
+		{"--no_such_flag"},
+		{"--oct_perm=0o648"},
+	} {
+		in := &OctalCfg{}
+		c, err := ParseArgs(in, args)
+		if err == nil {
+			t.Errorf("ParseArgs(%q) should return an error", args)
+		}
+		if c != in {
+			t.Errorf("ParseArgs(%q) = %v, want the config passed in", args, c)
+		}
+	}
+}
+
+func TestOctalFieldsInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"--oct_perm=0o648"},
+		{"--oct_red=256"},
+		{"--oct_green=green"},
+	} {
+		_, err := ParseArgs(&OctalCfg{}, args)
+		var errs ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("ParseArgs(%q) error = %v, want ValidationErrors", args, err)
+		}
+	}
+
+	type badDefault struct {
+		Config
+		Perm uint16 `type:"octal" name:"oct_bad" default:"0999"`
+	}
+	_, err := NewConfig(&badDefault{}, WithMerge(false), WithArgs(nil))
+	if !errors.Is(err, ErrInvalidDefault) {
+		t.Errorf("NewConfig() error = %v, want ErrInvalidDefault", err)
+	}
+}
+
+// NumberSliceCfg for numeric slice testing
+type NumberSliceCfg struct {
+	Config
+	Ports []int64   `type:"[]int64"   name:"num_ports"     default:"80,443"  desc:"Ports"`
+	Quant []float64 `type:"[]float64" name:"num_quantiles" default:"0.5;0.9" desc:"Quantiles" sep:";"`
+}
+
+func TestNumberSlices(t *testing.T) {
+	origVal := os.Getenv("NUM_PORTS")
+	os.Unsetenv("NUM_PORTS")
+	defer restoreEnv("NUM_PORTS", origVal)
+
+	cfg, err := ParseArgs(&NumberSliceCfg{}, nil)
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	fromDefault := cfg.(*NumberSliceCfg)
+	if want := []int64{80, 443}; !reflect.DeepEqual(fromDefault.Ports, want) {
+		t.Errorf("Ports default = %v, want %v", fromDefault.Ports, want)
+	}
+	want := []float64{0.5, 0.9}
+	if !reflect.DeepEqual(fromDefault.Quant, want) {
+		t.Errorf("Quantiles default = %v, want %v", fromDefault.Quant, want)
+	}
+
+	cfg, err = ParseArgs(&NumberSliceCfg{}, []string{
+		"--num_ports=8080",
+		"--num_ports=9090",
+		"--num_quantiles=0.99",
+	})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	fromFlags := cfg.(*NumberSliceCfg)
+	if want := []int64{8080, 9090}; !reflect.DeepEqual(fromFlags.Ports, want) {
+		t.Errorf("Ports from flags = %v, want %v", fromFlags.Ports, want)
+	}
+	if want := []float64{0.99}; !reflect.DeepEqual(fromFlags.Quant, want) {
+		t.Errorf("Quantiles from flags = %v, want %v", fromFlags.Quant, want)
+	}
+
+	os.Setenv("NUM_PORTS", "1, 2,3")
+	cfg, err = ParseArgs(&NumberSliceCfg{}, nil)
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	fromEnv := cfg.(*NumberSliceCfg)
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(fromEnv.Ports, want) {
+		t.Errorf("Ports from env = %v, want %v", fromEnv.Ports, want)
+	}
+
+	cfg, err = NewConfigFromMap(map[string]interface{}{
+		"num_ports": []interface{}{22, 2222},
+	}, &NumberSliceCfg{})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	fromMap := cfg.(*NumberSliceCfg)
+	if want := []int64{22, 2222}; !reflect.DeepEqual(fromMap.Ports, want) {
+		t.Errorf("Ports from map = %v, want %v", fromMap.Ports, want)
+	}
+}
+
+// Test Keys returns prefixed key names in sorted order
+func TestKeys(t *testing.T) {
+	keys := Keys(&ConfigWithPrefix{})
+	if len(keys) != 24 {
+		t.Fatalf("Keys() returned %d keys, want %d", len(keys), 24)
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1].Name > keys[i].Name {
+			t.Errorf(
+				"Keys() not sorted: %q before %q",
+				keys[i-1].Name,
+				keys[i].Name,
+			)
+		}
+	}
+	want := Key{
+		Name:        "primary_dbhost",
+		Type:        "string",
+		Default:     "localhost",
+		Description: "Database hostname",
+	}
+	for _, key := range keys {
+		if key.Name == want.Name {
+			if key != want {
+				t.Errorf("Keys() entry = %+v, want %+v", key, want)
+			}
+			return
+		}
+	}
+	t.Errorf("Keys() missing %q", want.Name)
+}
+
+// ArgsCfg for scoped flag parsing
+type ArgsCfg struct {
+	Config
+	Args ArgsStruct
+}
+
+type ArgsStruct struct {
+	Field string `type:"string" name:"scoped_field" default:"scoped_default" desc:"Scoped field"`
+}
+
+// Test flags are parsed from the process arguments without touching the
+// global command line
+func TestScopedFlagParsing(t *testing.T) {
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app", "--scoped_field=from_cli", "--not_ours=1"}
+
+	cfg := MustNewConfig(&ArgsCfg{}, WithMerge(false)).(*ArgsCfg)
+	if cfg.Args.Field != "from_cli" {
+		t.Errorf("Field = %q, want %q", cfg.Args.Field, "from_cli")
+	}
+	if pflag.CommandLine.Lookup("scoped_field") != nil {
+		t.Error("scoped_field should not be merged into pflag.CommandLine")
+	}
+}
+
+// Test a value that does not parse fails the load, rather than dropping
+// it and the flags after it
+func TestBadFlagValue(t *testing.T) {
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	args := []string{"--primary_dbport=abc", "--primary_dbhost=b"}
+	os.Args = append([]string{"app"}, args...)
+
+	if _, err := NewConfig(&ConfigWithPrefix{}, WithMerge(false)); err == nil {
+		t.Error("NewConfig() with a bad flag value should return an error")
+	}
+	_, err := NewConfig(&ConfigWithPrefix{}, WithMerge(false), WithArgs(args))
+	if err == nil {
+		t.Error("NewConfig(WithArgs) with a bad flag value should return an error")
+	}
+}
+
+// PtrCfg for pointer field testing
+type PtrCfg struct {
+	Config
+	Ptrs PtrStruct
+}
+
+type PtrStruct struct {
+	Name    *string        `type:"string"   name:"ptr_name"    desc:"Optional name"`
+	Count   *int           `type:"int"      name:"ptr_count"   desc:"Optional count"`
+	Enabled *bool          `type:"bool"     name:"ptr_enabled" desc:"Optional toggle"`
+	Timeout *time.Duration `type:"duration" name:"ptr_timeout" desc:"Optional timeout"`
+}
+
+// Test pointer fields stay nil when unset and capture explicit zero values
+func TestPointerFields(t *testing.T) {
+	envVars := []string{"PTR_NAME", "PTR_COUNT", "PTR_ENABLED", "PTR_TIMEOUT"}
+	origVals := make(map[string]string)
+	for _, env := range envVars {
+		origVals[env] = os.Getenv(env)
+		os.Unsetenv(env)
+	}
+	defer func() {
+		for _, env := range envVars {
+			restoreEnv(env, origVals[env])
+		}
+	}()
+
+	cfg := MustNewConfig(&PtrCfg{}, WithMerge(false)).(*PtrCfg)
+	if cfg.Ptrs.Name != nil || cfg.Ptrs.Count != nil ||
+		cfg.Ptrs.Enabled != nil || cfg.Ptrs.Timeout != nil {
+		t.Errorf("unset pointer fields should be nil, got %+v", cfg.Ptrs)
+	}
+
+	os.Setenv("PTR_NAME", "named")
+	os.Setenv("PTR_COUNT", "0")
+	os.Setenv("PTR_ENABLED", "false")
+	os.Setenv("PTR_TIMEOUT", "5s")
+	cfg = MustNewConfig(&PtrCfg{}, WithMerge(false)).(*PtrCfg)
+	if cfg.Ptrs.Name == nil || *cfg.Ptrs.Name != "named" {
+		t.Errorf("Name = %v, want %q", cfg.Ptrs.Name, "named")
+	}
+	if cfg.Ptrs.Count == nil || *cfg.Ptrs.Count != 0 {
+		t.Errorf("Count = %v, want explicit 0", cfg.Ptrs.Count)
+	}
+	if cfg.Ptrs.Enabled == nil || *cfg.Ptrs.Enabled {
+		t.Errorf("Enabled = %v, want explicit false", cfg.Ptrs.Enabled)
+	}
+	if cfg.Ptrs.Timeout == nil || *cfg.Ptrs.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want %v", cfg.Ptrs.Timeout, 5*time.Second)
+	}
+}
+
+// Test pointer fields are registered as flags and set from the CLI
+func TestPointerFieldsFromFlags(t *testing.T) {
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app", "--ptr_count=3"}
+
+	cfg := MustNewConfig(&PtrCfg{}, WithMerge(false)).(*PtrCfg)
+	if cfg.Ptrs.Count == nil || *cfg.Ptrs.Count != 3 {
+		t.Errorf("Count = %v, want 3", cfg.Ptrs.Count)
+	}
+	if cfg.Ptrs.Timeout != nil {
+		t.Errorf("Timeout = %v, want nil", *cfg.Ptrs.Timeout)
+	}
+}
+
+// EnvPrefixCfg for env prefix testing
+type EnvPrefixCfg struct {
+	Config
+	Name  string         `type:"string" name:"envp_name" default:"" desc:"Name"`
+	DB    DatabaseConfig `prefix:"primary"`
+	Outer OuterStruct    `prefix:"outer"`
+}
+
+// Test WithEnvPrefix composes with field prefixes at every level
+func TestWithEnvPrefix(t *testing.T) {
+	envVars := map[string]string{
+		"MYAPP_ENVP_NAME":         "namespaced",
+		"MYAPP_PRIMARY_DBHOST":    "primary.example.com",
+		"MYAPP_OUTER_FIELD":       "outer_env",
+		"MYAPP_OUTER_INNER_FIELD": "inner_env",
+		"ENVP_NAME":               "unprefixed",
+		"PRIMARY_DBHOST":          "unprefixed.example.com",
+	}
+	origVals := make(map[string]string)
+	for key, val := range envVars {
+		origVals[key] = os.Getenv(key)
+		os.Setenv(key, val)
+	}
+	defer func() {
+		for key, val := range origVals {
+			restoreEnv(key, val)
+		}
+	}()
+
+	cfg := MustNewConfig(
+		&EnvPrefixCfg{},
+		WithMerge(false),
+		WithEnvPrefix("MYAPP"),
+	).(*EnvPrefixCfg)
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"root field", cfg.Name, "namespaced"},
+		{"prefixed field", cfg.DB.DBHost, "primary.example.com"},
+		{"nested prefix", cfg.Outer.OuterField, "outer_env"},
+		{"double nested prefix", cfg.Outer.Inner.InnerField, "inner_env"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// Without the option the unprefixed variables are used
+	cfg = MustNewConfig(&EnvPrefixCfg{}, WithMerge(false)).(*EnvPrefixCfg)
+	if cfg.Name != "unprefixed" {
+		t.Errorf("Name = %q, want %q", cfg.Name, "unprefixed")
+	}
+	if cfg.DB.DBHost != "unprefixed.example.com" {
+		t.Errorf(
+			"DBHost = %q, want %q",
+			cfg.DB.DBHost,
+			"unprefixed.example.com",
+		)
+	}
+}
+
+// EnvKeyCfg for env key replacer testing
+type EnvKeyCfg struct {
+	Config
+	Host string `type:"string" name:"envkey-host" default:"localhost"`
+	Port int    `type:"int"    name:"envkey.port" default:"80"`
+}
+
+// Test the . and - of flag names map onto env var names
+func TestWithEnvKeyReplacer(t *testing.T) {
+	t.Setenv("ENVKEY_HOST", "underscored.example.com")
+	t.Setenv("ENVKEY_PORT", "8080")
+	t.Setenv("APP_ENVKEY_PORT", "9090")
+	t.Setenv("ENVKEY-HOST", "dashed.example.com")
+	t.Setenv("ENVKEY__HOST", "doubled.example.com")
+
+	tests := []struct {
+		name string
+		opts []Option
+		host string
+		port int
+		env  string
+	}{
+		{
+			name: "default",
+			host: "underscored.example.com",
+			port: 8080,
+			env:  "ENVKEY_HOST=underscored.example.com",
+		},
+		{
+			name: "with prefix",
+			opts: []Option{WithEnvPrefix("APP")},
+			host: "localhost",
+			port: 9090,
+			env:  "APP_ENVKEY_PORT=9090",
+		},
+		{
+			name: "custom",
+			opts: []Option{WithEnvKeyReplacer(strings.NewReplacer("-", "__"))},
+			host: "doubled.example.com",
+			port: 80,
+			env:  "ENVKEY__HOST=doubled.example.com",
+		},
+		{
+			name: "disabled",
+			opts: []Option{WithNoEnvKeyReplacer()},
+			host: "dashed.example.com",
+			port: 80,
+			env:  "ENVKEY-HOST=dashed.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithMerge(false), WithArgs(nil)}, tt.opts...)
+			cfg := MustNewConfig(&EnvKeyCfg{}, opts...).(*EnvKeyCfg)
+			if cfg.Host != tt.host || cfg.Port != tt.port {
+				t.Errorf(
+					"Host, Port = %q, %d, want %q, %d",
+					cfg.Host,
+					cfg.Port,
+					tt.host,
+					tt.port,
+				)
+			}
+			if !slices.Contains(ToEnv(cfg), tt.env) {
+				t.Errorf("ToEnv() = %q, want it to contain %q", ToEnv(cfg), tt.env)
+			}
+		})
+	}
+}
+
+// Test NewConfigFromMap populates values without any global state
+func TestNewConfigFromMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[string]interface{}
+		wantHost string
+		wantPort int
+	}{
+		{"defaults", nil, "localhost", 5432},
+		{
+			"overrides",
+			map[string]interface{}{
+				"primary_dbhost": "db.internal",
+				"primary_dbport": 6543,
+			},
+			"db.internal",
+			6543,
+		},
+		{
+			"string values",
+			map[string]interface{}{"primary_dbport": "7000"},
+			"localhost",
+			7000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := NewConfigFromMap(tt.values, &ConfigWithPrefix{})
+			if err != nil {
+				t.Fatalf("NewConfigFromMap() error = %v", err)
+			}
+			cfg := c.(*ConfigWithPrefix)
+			db := cfg.PrimaryDB
+			if db.DBHost != tt.wantHost {
+				t.Errorf("DBHost = %q, want %q", db.DBHost, tt.wantHost)
+			}
+			if db.DBPort != tt.wantPort {
+				t.Errorf("DBPort = %d, want %d", db.DBPort, tt.wantPort)
+			}
+		})
+	}
+}
+
+// Test NewConfigFromMap rejects keys the config does not register
+func TestNewConfigFromMapUnknownKey(t *testing.T) {
+	_, err := NewConfigFromMap(
+		map[string]interface{}{"primary_dbhots": "typo"},
+		&ConfigWithPrefix{},
+	)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("NewConfigFromMap() error = %v, want ErrUnknownKey", err)
+	}
+}
+
+// MapCfg for map field testing
+type MapCfg struct {
+	Config
+	Headers map[string]string `type:"map" name:"map_headers" default:"{\"X-Source\":\"coil\"}" desc:"Headers to forward"`
+	Labels  map[string]string `type:"map" name:"map_labels"  default:"tier=web,zone=a"          desc:"Labels"`
+}
+
+// Test map fields from defaults, env vars and CLI flags
+func TestMapFields(t *testing.T) {
+	origVal := os.Getenv("MAP_HEADERS")
+	os.Unsetenv("MAP_HEADERS")
+	defer restoreEnv("MAP_HEADERS", origVal)
+
+	cfg := MustNewConfig(&MapCfg{}, WithMerge(false)).(*MapCfg)
+	if !reflect.DeepEqual(cfg.Headers, map[string]string{"X-Source": "coil"}) {
+		t.Errorf("Headers default = %v", cfg.Headers)
+	}
+	if !reflect.DeepEqual(
+		cfg.Labels,
+		map[string]string{"tier": "web", "zone": "a"},
+	) {
+		t.Errorf("Labels default = %v", cfg.Labels)
 	}
+
+	os.Setenv("MAP_HEADERS", "X-Trace=on,X-Token=a:b")
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app", "--map_labels=tier=db"}
+	cfg = MustNewConfig(&MapCfg{}, WithMerge(false)).(*MapCfg)
+	want := map[string]string{"X-Trace": "on", "X-Token": "a:b"}
+	if !reflect.DeepEqual(cfg.Headers, want) {
+		t.Errorf("Headers from env = %v, want %v", cfg.Headers, want)
+	}
+	if !reflect.DeepEqual(cfg.Labels, map[string]string{"tier": "db"}) {
+		t.Errorf("Labels from flag = %v", cfg.Labels)
+	}
+}
+
+// TimeCfg for time field testing
+type TimeCfg struct {
+	Config
+	Expiry      time.Time `type:"time" name:"time_expiry"      default:"2030-01-31" desc:"Expiry" layout:"2006-01-02"`
+	Maintenance time.Time `type:"time" name:"time_maintenance" default:""           desc:"Window"`
+}
+
+// Test time fields parse defaults and env vars using their layout
+func TestTimeFields(t *testing.T) {
+	c, err := NewConfigFromMap(
+		map[string]interface{}{"time_maintenance": "2025-06-01T02:00:00Z"},
+		&TimeCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	cfg := c.(*TimeCfg)
+	wantExpiry := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+	if !cfg.Expiry.Equal(wantExpiry) {
+		t.Errorf("Expiry = %v, want %v", cfg.Expiry, wantExpiry)
+	}
+	wantWindow := time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)
+	if !cfg.Maintenance.Equal(wantWindow) {
+		t.Errorf("Maintenance = %v, want %v", cfg.Maintenance, wantWindow)
+	}
+	if got := ToMap(cfg)["time_expiry"]; got != "2030-01-31" {
+		t.Errorf("ToMap() time_expiry = %v, want %q", got, "2030-01-31")
+	}
+
+	zero, err := NewConfigFromMap(nil, &TimeCfg{})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	if !zero.(*TimeCfg).Maintenance.IsZero() {
+		t.Error("Maintenance should be zero without a value")
+	}
+}
+
+// Test time values that do not match their layout are reported
+func TestTimeFieldsInvalid(t *testing.T) {
+	_, err := NewConfigFromMap(
+		map[string]interface{}{"time_expiry": "31/01/2030"},
+		&TimeCfg{},
+	)
+	if err == nil || !strings.Contains(err.Error(), "time_expiry") {
+		t.Errorf("NewConfigFromMap() error = %v, want time_expiry error", err)
+	}
+}
+
+// NetCfg for ip and cidr field testing
+type NetCfg struct {
+	Config
+	Bind    net.IP     `type:"ip"   name:"net_bind"    default:"127.0.0.1" desc:"Bind address"`
+	Allowed *net.IPNet `type:"cidr" name:"net_allowed" default:"10.0.0.0/8" desc:"Allowed clients"`
+	Gateway net.IP     `type:"ip"   name:"net_gateway" default:""          desc:"Gateway"`
+}
+
+// Test ip and cidr fields parse defaults and supplied values
+func TestNetFields(t *testing.T) {
+	c, err := NewConfigFromMap(
+		map[string]interface{}{"net_gateway": "fd00::1"},
+		&NetCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	cfg := c.(*NetCfg)
+	if !cfg.Bind.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Bind = %v, want 127.0.0.1", cfg.Bind)
+	}
+	if !cfg.Gateway.Equal(net.ParseIP("fd00::1")) {
+		t.Errorf("Gateway = %v, want fd00::1", cfg.Gateway)
+	}
+	if cfg.Allowed == nil || !cfg.Allowed.Contains(net.ParseIP("10.1.2.3")) {
+		t.Errorf("Allowed = %v, want 10.0.0.0/8", cfg.Allowed)
+	}
+	want := map[string]interface{}{
+		"net_bind":    "127.0.0.1",
+		"net_allowed": "10.0.0.0/8",
+		"net_gateway": "fd00::1",
+	}
+	if got := ToMap(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToMap() = %v, want %v", got, want)
+	}
+
+	c, err = NewConfigFromMap(nil, &NetCfg{})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	if gateway := c.(*NetCfg).Gateway; gateway != nil {
+		t.Errorf("Gateway = %v, want nil without a value", gateway)
+	}
+}
+
+// Test malformed ip and cidr values are reported
+func TestNetFieldsInvalid(t *testing.T) {
+	_, err := NewConfigFromMap(
+		map[string]interface{}{
+			"net_bind":    "localhost",
+			"net_allowed": "10.0.0.0",
+		},
+		&NetCfg{},
+	)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("NewConfigFromMap() error = %v, want 2 errors", err)
+	}
+	for _, key := range []string{"net_bind", "net_allowed"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("NewConfigFromMap() error = %v, want %s error", err, key)
+		}
+	}
+}
+
+// Test an invalid ip or cidr default panics when the flag is defined
+func TestNetFieldsInvalidDefault(t *testing.T) {
+	for _, def := range []fieldDef{
+		{Name: "bad_ip", Type: "ip", Default: "nowhere"},
+		{Name: "bad_cidr", Type: "cidr", Default: "10.0.0.1"},
+	} {
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		err := defineFlag(fs, def)
+		if !errors.Is(err, ErrInvalidDefault) {
+			t.Errorf("defineFlag(%s) error = %v, want ErrInvalidDefault",
+				def.Name, err)
+		}
+		if fs.Lookup(def.Name) == nil {
+			t.Errorf("defineFlag(%s) did not register the flag", def.Name)
+		}
+	}
+
+	_, err := NewConfig(&BadURLCfg{}, WithMerge(false), WithArgs(nil))
+	if !errors.Is(err, ErrInvalidDefault) {
+		t.Errorf("NewConfig() error = %v, want ErrInvalidDefault", err)
+	}
+}
+
+// BadURLCfg declares a url field whose default has no scheme
+type BadURLCfg struct {
+	Config
+	Endpoint url.URL `type:"url" name:"bad_endpoint" default:"localhost:8080"`
+}
+
+// BytesCfg for bytes field testing
+type BytesCfg struct {
+	Config
+	Key   []byte `type:"bytes" name:"bytes_key"   default:"c2VjcmV0" minlen:"4" maxlen:"16"`
+	Token []byte `type:"bytes" name:"bytes_token" encoding:"url"`
+}
+
+// Test bytes fields decode base64 defaults, flags and env vars
+func TestBytesFields(t *testing.T) {
+	t.Setenv("BYTES_TOKEN", "-_-_")
+	cfg := MustNewConfig(
+		&BytesCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--bytes_key", "aGVsbG8gd29ybGQ="}),
+	).(*BytesCfg)
+	if string(cfg.Key) != "hello world" {
+		t.Errorf("Key = %q, want %q", cfg.Key, "hello world")
+	}
+	if want := []byte{0xfb, 0xff, 0xbf}; !bytes.Equal(cfg.Token, want) {
+		t.Errorf("Token = %v, want %v", cfg.Token, want)
+	}
+	want := map[string]interface{}{
+		"bytes_key":   "aGVsbG8gd29ybGQ=",
+		"bytes_token": "-_-_",
+	}
+	if got := ToMap(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToMap() = %v, want %v", got, want)
+	}
+
+	cfg = MustNewConfig(&BytesCfg{}, WithMerge(false), WithArgs(nil)).(*BytesCfg)
+	if string(cfg.Key) != "secret" {
+		t.Errorf("Key = %q, want default %q", cfg.Key, "secret")
+	}
+}
+
+// Test malformed base64 and lengths outside minlen and maxlen are reported
+func TestBytesFieldsInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--bytes_key", "not base64!"}, "is not valid base64"},
+		{[]string{"--bytes_key", "YWJj"}, "3 bytes is below the minimum of 4"},
+		{
+			[]string{"--bytes_key", "MDEyMzQ1Njc4OWFiY2RlZmc="},
+			"17 bytes is above the maximum of 16",
+		},
+		{[]string{"--bytes_token", "a+b/"}, "bytes_token"},
+	} {
+		_, err := NewConfig(&BytesCfg{}, WithMerge(false), WithArgs(tt.args))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("NewConfig(%v) error = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+// RoutingRule is decoded from JSON by JSONCfg
+type RoutingRule struct {
+	Path    string `json:"path"`
+	Backend string `json:"backend"`
+	// Tags on a JSON field's type are not config keys
+	Weight int `json:"weight" type:"int" name:"json_weight"`
+}
+
+// JSONCfg for json field testing
+type JSONCfg struct {
+	Config
+	Route  RoutingRule             `type:"json" name:"json_route" default:"{\"path\":\"/\",\"backend\":\"web\"}"`
+	Routes []RoutingRule           `type:"json" name:"json_routes"`
+	Flags  map[string]bool         `type:"json" name:"json_flags"  default:"{\"beta\":true}"`
+	Extra  *map[string]RoutingRule `type:"json" name:"json_extra"`
+}
+
+// Test json fields decode defaults, flags and config file objects
+func TestJSONFields(t *testing.T) {
+	cfg := MustNewConfig(
+		&JSONCfg{},
+		WithMerge(false),
+		WithArgs([]string{
+			`--json_routes=[{"path":"/api","backend":"api","weight":2}]`,
+		}),
+	).(*JSONCfg)
+	if cfg.Route != (RoutingRule{Path: "/", Backend: "web"}) {
+		t.Errorf("Route = %+v, want the default", cfg.Route)
+	}
+	want := []RoutingRule{{Path: "/api", Backend: "api", Weight: 2}}
+	if !reflect.DeepEqual(cfg.Routes, want) {
+		t.Errorf("Routes = %+v, want %+v", cfg.Routes, want)
+	}
+	if !cfg.Flags["beta"] {
+		t.Errorf("Flags = %v, want beta", cfg.Flags)
+	}
+	if cfg.Extra != nil {
+		t.Errorf("Extra = %v, want nil without a value", cfg.Extra)
+	}
+	if keys := Keys(cfg); len(keys) != 4 {
+		t.Errorf("Keys() = %v, want the 4 json keys", keys)
+	}
+	got := ToMap(cfg)["json_routes"]
+	if got != `[{"path":"/api","backend":"api","weight":2}]` {
+		t.Errorf("ToMap() json_routes = %v", got)
+	}
+
+	// Objects in a config file need not be written as JSON strings
+	c, err := NewConfigFromMap(
+		map[string]interface{}{
+			"json_extra": map[string]interface{}{
+				"admin": map[string]interface{}{"path": "/admin"},
+			},
+		},
+		&JSONCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	extra := c.(*JSONCfg).Extra
+	if extra == nil || (*extra)["admin"].Path != "/admin" {
+		t.Errorf("Extra = %v, want admin path /admin", extra)
+	}
+}
+
+// Test malformed json values and defaults are reported
+func TestJSONFieldsInvalid(t *testing.T) {
+	_, err := NewConfig(
+		&JSONCfg{},
+		WithMerge(false),
+		WithArgs([]string{`--json_routes={"path":"/"}`}),
+	)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || !strings.Contains(err.Error(), "json_routes") {
+		t.Errorf("NewConfig() error = %v, want json_routes error", err)
+	}
+
+	type badDefault struct {
+		Config
+		Route RoutingRule `type:"json" name:"json_bad" default:"{path:/}"`
+	}
+	_, err = NewConfig(&badDefault{}, WithMerge(false), WithArgs(nil))
+	if !errors.Is(err, ErrInvalidDefault) {
+		t.Errorf("NewConfig() error = %v, want ErrInvalidDefault", err)
+	}
+}
+
+// BadDefaultCfg declares numeric defaults that do not parse
+type BadDefaultCfg struct {
+	Config
+	Workers int     `type:"int"     name:"bad_workers" default:"four" desc:"Workers"`
+	Ratio   float64 `type:"float64" name:"bad_ratio"   default:"half" desc:"Ratio"`
+	Size    float32 `type:"float32" name:"bad_size"    default:"1.5"  desc:"Size"`
+}
+
+// Test unparseable defaults are reported instead of dropping the flag
+func TestInvalidNumericDefaults(t *testing.T) {
+	_, err := NewConfig(&BadDefaultCfg{}, WithMerge(false), WithArgs(nil))
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("NewConfig() error = %v, want 2 errors", err)
+	}
+	if !errors.Is(err, ErrInvalidDefault) {
+		t.Errorf("NewConfig() error = %v, want ErrInvalidDefault", err)
+	}
+	for _, want := range []string{
+		`BadDefaultCfg.Workers: invalid default "four" for bad_workers`,
+		`BadDefaultCfg.Ratio: invalid default "half" for bad_ratio`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("NewConfig() error = %v, want %q", err, want)
+		}
+	}
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	defineFlagsFromStruct(reflect.TypeOf(BadDefaultCfg{}), fs)
+	for _, name := range []string{"bad_workers", "bad_ratio", "bad_size"} {
+		if fs.Lookup(name) == nil {
+			t.Errorf("flag %s was not registered", name)
+		}
+	}
+}
+
+// Test fractional float defaults parse wherever a default is read, as an
+// integer parse would reject them
+func TestFractionalFloatDefaults(t *testing.T) {
+	type fractional struct {
+		Config
+		Ratio float32 `type:"float32" name:"frac_ratio" default:"3.14"`
+		Scale float64 `type:"float64" name:"frac_scale" default:"0.5"`
+	}
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	err := defineFlagsFromStruct(reflect.TypeOf(fractional{}), fs)
+	if err != nil {
+		t.Fatalf("defineFlagsFromStruct() error = %v", err)
+	}
+	for name, want := range map[string]string{
+		"frac_ratio": "3.14",
+		"frac_scale": "0.5",
+	} {
+		flag := fs.Lookup(name)
+		if flag == nil {
+			t.Fatalf("flag %s was not registered", name)
+		}
+		if flag.DefValue != want {
+			t.Errorf("%s default = %q, want %q", name, flag.DefValue, want)
+		}
+	}
+
+	cfg := MustNewConfig(
+		&fractional{},
+		WithMerge(false),
+		WithArgs(nil),
+	).(*fractional)
+	if cfg.Ratio != 3.14 || cfg.Scale != 0.5 {
+		t.Errorf("NewConfig() = %v, %v, want 3.14, 0.5", cfg.Ratio, cfg.Scale)
+	}
+	c, err := NewConfigFromMap(nil, &fractional{})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	if cfg = c.(*fractional); cfg.Ratio != 3.14 || cfg.Scale != 0.5 {
+		t.Errorf(
+			"NewConfigFromMap() = %v, %v, want 3.14, 0.5",
+			cfg.Ratio,
+			cfg.Scale,
+		)
+	}
+}
+
+// Test a flag name declared twice is reported with both fields
+func TestDuplicateFlagName(t *testing.T) {
+	type duplicated struct {
+		Config
+		DatabaseConfig
+		Host string `type:"string" name:"dbhost"`
+	}
+	_, err := NewConfig(&duplicated{}, WithMerge(false), WithArgs(nil))
+	if !errors.Is(err, ErrDuplicateFlag) {
+		t.Fatalf("NewConfig() error = %v, want ErrDuplicateFlag", err)
+	}
+	want := `duplicate flag name "dbhost": ` +
+		`defined in DatabaseConfig.DBHost and duplicated.Host`
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("NewConfig() error = %v, want %q", err, want)
+	}
+}
+
+// Test type tags the field cannot hold are reported
+func TestTypeTagMismatch(t *testing.T) {
+	type mismatched struct {
+		Config
+		Debug   bool          `type:"string"   name:"mm_debug"   default:""`
+		Retries *uint         `type:"int"      name:"mm_retries"`
+		Timeout time.Duration `type:"int"      name:"mm_timeout" default:"5"`
+		Port    int           `type:"int"      name:"mm_port"    default:"80"`
+	}
+	_, err := NewConfig(&mismatched{}, WithMerge(false), WithArgs(nil))
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("NewConfig() error = %v, want 2 errors", err)
+	}
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("NewConfig() error = %v, want ErrTypeMismatch", err)
+	}
+	for _, want := range []string{
+		`mismatched.Debug: type tag does not match field: bool has type tag "string"`,
+		`mismatched.Retries: type tag does not match field: *uint has type tag "int"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("NewConfig() error = %v, want %q", err, want)
+		}
+	}
+}
+
+// Test int and uint flags take the bit width of their field
+func TestIntFlagWidth(t *testing.T) {
+	type widths struct {
+		Int    int    `type:"int"  name:"w_int"    default:"1"`
+		Int8   int8   `type:"int"  name:"w_int8"   default:"1"`
+		Int16  int16  `type:"int"  name:"w_int16"  default:"1"`
+		Int32  int32  `type:"int"  name:"w_int32"  default:"1"`
+		Int64  int64  `type:"int"  name:"w_int64"  default:"1"`
+		IntPtr *int   `type:"int"  name:"w_intptr"`
+		Uint   uint   `type:"uint" name:"w_uint"   default:"1"`
+		Uint8  uint8  `type:"uint" name:"w_uint8"  default:"1"`
+		Uint16 uint16 `type:"uint" name:"w_uint16" default:"1"`
+		Uint32 uint32 `type:"uint" name:"w_uint32" default:"1"`
+		Uint64 uint64 `type:"uint" name:"w_uint64" default:"1"`
+		Tiny   int8   `type:"int"  name:"w_tiny"   default:"300"`
+	}
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	err := defineFlagsFromStruct(reflect.TypeOf(widths{}), fs)
+	if !errors.Is(err, ErrInvalidDefault) {
+		t.Errorf("out of range default error = %v, want ErrInvalidDefault", err)
+	}
+	for name, want := range map[string]string{
+		"w_int":    "int",
+		"w_int8":   "int8",
+		"w_int16":  "int16",
+		"w_int32":  "int32",
+		"w_int64":  "int64",
+		"w_intptr": "int",
+		"w_uint":   "uint",
+		"w_uint8":  "uint8",
+		"w_uint16": "uint16",
+		"w_uint32": "uint32",
+		"w_uint64": "uint64",
+	} {
+		if got := fs.Lookup(name).Value.Type(); got != want {
+			t.Errorf("flag %s type = %s, want %s", name, got, want)
+		}
+	}
+}
+
+// URLCfg for url field testing
+type URLCfg struct {
+	Config
+	API      url.URL `type:"url" name:"url_api"      default:"https://api.example.com/v1/" desc:"API endpoint" secure:"true"`
+	Callback url.URL `type:"url" name:"url_callback" default:""                            desc:"Callback endpoint"`
+}
+
+// Test url fields parse, strip trailing slashes and round-trip
+func TestURLFields(t *testing.T) {
+	c, err := NewConfigFromMap(
+		map[string]interface{}{"url_callback": "http://hooks.local:8080/cb//"},
+		&URLCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	cfg := c.(*URLCfg)
+	if got := cfg.API.String(); got != "https://api.example.com/v1" {
+		t.Errorf("API = %q, want https://api.example.com/v1", got)
+	}
+	if cfg.Callback.Host != "hooks.local:8080" || cfg.Callback.Path != "/cb" {
+		t.Errorf("Callback = %q, want http://hooks.local:8080/cb", &cfg.Callback)
+	}
+	parsed, err := url.Parse(cfg.Callback.String())
+	if err != nil || *parsed != cfg.Callback {
+		t.Errorf("url.Parse(%q) = %v, %v, want a round trip", &cfg.Callback, parsed, err)
+	}
+	want := map[string]interface{}{
+		"url_api":      "https://api.example.com/v1",
+		"url_callback": "http://hooks.local:8080/cb",
+	}
+	if got := ToMap(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToMap() = %v, want %v", got, want)
+	}
+}
+
+// Test bare hostnames and insecure URLs on secure fields are reported
+func TestURLFieldsInvalid(t *testing.T) {
+	_, err := NewConfigFromMap(
+		map[string]interface{}{
+			"url_api":      "http://api.example.com",
+			"url_callback": "hooks.local",
+		},
+		&URLCfg{},
+	)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("NewConfigFromMap() error = %v, want 2 errors", err)
+	}
+	for _, want := range []string{"does not use https", "has no scheme"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("NewConfigFromMap() error = %v, want %q", err, want)
+		}
+	}
+}
+
+// Test an invalid url default panics when the flag is defined
+func TestURLFieldsInvalidDefault(t *testing.T) {
+	for _, def := range []fieldDef{
+		{Name: "bare_url", Type: "url", Default: "localhost:8080"},
+		{Name: "plain_url", Type: "url", Default: "http://x", Secure: true},
+	} {
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		err := defineFlag(fs, def)
+		if !errors.Is(err, ErrInvalidDefault) {
+			t.Errorf("defineFlag(%s) error = %v, want ErrInvalidDefault",
+				def.Name, err)
+		}
+	}
+}
+
+// LogLevel is an enumeration decoding itself from text
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelError
+)
+
+var logLevelNames = []string{"debug", "info", "error"}
+
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(logLevelNames[l]), nil
+}
+
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(string(text), name) {
+			*l = LogLevel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown level %q", text)
+}
+
+// TextCfg for encoding.TextUnmarshaler field testing
+type TextCfg struct {
+	Config
+	Level    LogLevel  `name:"text_level"    default:"info" desc:"Log level"`
+	Override *LogLevel `name:"text_override"                desc:"Level override"`
+	Tagged   LogLevel  `type:"text" name:"text_tagged" default:"error"`
+}
+
+// Test fields implementing encoding.TextUnmarshaler are set from text
+func TestTextUnmarshalerFields(t *testing.T) {
+	cfg, err := ParseArgs(&TextCfg{}, []string{"--text_override=DEBUG"})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	c := cfg.(*TextCfg)
+	if c.Level != LevelInfo || c.Tagged != LevelError {
+		t.Errorf("Level, Tagged = %v, %v, want info, error", c.Level, c.Tagged)
+	}
+	if c.Override == nil || *c.Override != LevelDebug {
+		t.Errorf("Override = %v, want debug", c.Override)
+	}
+	want := map[string]interface{}{
+		"text_level":    "info",
+		"text_override": "debug",
+		"text_tagged":   "error",
+	}
+	if got := ToMap(c); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToMap() = %v, want %v", got, want)
+	}
+
+	cfg, err = ParseArgs(&TextCfg{}, nil)
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	if c := cfg.(*TextCfg); c.Override != nil {
+		t.Errorf("Override = %v, want nil when unset", *c.Override)
+	}
+}
+
+// Test values rejected by UnmarshalText are reported
+func TestTextUnmarshalerFieldsInvalid(t *testing.T) {
+	_, err := ParseArgs(&TextCfg{}, []string{"--text_level=loud"})
+	if err == nil || !strings.Contains(err.Error(), `unknown level "loud"`) {
+		t.Errorf("ParseArgs() error = %v, want the unmarshal error", err)
+	}
+
+	type badDefault struct {
+		Config
+		Level LogLevel `name:"bad_level" default:"loud"`
+	}
+	_, err = ParseArgs(&badDefault{}, nil)
+	if !errors.Is(err, ErrInvalidDefault) {
+		t.Errorf("ParseArgs() error = %v, want ErrInvalidDefault", err)
+	}
+}
+
+// Benchmark for prefix config creation
+func BenchmarkNewConfigWithPrefix(b *testing.B) {
+	for b.Loop() {
+		_ = NewConfigWithPrefix()
+	}
+}
+
+// Benchmark for nested config creation
+func BenchmarkNewNestedConfig(b *testing.B) {
+	for b.Loop() {
+		_ = NewNestedConfig()
+	}
+}
+
+// Benchmark for all types config creation
+func BenchmarkNewAllTypesConfig(b *testing.B) {
+	for b.Loop() {
+		_ = NewAllTypesConfig()
+	}
+}
+
+// Test ParseArgs parses explicit arguments without global flag state
+func TestParseArgs(t *testing.T) {
+	c, err := ParseArgs(
+		&ConfigWithPrefix{},
+		[]string{"--primary_dbport=5433", "--primary_dbhost=testdb"},
+	)
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	cfg := c.(*ConfigWithPrefix)
+	if cfg.PrimaryDB.DBHost != "testdb" || cfg.PrimaryDB.DBPort != 5433 {
+		t.Errorf(
+			"PrimaryDB = %s:%d, want testdb:5433",
+			cfg.PrimaryDB.DBHost,
+			cfg.PrimaryDB.DBPort,
+		)
+	}
+	if cfg.ReplicaDB.DBPort != 5432 {
+		t.Errorf("ReplicaDB.DBPort = %d, want 5432", cfg.ReplicaDB.DBPort)
+	}
+	if pflag.CommandLine.Lookup("primary_dbport") != nil {
+		t.Error("ParseArgs() registered flags on pflag.CommandLine")
+	}
+}
+
+// Test WithArgs parses the given arguments instead of os.Args
+func TestWithArgs(t *testing.T) {
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app.test", "-test.v", "--primary_dbport=1"}
+
+	c, err := NewConfig(
+		&ConfigWithPrefix{},
+		WithMerge(false),
+		WithArgs([]string{"--primary_dbport=5433"}),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	if port := c.(*ConfigWithPrefix).PrimaryDB.DBPort; port != 5433 {
+		t.Errorf("PrimaryDB.DBPort = %d, want 5433", port)
+	}
+
+	_, err = NewConfig(
+		&ConfigWithPrefix{},
+		WithMerge(false),
+		WithArgs([]string{"--primary_dbport=abc"}),
+	)
+	if err == nil {
+		t.Error("NewConfig() with an invalid flag value should return an error")
+	}
+}
+
+// NestedHasCfg for HasConfig testing
+type NestedHasCfg struct {
+	Config
+	ConfigTest1
+	Services struct {
+		Cache *RedisConfig
+	}
+}
+
+// Test HasConfig searches nested, embedded and pointer fields
+func TestHasConfig(t *testing.T) {
+	cfg := &NestedHasCfg{}
+	for _, target := range []interface{}{
+		MyCustomConfig{},
+		&RedisConfig{},
+		ConfigTest1{},
+	} {
+		if !HasConfig(cfg, target) {
+			t.Errorf("HasConfig(%T) = false, want true", target)
+		}
+	}
+	if HasConfig(cfg, DatabaseConfig{}) {
+		t.Error("HasConfig(DatabaseConfig) = true, want false")
+	}
+	if !HasConfig(&ConfigWithPrefix{}, DatabaseConfig{}) {
+		t.Error("HasConfig(DatabaseConfig) on prefixed config = false")
+	}
+
+	loaded := MustNewConfig(&ConfigTest1{}, WithMerge(false)).(*ConfigTest1)
+	if !loaded.HasConfig(MyCustomConfig{}) {
+		t.Error("Config.HasConfig(MyCustomConfig) = false, want true")
+	}
+}
+
+// Test the deprecated signature still loads the config
+func TestNewConfigLegacy(t *testing.T) {
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app"}
+
+	cfg := NewConfigLegacy(&ConfigWithPrefix{}, false).(*ConfigWithPrefix)
+	if cfg.PrimaryDB.DBPort != 5432 {
+		t.Errorf("PrimaryDB.DBPort = %d, want the default", cfg.PrimaryDB.DBPort)
+	}
+	if pflag.CommandLine.Lookup("primary_dbhost") != nil {
+		t.Error("NewConfigLegacy(c, false) merged into pflag.CommandLine")
+	}
+}
+
+// LegacyCfg is merged into pflag.CommandLine by NewConfigLegacy
+type LegacyCfg struct {
+	Config
+	Field string `type:"string" name:"legacy_field" default:"default" desc:"Field"`
+}
+
+// Test NewConfigLegacy parses the global command line, so flags a program
+// declares on pflag.CommandLine are still set
+func TestNewConfigLegacyGlobalFlags(t *testing.T) {
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app", "--legacy_global=set", "--legacy_field=cli"}
+	if pflag.CommandLine.Lookup("legacy_global") == nil {
+		pflag.String("legacy_global", "", "Flag declared by the program")
+	}
+
+	cfg := NewConfigLegacy(&LegacyCfg{}).(*LegacyCfg)
+	if got, _ := pflag.CommandLine.GetString("legacy_global"); got != "set" {
+		t.Errorf("legacy_global = %q, want %q", got, "set")
+	}
+	if cfg.Field != "cli" {
+		t.Errorf("Field = %q, want %q", cfg.Field, "cli")
+	}
+}
+
+// Test ParseArgs reports unknown flags and missing config files
+func TestParseArgsErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"--no_such_flag=1"},
+		{"--config=/does/not/exist.yaml"},
+	} {
+		if _, err := ParseArgs(&ConfigWithPrefix{}, args); err == nil {
+			t.Errorf("ParseArgs(%q) should return an error", args)
+		}
+	}
+}
+
+// Test NewConfig returns, rather than panics on, unreadable config files
+func TestNewConfigFileErrors(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(bad, []byte("dbhost: [\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{bad, "/does/not/exist.yaml"} {
+		_, err := NewConfig(
+			&ConfigWithPrefix{},
+			WithMerge(false),
+			WithArgs([]string{"--config=" + path}),
+		)
+		if err == nil ||
+			!strings.Contains(err.Error(), "could not read configuration file") {
+			t.Errorf("NewConfig(--config=%s) error = %v, want a read error",
+				path, err)
+		}
+	}
+}
+
+func TestConfigSearchPaths(t *testing.T) {
+	first, second, empty := t.TempDir(), t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(first, "app.yaml"):    "dbhost: first.example.com\n",
+		filepath.Join(second, "app.yaml"):   "dbhost: second.example.com\ndbport: 6000\n",
+		filepath.Join(second, "other.yaml"): "dbhost: other.example.com\n",
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	load := func(args ...string) *RemoteCfg {
+		t.Helper()
+		c, err := NewConfig(
+			&RemoteCfg{},
+			WithMerge(false),
+			WithArgs(args),
+			WithConfigSearchPaths(empty, first, second),
+			WithConfigName("app"),
+			WithConfigType("yaml"),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig() error = %v", err)
+		}
+		return c.(*RemoteCfg)
+	}
+
+	// The first directory holding the file wins
+	cfg := load()
+	if cfg.DBHost != "first.example.com" || cfg.DBPort != 5432 {
+		t.Errorf(
+			"DBHost, DBPort = %q, %d, want first.example.com, 5432",
+			cfg.DBHost,
+			cfg.DBPort,
+		)
+	}
+
+	// An explicit config flag skips the search
+	cfg = load("--config=" + filepath.Join(second, "other.yaml"))
+	if cfg.DBHost != "other.example.com" {
+		t.Errorf("DBHost = %q, want other.example.com", cfg.DBHost)
+	}
+
+	// Finding no file is not an error
+	c, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs(nil),
+		WithConfigSearchPaths(empty),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() without a file error = %v", err)
+	}
+	if host := c.(*RemoteCfg).DBHost; host != "localhost" {
+		t.Errorf("DBHost = %q, want the default", host)
+	}
+}
+
+func TestXDGConfigPaths(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	t.Setenv("HOME", "/home/user")
+	got := XDGConfigPaths("app")
+	want := []string{"/xdg/app", "/home/user/.config/app", "/etc/app"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("XDGConfigPaths() = %q, want %q", got, want)
+	}
+}
+
+func TestConfigFileType(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"json":      `{"dbhost": "json.example.com"}`,
+		"yaml":      "---\ndbhost: yaml.example.com\n",
+		"toml":      "[pool]\nsize = 4\n",
+		"untyped":   "dbhost: untyped.example.com\n",
+		"ambiguous": "# comment\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]string{
+		"json":      "json",
+		"yaml":      "yaml",
+		"toml":      "toml",
+		"untyped":   "",
+		"ambiguous": "",
+		"missing":   "",
+	} {
+		if got := detectConfigType(filepath.Join(dir, name)); got != want {
+			t.Errorf("detectConfigType(%s) = %q, want %q", name, got, want)
+		}
+	}
+
+	load := func(name string, opts ...Option) (*RemoteCfg, error) {
+		opts = append(
+			opts,
+			WithMerge(false),
+			WithArgs([]string{"--config=" + filepath.Join(dir, name)}),
+		)
+		c, err := NewConfig(&RemoteCfg{}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c.(*RemoteCfg), nil
+	}
+	for _, name := range []string{"json", "yaml"} {
+		cfg, err := load(name)
+		if err != nil {
+			t.Fatalf("NewConfig() with a %s file error = %v", name, err)
+		}
+		if want := name + ".example.com"; cfg.DBHost != want {
+			t.Errorf("DBHost = %q, want %q", cfg.DBHost, want)
+		}
+	}
+	cfg, err := load("untyped", WithConfigType("yaml"))
+	if err != nil {
+		t.Fatalf("NewConfig() with WithConfigType error = %v", err)
+	}
+	if cfg.DBHost != "untyped.example.com" {
+		t.Errorf("DBHost = %q, want untyped.example.com", cfg.DBHost)
+	}
+}
+
+// serverSettings is an unexported struct embedded by EmbeddedCfg
+type serverSettings struct {
+	Listen  string `type:"string" name:"emb_listen"  default:":8080" desc:"Listen address"`
+	Workers int    `type:"int"    name:"emb_workers" default:"4"     desc:"Worker count"`
+}
+
+// EmbeddedCfg for anonymous embedding testing
+type EmbeddedCfg struct {
+	Config
+	serverSettings
+	DatabaseConfig `prefix:"emb"`
+	// private is not a config field despite its tags
+	private DatabaseConfig `prefix:"private"`
+}
+
+// Test anonymous embedded structs are traversed and private fields skipped
+func TestAnonymousEmbedding(t *testing.T) {
+	c, err := ParseArgs(&EmbeddedCfg{}, []string{
+		"--emb_workers=8",
+		"--emb_dbhost=db.example.com",
+	})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	cfg := c.(*EmbeddedCfg)
+	if cfg.Listen != ":8080" || cfg.Workers != 8 {
+		t.Errorf(
+			"Listen, Workers = %q, %d, want :8080, 8",
+			cfg.Listen,
+			cfg.Workers,
+		)
+	}
+	if cfg.DBHost != "db.example.com" {
+		t.Errorf("DBHost = %q, want db.example.com", cfg.DBHost)
+	}
+	if cfg.private.DBHost != "" {
+		t.Errorf("private.DBHost = %q, want it left alone", cfg.private.DBHost)
+	}
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	defineFlagsFromStruct(reflect.TypeOf(EmbeddedCfg{}), fs)
+	if fs.Lookup("private_dbhost") != nil {
+		t.Error("flags were registered for a private field")
+	}
+	if fs.Lookup("emb_listen") == nil {
+		t.Error("flags were not registered for an unexported embedded struct")
+	}
+	if got := ToMap(cfg)["emb_workers"]; got != 8 {
+		t.Errorf("ToMap()[emb_workers] = %v, want 8", got)
+	}
+}
+
+// Test programmatic defaults rank between the sources and the default tags
+func TestNewConfigWithDefaults(t *testing.T) {
+	t.Setenv("DBHOST", "env.example.com")
+	c, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--dbuser=flaguser"}),
+		WithDefaults(map[string]interface{}{
+			"dbhost": "computed.example.com",
+			"dbuser": "computed",
+			"dbport": 6543,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg := c.(*RemoteCfg)
+	if cfg.DBHost != "env.example.com" {
+		t.Errorf("DBHost = %q, want the env value", cfg.DBHost)
+	}
+	if cfg.DBUser != "flaguser" {
+		t.Errorf("DBUser = %q, want the flag value", cfg.DBUser)
+	}
+	if cfg.DBPort != 6543 {
+		t.Errorf("DBPort = %d, want the computed default 6543", cfg.DBPort)
+	}
+	if cfg.DBSSL != "disable" {
+		t.Errorf("DBSSL = %q, want the tag default", cfg.DBSSL)
+	}
+
+	_, err = NewConfigWithDefaults(
+		map[string]interface{}{"no_such_key": 1},
+		&RemoteCfg{},
+	)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("NewConfigWithDefaults() error = %v, want ErrUnknownKey", err)
+	}
+}
+
+// Test signed integer fields of every width are populated
+func TestIntFieldKinds(t *testing.T) {
+	type ints struct {
+		Config
+		Int   int   `type:"int" name:"k_int"   default:"1"`
+		Int8  int8  `type:"int" name:"k_int8"  default:"2"`
+		Int16 int16 `type:"int" name:"k_int16" default:"3"`
+		Int32 int32 `type:"int" name:"k_int32" default:"4"`
+		Int64 int64 `type:"int" name:"k_int64" default:"5"`
+	}
+	tests := []struct {
+		name string
+		args []string
+		want [5]int64
+	}{
+		{name: "defaults", want: [5]int64{1, 2, 3, 4, 5}},
+		{
+			name: "flags",
+			args: []string{
+				"--k_int=-10",
+				"--k_int8=-128",
+				"--k_int16=32767",
+				"--k_int32=-2147483648",
+				"--k_int64=9223372036854775807",
+			},
+			want: [5]int64{-10, -128, 32767, -2147483648, 9223372036854775807},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseArgs(&ints{}, tt.args)
+			if err != nil {
+				t.Fatalf("ParseArgs() error = %v", err)
+			}
+			cfg := c.(*ints)
+			got := [5]int64{
+				int64(cfg.Int),
+				int64(cfg.Int8),
+				int64(cfg.Int16),
+				int64(cfg.Int32),
+				cfg.Int64,
+			}
+			if got != tt.want {
+				t.Errorf("ints = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Values too wide for the field are reported rather than wrapped
+	_, err := NewConfigFromMap(
+		map[string]interface{}{"k_int8": 300},
+		&ints{},
+	)
+	if err == nil || !strings.Contains(
+		err.Error(),
+		"k_int8: 300 does not fit in 8 bits",
+	) {
+		t.Errorf("NewConfigFromMap() error = %v, want k_int8 overflow", err)
+	}
+}
+
+func TestMustNewConfig(t *testing.T) {
+	type requiredCfg struct {
+		Config
+		Token string `type:"string" name:"must_token" required:"true"`
+	}
+	cfg := MustNewConfig(
+		&requiredCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--must_token=abc"}),
+	)
+	if got := cfg.(*requiredCfg).Token; got != "abc" {
+		t.Errorf("Token = %q, want %q", got, "abc")
+	}
+
+	defer func() {
+		msg, _ := recover().(string)
+		want := "coil: failed to load config: invalid config: "
+		if !strings.HasPrefix(msg, want) || !strings.Contains(msg, "must_token") {
+			t.Errorf("MustNewConfig() panicked with %q", msg)
+		}
+	}()
+	MustNewConfig(&requiredCfg{}, WithMerge(false), WithArgs(nil))
+	t.Error("MustNewConfig() did not panic")
 }
@@ -0,0 +1,71 @@
+// Package coiltest provides helpers for testing code that depends on coil
+// configs. Configs are populated from explicit values only, so tests never
+// read the environment, os.Args or pflag.CommandLine and can run in
+// parallel
+package coiltest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cvlstack/coil"
+)
+
+// NewTestConfig returns a new config of type T, a pointer to a config
+// struct, populated from its defaults and the overrides keyed by flag
+// name. Unknown keys and validation errors fail the test
+func NewTestConfig[T coil.Configer](
+	t testing.TB,
+	overrides map[string]interface{},
+) T {
+	t.Helper()
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Ptr {
+		t.Fatalf("coiltest: %s is not a pointer to a config struct", typ)
+	}
+	c, err := coil.NewConfigFromMap(
+		overrides,
+		reflect.New(typ.Elem()).Interface().(T),
+	)
+	if err != nil {
+		t.Fatalf("coiltest: populating %s: %v", typ, err)
+	}
+	return c.(T)
+}
+
+// AssertField reports an error if the value of a config key differs from
+// expected. Numeric values are compared after conversion to the field's
+// type, so an untyped constant matches an int64 or uint32 field
+func AssertField(
+	t testing.TB,
+	c coil.Configer,
+	flagName string,
+	expected interface{},
+) {
+	t.Helper()
+	got, err := coil.Value(c, flagName)
+	if err != nil {
+		t.Errorf("coiltest: %v", err)
+		return
+	}
+	want := expected
+	gv, ev := reflect.ValueOf(got), reflect.ValueOf(expected)
+	if expected != nil && gv.Type() != ev.Type() &&
+		isNumber(gv.Kind()) && isNumber(ev.Kind()) {
+		want = ev.Convert(gv.Type()).Interface()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %#v, want %#v", flagName, got, expected)
+	}
+}
+
+// isNumber reports whether k is an integer or float kind
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
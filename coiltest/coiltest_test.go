@@ -0,0 +1,53 @@
+package coiltest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cvlstack/coil"
+)
+
+// AppCfg for helper testing
+type AppCfg struct {
+	coil.Config
+	coil.DatabaseConfig
+	Timeout time.Duration `type:"duration" name:"app_timeout" default:"5s" desc:"Timeout"`
+}
+
+// recorder captures the failures reported through testing.TB
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestNewTestConfig(t *testing.T) {
+	t.Setenv("DBHOST", "from-env")
+	cfg := NewTestConfig[*AppCfg](t, map[string]interface{}{
+		"dbport":      6543,
+		"app_timeout": "1m",
+	})
+	AssertField(t, cfg, "dbhost", "localhost")
+	AssertField(t, cfg, "dbport", 6543)
+	AssertField(t, cfg, "app_timeout", time.Minute)
+	if cfg.DBPort != 6543 {
+		t.Errorf("DBPort = %d, want 6543", cfg.DBPort)
+	}
+}
+
+func TestAssertFieldMismatch(t *testing.T) {
+	cfg := NewTestConfig[*AppCfg](t, nil)
+	r := &recorder{TB: t}
+	AssertField(r, cfg, "dbport", 1)
+	AssertField(r, cfg, "dbhost", 5432)
+	AssertField(r, cfg, "missing", "x")
+	if len(r.errors) != 3 {
+		t.Errorf("AssertField() reported %q, want 3 failures", r.errors)
+	}
+}
@@ -0,0 +1,190 @@
+package coil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// completionFlag describes a flag offered by a completion script
+type completionFlag struct {
+	name    string
+	desc    string
+	isBool  bool
+	isFile  bool
+	choices []string
+}
+
+// completionFlags returns the flags registered by the config, sorted by
+// name, with the values allowed by oneof tags as their choices
+func completionFlags(c Configer) []completionFlag {
+	fs := pflag.NewFlagSet("completion", pflag.ContinueOnError)
+	// Flags with invalid defaults are still registered, and NewConfig
+	// reports them
+	defineFlagsFromStructWithPrefix(
+		reflect.TypeOf(c).Elem(),
+		fs,
+		c.base().prefix,
+		c.base().fieldPrefixes,
+	)
+	defineConfigFlag(fs)
+	choices := make(map[string][]string)
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			choices[def.Name] = def.OneOf
+		},
+	)
+	var flags []completionFlag
+	fs.VisitAll(func(f *pflag.Flag) {
+		flags = append(flags, completionFlag{
+			name:    f.Name,
+			desc:    f.Usage,
+			isBool:  f.Value.Type() == "bool",
+			isFile:  f.Name == "config" || f.Name == "env_file",
+			choices: choices[f.Name],
+		})
+	})
+	return flags
+}
+
+// programName returns the name the completion scripts are registered for
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// nonIdent matches the characters not allowed in a shell function name
+var nonIdent = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// CompletionBash generates a bash completion script for the flags of the
+// config, completing the values of oneof fields and file paths for the
+// config and env_file flags
+func CompletionBash(c Configer) string {
+	prog := programName()
+	fn := "_" + nonIdent.ReplaceAllString(prog, "_") + "_completion"
+	var names []string
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("    local prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("    case \"$prev\" in\n")
+	for _, f := range completionFlags(c) {
+		names = append(names, "--"+f.name)
+		switch {
+		case len(f.choices) > 0:
+			fmt.Fprintf(
+				&b,
+				"        --%s)\n            COMPREPLY=($(compgen -W %s -- \"$cur\"))\n            return ;;\n",
+				f.name,
+				shellQuote(strings.Join(f.choices, " ")),
+			)
+		case f.isFile:
+			fmt.Fprintf(
+				&b,
+				"        --%s)\n            COMPREPLY=($(compgen -f -- \"$cur\"))\n            return ;;\n",
+				f.name,
+			)
+		}
+	}
+	b.WriteString("    esac\n")
+	fmt.Fprintf(
+		&b,
+		"    COMPREPLY=($(compgen -W %s -- \"$cur\"))\n}\n",
+		shellQuote(strings.Join(names, " ")),
+	)
+	fmt.Fprintf(&b, "complete -F %s %s\n", fn, prog)
+	return b.String()
+}
+
+// CompletionZsh generates a zsh completion script for the flags of the
+// config, with their descriptions and the values of oneof fields
+func CompletionZsh(c Configer) string {
+	prog := programName()
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n\n_arguments \\\n", prog)
+	flags := completionFlags(c)
+	for i, f := range flags {
+		spec := "--" + f.name + "[" + zshEscape(f.desc) + "]"
+		switch {
+		case f.isBool:
+		case len(f.choices) > 0:
+			spec += ":" + f.name + ":(" + strings.Join(f.choices, " ") + ")"
+		case f.isFile:
+			spec += ":" + f.name + ":_files"
+		default:
+			spec += ":" + f.name + ":"
+		}
+		b.WriteString("  " + shellQuote(spec))
+		if i < len(flags)-1 {
+			b.WriteString(" \\")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// CompletionFish generates a fish completion script for the flags of the
+// config, with their descriptions and the values of oneof fields
+func CompletionFish(c Configer) string {
+	prog := programName()
+	var b strings.Builder
+	for _, f := range completionFlags(c) {
+		fmt.Fprintf(&b, "complete -c %s -l %s", prog, f.name)
+		if f.desc != "" {
+			fmt.Fprintf(&b, " -d %s", shellQuote(f.desc))
+		}
+		switch {
+		case f.isBool:
+		case len(f.choices) > 0:
+			fmt.Fprintf(&b, " -x -a %s", shellQuote(strings.Join(f.choices, " ")))
+		case f.isFile:
+			b.WriteString(" -r -F")
+		default:
+			b.WriteString(" -x")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// HandleCompletion writes the completion script requested by a hidden
+// --completion-bash, --completion-zsh or --completion-fish argument to w
+// and reports whether one was found. It is meant to run before the config
+// is loaded, e.g.
+//
+//	if coil.HandleCompletion(&AppConfig{}, os.Args[1:], os.Stdout) {
+//		os.Exit(0)
+//	}
+func HandleCompletion(c Configer, args []string, w io.Writer) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--completion-bash":
+			io.WriteString(w, CompletionBash(c))
+		case "--completion-zsh":
+			io.WriteString(w, CompletionZsh(c))
+		case "--completion-fish":
+			io.WriteString(w, CompletionFish(c))
+		default:
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// shellQuote wraps s in single quotes for bash, zsh and fish
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// zshEscape escapes the characters _arguments treats specially in a
+// description
+func zshEscape(s string) string {
+	return strings.NewReplacer(`[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(s)
+}
@@ -0,0 +1,134 @@
+package coil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// CompletionCfg for completion script testing
+type CompletionCfg struct {
+	Config
+	Mode    string `type:"string" name:"mode"    default:"fast" desc:"Run mode" oneof:"fast safe"`
+	Verbose bool   `type:"bool"   name:"verbose" default:"false" desc:"Verbose output"`
+}
+
+func TestCompletionBash(t *testing.T) {
+	script := CompletionBash(&CompletionCfg{})
+	for _, want := range []string{
+		"--mode)",
+		"compgen -W 'fast safe'",
+		"--config)",
+		"--verbose",
+		"complete -F _",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("CompletionBash() missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestCompletionZsh(t *testing.T) {
+	script := CompletionZsh(&CompletionCfg{})
+	for _, want := range []string{
+		"#compdef ",
+		"'--mode[Run mode]:mode:(fast safe)'",
+		"'--verbose[Verbose output]'",
+		"'--config[Path for a configuration file to load]:config:_files'",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("CompletionZsh() missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestCompletionFish(t *testing.T) {
+	script := CompletionFish(&CompletionCfg{})
+	for _, want := range []string{
+		"-l mode -d 'Run mode' -x -a 'fast safe'",
+		"-l verbose -d 'Verbose output'\n",
+		"-l config -d 'Path for a configuration file to load' -r -F",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("CompletionFish() missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestHandleCompletion(t *testing.T) {
+	var b strings.Builder
+	if !HandleCompletion(&CompletionCfg{}, []string{"--completion-fish"}, &b) {
+		t.Fatal("HandleCompletion() = false, want true")
+	}
+	if b.String() != CompletionFish(&CompletionCfg{}) {
+		t.Errorf("HandleCompletion() wrote %q", b.String())
+	}
+	b.Reset()
+	if HandleCompletion(&CompletionCfg{}, []string{"--mode=safe"}, &b) {
+		t.Error("HandleCompletion() without a completion flag = true")
+	}
+	if b.Len() != 0 {
+		t.Errorf("HandleCompletion() wrote %q, want nothing", b.String())
+	}
+}
+
+func TestOneOfValidation(t *testing.T) {
+	// The flag rejects the value as soon as it is parsed
+	_, err := NewConfig(
+		&CompletionCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--mode=slow"}),
+	)
+	msg := `"slow" is not one of fast, safe`
+	if err == nil || !strings.Contains(err.Error(), msg) {
+		t.Errorf("NewConfig() error = %v", err)
+	}
+
+	// Other sources are caught by validation
+	t.Setenv("MODE", "slow")
+	_, err = NewConfig(&CompletionCfg{}, WithMerge(false), WithArgs(nil))
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("NewConfig() error = %v, want ValidationErrors", err)
+	}
+	if !strings.Contains(err.Error(), `mode: "slow" is not one of fast, safe`) {
+		t.Errorf("NewConfig() error = %v", err)
+	}
+
+	c, err := NewConfig(
+		&CompletionCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--mode=safe"}),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	if mode := c.(*CompletionCfg).Mode; mode != "safe" {
+		t.Errorf("Mode = %q, want safe", mode)
+	}
+}
+
+func TestOneOfDefault(t *testing.T) {
+	type badDefault struct {
+		Config
+		Mode string `type:"string" name:"bad_mode" default:"slow" oneof:"fast safe"`
+	}
+	_, err := NewConfig(&badDefault{}, WithMerge(false), WithArgs(nil))
+	if !errors.Is(err, ErrInvalidDefault) {
+		t.Errorf("NewConfig() error = %v, want ErrInvalidDefault", err)
+	}
+
+	type logCfg struct {
+		Config
+		LogConfig
+	}
+	_, err = NewConfig(
+		&logCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--log_level=verbose"}),
+	)
+	allowed := "trace, debug, info, warn, error, fatal"
+	if err == nil || !strings.Contains(err.Error(), allowed) {
+		t.Errorf("NewConfig() error = %v", err)
+	}
+}
@@ -1,6 +1,34 @@
 package coil
 
-import "time"
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
 
 // APIServiceConfig is a global struct passed to all services
 type APIServiceConfig struct {
@@ -9,31 +37,420 @@ type APIServiceConfig struct {
 	Build   string        `type:"string"   name:"build"   default:"UNSPECIFIED" desc:"Build version"`
 	Host    string        `type:"string"   name:"host"    default:"localhost"   desc:"Server hostname to bind to"`
 	URL     string        `type:"string"   name:"api_url" default:""            desc:"The URL to the API"`
-	Port    int           `type:"int"      name:"port"    default:"80"          desc:"Server port to bind to"`
-	Timeout time.Duration `type:"duration" name:"timeout" default:"15s"         desc:"Timeout for any connection i.e. 10s"`
+	Port    int           `type:"int"      name:"port"    default:"80"          desc:"Server port to bind to" min:"0" max:"65535"`
+	Timeout time.Duration `type:"duration" name:"timeout" default:"15s"         desc:"Timeout for any connection i.e. 10s" min:"1ns"`
+}
+
+// ListenAddr returns the host:port address to bind the server to, suitable
+// for net.Listen or http.ListenAndServe. An empty host listens on all
+// interfaces and a zero port picks a random one. A host that already
+// carries a port is returned as is
+func (c APIServiceConfig) ListenAddr() string {
+	if _, _, err := net.SplitHostPort(c.Host); err == nil {
+		return c.Host
+	}
+	host := c.Host
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(c.Port))
+}
+
+// BaseURL returns the URL the API is reachable at, without a trailing slash.
+// An explicit URL takes precedence over Host and Port, even when it names a
+// different port. Otherwise the URL is http://host:port, assembled from Host
+// and Port or the port already carried by Host, and wildcard listen hosts
+// are replaced by localhost. A Port of 0 asks the OS for a random port that
+// BaseURL cannot know, so callers listening on port 0 must set Port to the
+// bound port first; the URL otherwise carries port 0
+func (c APIServiceConfig) BaseURL() string {
+	if c.URL != "" {
+		return strings.TrimRight(c.URL, "/")
+	}
+	host, port := c.Host, strconv.Itoa(c.Port)
+	if h, p, err := net.SplitHostPort(c.Host); err == nil {
+		host, port = h, p
+	}
+	switch host {
+	case "", "0.0.0.0", "::":
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// RateLimitConfig represents a composable struct for request rate limiting
+type RateLimitConfig struct {
+	Enabled        bool    `type:"bool"    name:"ratelimit_enabled"    default:"false" desc:"Limit the request rate"`
+	RPS            float64 `type:"float64" name:"ratelimit_rps"        default:"10"    desc:"Requests allowed per second"`
+	Burst          int     `type:"int"     name:"ratelimit_burst"      default:"20"    desc:"Requests allowed in a single burst"`
+	PerClientLimit bool    `type:"bool"    name:"ratelimit_per_client" default:"false" desc:"Apply the limit to each client rather than globally"`
+
+	limitersMu sync.Mutex
+	limiters   map[string]*clientLimiter
+	lastSweep  time.Time
+}
+
+// limiterIdleTTL is how long LimiterFor keeps the limiter of a client that
+// makes no requests
+const limiterIdleTTL = 10 * time.Minute
+
+// clientLimiter is a cached limiter and when it was last handed out
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// Limiter returns a new limiter for the configured rate and burst, which
+// allows every request when rate limiting is disabled
+func (c *RateLimitConfig) Limiter() *rate.Limiter {
+	return rate.NewLimiter(c.limit())
+}
+
+// limit returns the configured rate and burst, or an infinite rate when
+// rate limiting is disabled
+func (c *RateLimitConfig) limit() (rate.Limit, int) {
+	if !c.Enabled {
+		return rate.Inf, 0
+	}
+	return rate.Limit(c.RPS), c.Burst
+}
+
+// LimiterFor returns the limiter shared by requests from a client. Each
+// client gets its own limiter when PerClientLimit is set, otherwise all
+// clients share one. Limiters are cached and follow the configured rate and
+// burst across reloads. A client's limiter is dropped after ten minutes
+// without requests, so the cache does not grow with every client seen
+func (c *RateLimitConfig) LimiterFor(clientID string) *rate.Limiter {
+	if !c.PerClientLimit {
+		clientID = ""
+	}
+	now := time.Now()
+	limit, burst := c.limit()
+	c.limitersMu.Lock()
+	defer c.limitersMu.Unlock()
+	if now.Sub(c.lastSweep) >= limiterIdleTTL {
+		for id, cl := range c.limiters {
+			if now.Sub(cl.lastUsed) >= limiterIdleTTL {
+				delete(c.limiters, id)
+			}
+		}
+		c.lastSweep = now
+	}
+	cl, ok := c.limiters[clientID]
+	if !ok {
+		if c.limiters == nil {
+			c.limiters = make(map[string]*clientLimiter)
+		}
+		cl = &clientLimiter{limiter: rate.NewLimiter(limit, burst)}
+		c.limiters[clientID] = cl
+	}
+	if cl.limiter.Limit() != limit {
+		cl.limiter.SetLimitAt(now, limit)
+	}
+	if cl.limiter.Burst() != burst {
+		cl.limiter.SetBurstAt(now, burst)
+	}
+	cl.lastUsed = now
+	return cl.limiter
+}
+
+// MetricsConfig represents a composable struct for a Prometheus endpoint
+type MetricsConfig struct {
+	MetricsEnabled   bool   `type:"bool"   name:"metrics_enabled"   default:"true"     desc:"Expose Prometheus metrics"`
+	MetricsHost      string `type:"string" name:"metrics_host"      default:"0.0.0.0"  desc:"Metrics server hostname to bind to"`
+	MetricsPort      int    `type:"int"    name:"metrics_port"      default:"9090"     desc:"Metrics server port to bind to" min:"0" max:"65535"`
+	MetricsPath      string `type:"string" name:"metrics_path"      default:"/metrics" desc:"HTTP path serving the metrics"`
+	MetricsNamespace string `type:"string" name:"metrics_namespace" default:""         desc:"Namespace prefixed to metric names"`
+	MetricsSubsystem string `type:"string" name:"metrics_subsystem" default:""         desc:"Subsystem prefixed to metric names"`
+
+	registryOnce sync.Once
+	registry     *prometheus.Registry
+}
+
+// ListenAddr returns the address the metrics server should bind to
+func (c *MetricsConfig) ListenAddr() string {
+	return net.JoinHostPort(c.MetricsHost, strconv.Itoa(c.MetricsPort))
+}
+
+// NewRegistry returns the registry of the config, created with the Go and
+// process collectors on first use. It is safe for concurrent use
+func (c *MetricsConfig) NewRegistry() *prometheus.Registry {
+	c.registryOnce.Do(func() {
+		c.registry = prometheus.NewRegistry()
+		c.registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	})
+	return c.registry
+}
+
+// Registerer returns a registerer for the registry that prefixes metric
+// names with the namespace and subsystem
+func (c *MetricsConfig) Registerer() prometheus.Registerer {
+	var parts []string
+	for _, part := range []string{c.MetricsNamespace, c.MetricsSubsystem} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return c.NewRegistry()
+	}
+	return prometheus.WrapRegistererWithPrefix(
+		strings.Join(parts, "_")+"_",
+		c.NewRegistry(),
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format, or
+// responds 404 when metrics are disabled
+func (c *MetricsConfig) Handler() http.Handler {
+	if !c.MetricsEnabled {
+		return http.NotFoundHandler()
+	}
+	reg := c.NewRegistry()
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
 }
 
 // DatabaseConfig represents a composable struct for db connections
 type DatabaseConfig struct {
-	DBHost  string `type:"string" name:"dbhost"  default:"localhost" desc:"Database hostname"`
-	DBUser  string `type:"string" name:"dbuser"  default:""          desc:"Database username"`
-	DBName  string `type:"string" name:"dbname"  default:""          desc:"Database name"`
-	DBPass  string `type:"string" name:"dbpass"  default:""          desc:"Database password"`
-	DBSSL   string `type:"string" name:"dbssl"   default:"disable"   desc:"Database SSL mode"`
-	DBDebug bool   `type:"string" name:"dbdebug" default:""          desc:"Enable database debug mode"`
-	DBPort  int    `type:"int"    name:"dbport"  default:"5432"      desc:"Database port number"`
+	DBDriver        string        `type:"string"   name:"dbdriver"       default:"postgres"  desc:"Database driver (postgres, mysql, sqlserver, sqlite)"`
+	DBHost          string        `type:"string"   name:"dbhost"         default:"localhost" desc:"Database hostname"`
+	DBUser          string        `type:"string"   name:"dbuser"         default:""          desc:"Database username"`
+	DBName          string        `type:"string"   name:"dbname"         default:""          desc:"Database name"`
+	DBPass          string        `type:"string"   name:"dbpass"         default:""          desc:"Database password"                                    secret:"true"`
+	DBSSL           string        `type:"string"   name:"dbssl"          default:"disable"   desc:"Database SSL mode"`
+	DBDebug         bool          `type:"bool"     name:"dbdebug"        default:"false"     desc:"Enable database debug mode"`
+	DBPort          int           `type:"int"      name:"dbport"         default:"5432"      desc:"Database port number" min:"1" max:"65535"`
+	DBPingOnConnect bool          `type:"bool"     name:"dbping"         default:"false"     desc:"Ping the database when opening a connection"`
+	MaxOpenConns    int           `type:"int"      name:"dbmaxopen"      default:"25"        desc:"Maximum number of open database connections"`
+	MaxIdleConns    int           `type:"int"      name:"dbmaxidle"      default:"5"         desc:"Maximum number of idle database connections"`
+	ConnMaxLifetime time.Duration `type:"duration" name:"dbconnlifetime" default:"5m"        desc:"Maximum time a database connection may be reused"`
+}
+
+// WithDriver returns a copy of the config using the given driver, which
+// controls the DSN format
+func (c DatabaseConfig) WithDriver(driver string) DatabaseConfig {
+	c.DBDriver = driver
+	return c
+}
+
+// DSN returns the connection string for the configured driver. Unknown
+// drivers use the Postgres key/value format
+func (c DatabaseConfig) DSN() string {
+	switch c.DBDriver {
+	case "mysql":
+		dsn := c.DBUser
+		if c.DBPass != "" {
+			dsn += ":" + c.DBPass
+		}
+		dsn += fmt.Sprintf(
+			"@tcp(%s)/%s",
+			net.JoinHostPort(c.DBHost, strconv.Itoa(c.DBPort)),
+			c.DBName,
+		)
+		switch c.DBSSL {
+		case "", "disable":
+			return dsn
+		case "require", "verify-ca", "verify-full":
+			return dsn + "?tls=true"
+		default:
+			return dsn + "?tls=" + url.QueryEscape(c.DBSSL)
+		}
+	case "sqlserver":
+		u := url.URL{
+			Scheme:   "sqlserver",
+			User:     url.UserPassword(c.DBUser, c.DBPass),
+			Host:     net.JoinHostPort(c.DBHost, strconv.Itoa(c.DBPort)),
+			RawQuery: url.Values{"database": {c.DBName}}.Encode(),
+		}
+		return u.String()
+	case "sqlite", "sqlite3":
+		return c.DBName
+	default:
+		pairs := []string{
+			"host=" + quoteDSNValue(c.DBHost),
+			"port=" + strconv.Itoa(c.DBPort),
+		}
+		for _, kv := range [][2]string{
+			{"user", c.DBUser},
+			{"dbname", c.DBName},
+			{"password", c.DBPass},
+			{"sslmode", c.DBSSL},
+		} {
+			if kv[1] != "" {
+				pairs = append(pairs, kv[0]+"="+quoteDSNValue(kv[1]))
+			}
+		}
+		return strings.Join(pairs, " ")
+	}
+}
+
+// Open opens a connection pool with the given driver, which must be
+// registered with database/sql. An empty driver uses DBDriver. The pool
+// is sized by MaxOpenConns, MaxIdleConns and ConnMaxLifetime. When
+// DBPingOnConnect is set the connection is verified before returning
+func (c DatabaseConfig) Open(driver string) (*sql.DB, error) {
+	if driver != "" {
+		c = c.WithDriver(driver)
+	}
+	db, err := sql.Open(c.DBDriver, c.DSN())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	if c.DBPingOnConnect {
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// quoteDSNValue quotes a Postgres key/value DSN value when required
+func quoteDSNValue(val string) string {
+	if val != "" && !strings.ContainsAny(val, ` '\`) {
+		return val
+	}
+	val = strings.ReplaceAll(val, `\`, `\\`)
+	return "'" + strings.ReplaceAll(val, "'", `\'`) + "'"
+}
+
+// GRPCConfig represents a composable struct for gRPC client connections
+type GRPCConfig struct {
+	GRPCHost              string        `type:"string"   name:"grpc_host"                default:"localhost" desc:"gRPC server hostname"`
+	GRPCPort              int           `type:"int"      name:"grpc_port"                default:"50051"     desc:"gRPC server port number" min:"1" max:"65535"`
+	GRPCTLS               bool          `type:"bool"     name:"grpc_tls"                 default:"false"     desc:"Connect using TLS"`
+	GRPCServerName        string        `type:"string"   name:"grpc_server_name"         default:""          desc:"Server name to verify when using TLS, defaults to the host"`
+	GRPCKeepaliveTime     time.Duration `type:"duration" name:"grpc_keepalive_time"      default:"30s"       desc:"Interval between keepalive pings when idle"`
+	GRPCKeepaliveTimeout  time.Duration `type:"duration" name:"grpc_keepalive_timeout"   default:"10s"       desc:"Time to wait for a keepalive ping ack"`
+	GRPCKeepaliveNoStream bool          `type:"bool"     name:"grpc_keepalive_no_stream" default:"false"     desc:"Send keepalive pings without active streams"`
+	GRPCMaxRecvMsgSize    int           `type:"int"      name:"grpc_max_recv_msg_size"   default:"4194304"   desc:"Maximum message size in bytes the client can receive"`
+	GRPCMaxSendMsgSize    int           `type:"int"      name:"grpc_max_send_msg_size"   default:"4194304"   desc:"Maximum message size in bytes the client can send"`
+	GRPCBackoffBaseDelay  time.Duration `type:"duration" name:"grpc_backoff_base_delay"  default:"1s"        desc:"Delay before the first reconnect attempt"`
+	GRPCBackoffMaxDelay   time.Duration `type:"duration" name:"grpc_backoff_max_delay"   default:"2m"        desc:"Upper bound on the reconnect delay"`
+	GRPCBackoffMultiplier float64       `type:"float64"  name:"grpc_backoff_multiplier"  default:"1.6"       desc:"Factor applied to the delay after each failed attempt"`
+	GRPCMinConnectTimeout time.Duration `type:"duration" name:"grpc_min_connect_timeout" default:"20s"       desc:"Minimum time to allow a connection attempt"`
+}
+
+// Target returns the host:port address to dial
+func (c GRPCConfig) Target() string {
+	return net.JoinHostPort(c.GRPCHost, strconv.Itoa(c.GRPCPort))
+}
+
+// DialOptions returns the dial options for the config, leaving the choice
+// of dialer to the caller
+func (c GRPCConfig) DialOptions() []grpc.DialOption {
+	creds := insecure.NewCredentials()
+	if c.GRPCTLS {
+		creds = credentials.NewTLS(&tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: c.GRPCServerName,
+		})
+	}
+	backoffCfg := backoff.DefaultConfig
+	backoffCfg.BaseDelay = c.GRPCBackoffBaseDelay
+	backoffCfg.MaxDelay = c.GRPCBackoffMaxDelay
+	backoffCfg.Multiplier = c.GRPCBackoffMultiplier
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                c.GRPCKeepaliveTime,
+			Timeout:             c.GRPCKeepaliveTimeout,
+			PermitWithoutStream: c.GRPCKeepaliveNoStream,
+		}),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(c.GRPCMaxRecvMsgSize),
+			grpc.MaxCallSendMsgSize(c.GRPCMaxSendMsgSize),
+		),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoffCfg,
+			MinConnectTimeout: c.GRPCMinConnectTimeout,
+		}),
+	}
+}
+
+// RedisConfig represents a composable struct for redis connections
+type RedisConfig struct {
+	RedisHost        string        `type:"string"   name:"redis_host"         default:"localhost" desc:"Redis hostname"`
+	RedisPort        int           `type:"int"      name:"redis_port"         default:"6379"      desc:"Redis port number" min:"1" max:"65535"`
+	RedisPass        string        `type:"string"   name:"redis_pass"         default:""          desc:"Redis password"                secret:"true"`
+	RedisDB          int           `type:"int"      name:"redis_db"           default:"0"         desc:"Redis database number"`
+	RedisMaxRetries  int           `type:"int"      name:"redis_max_retries"  default:"3"         desc:"Maximum number of command retries"`
+	RedisPoolSize    int           `type:"int"      name:"redis_pool_size"    default:"10"        desc:"Maximum number of socket connections"`
+	RedisDialTimeout time.Duration `type:"duration" name:"redis_dial_timeout" default:"5s"        desc:"Timeout for establishing new connections"`
+}
+
+// Options returns the go-redis client options for the config
+func (c RedisConfig) Options() *redis.Options {
+	return &redis.Options{
+		Addr:        net.JoinHostPort(c.RedisHost, strconv.Itoa(c.RedisPort)),
+		Password:    c.RedisPass,
+		DB:          c.RedisDB,
+		MaxRetries:  c.RedisMaxRetries,
+		PoolSize:    c.RedisPoolSize,
+		DialTimeout: c.RedisDialTimeout,
+	}
+}
+
+// NewClient returns a redis client that has answered a ping. The ping is
+// bounded by RedisDialTimeout when it is set
+func (c RedisConfig) NewClient() (*redis.Client, error) {
+	client := redis.NewClient(c.Options())
+	ctx := context.Background()
+	if c.RedisDialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RedisDialTimeout)
+		defer cancel()
+	}
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// CacheConfig represents a composable struct for an in-memory or Redis
+// backed cache. The Redis fields are only used by the redis backend but
+// are always parsed, so a config file can set both backends up front
+type CacheConfig struct {
+	Backend        string        `type:"string"   name:"cache_backend"         default:"memory"         desc:"Cache backend (memory, redis)"                           oneof:"memory redis"`
+	TTL            time.Duration `type:"duration" name:"cache_ttl"             default:"5m"             desc:"Time entries live in the cache"`
+	MaxEntries     int           `type:"int"      name:"cache_max_entries"     default:"10000"          desc:"Maximum number of cached entries, 0 for no limit"`
+	EvictionPolicy string        `type:"string"   name:"cache_eviction_policy" default:"lru"            desc:"Eviction policy when the cache is full (lru, lfu, fifo)"`
+	RedisAddr      string        `type:"string"   name:"cache_redis_addr"      default:"localhost:6379" desc:"Redis address, redis backend only"`
+	RedisPassword  string        `type:"string"   name:"cache_redis_password"  default:""               desc:"Redis password, redis backend only"                       secret:"true"`
+	RedisDB        int           `type:"int"      name:"cache_redis_db"        default:"0"              desc:"Redis database number, redis backend only"`
+}
+
+// IsRedis reports whether the cache is backed by Redis
+func (c CacheConfig) IsRedis() bool {
+	return strings.EqualFold(c.Backend, "redis")
+}
+
+// RedisOptions returns the go-redis client options for the redis backend
+func (c CacheConfig) RedisOptions() *redis.Options {
+	return &redis.Options{
+		Addr:     c.RedisAddr,
+		Password: c.RedisPassword,
+		DB:       c.RedisDB,
+	}
 }
 
 // LogConfig represents a composable struct for logging
 type LogConfig struct {
 	// Core logging settings
-	Level  string `type:"string" name:"log_level"  default:"info" desc:"Log level (trace, debug, info, warn, error, fatal)"`
+	Level  string `type:"string" name:"log_level"  default:"info" desc:"Log level (trace, debug, info, warn, error, fatal)" oneof:"trace debug info warn error fatal"`
 	Format string `type:"string" name:"log_format" default:"json" desc:"Log format (json, text, logfmt)"`
 
 	// Output configuration
 	Output     string `type:"string" name:"log_output"      default:"stdout"         desc:"Log output destination (stdout, stderr, file)"`
 	FilePath   string `type:"string" name:"log_file_path"   default:"./logs/app.log" desc:"Path to log file when output is 'file'"`
-	MaxSize    int    `type:"int"    name:"log_max_size"    default:"100"            desc:"Maximum size in megabytes before rotation"`
+	MaxSize    int    `type:"int"    name:"log_max_size"    default:"100"            desc:"Maximum size in megabytes before rotation" min:"1"`
 	MaxBackups int    `type:"int"    name:"log_max_backups" default:"3"              desc:"Maximum number of old log files to retain"`
 	MaxAge     int    `type:"int"    name:"log_max_age"     default:"28"             desc:"Maximum number of days to retain old log files"`
 	Compress   bool   `type:"bool"   name:"log_compress"    default:"false"          desc:"Whether to compress rotated log files"`
@@ -43,4 +460,184 @@ type LogConfig struct {
 	ServiceName  string `type:"string" name:"log_service_name"  default:"" desc:"Service name to include in logs"`
 	Environment  string `type:"string" name:"log_environment"   default:"" desc:"Environment name (dev, staging, prod)"`
 	InstanceID   string `type:"string" name:"log_instance_id"   default:"" desc:"Instance/container ID to include in logs"`
+
+	// Caller configuration
+	AddSource  bool `type:"bool" name:"log_add_source"  default:"false" desc:"Include the file and line of the caller in logs"`
+	CallerSkip int  `type:"int"  name:"log_caller_skip" default:"0"     desc:"Extra stack frames to skip when logging through a wrapper" min:"0"`
+
+	// out replaces the destination selected by Output, see WithWriter
+	out io.Writer
+}
+
+// TLSConfig represents a composable struct for TLS and mutual TLS endpoints
+type TLSConfig struct {
+	CertFile     string   `type:"string"   name:"tls_cert_file"     default:""     desc:"Path to the PEM encoded certificate"`
+	KeyFile      string   `type:"string"   name:"tls_key_file"      default:""     desc:"Path to the PEM encoded private key"`
+	CAFile       string   `type:"string"   name:"tls_ca_file"       default:""     desc:"Path to the PEM encoded CA bundle used to verify peers"`
+	VerifyPeer   bool     `type:"bool"     name:"tls_verify_peer"   default:"true" desc:"Verify the certificate presented by the peer"`
+	MinVersion   string   `type:"string"   name:"tls_min_version"   default:"1.2"  desc:"Minimum TLS version (1.0, 1.1, 1.2, 1.3)"`
+	CipherSuites []string `type:"[]string" name:"tls_cipher_suites" default:""     desc:"Allowed cipher suite names, empty for Go's defaults"`
+}
+
+// tlsVersions maps the accepted tls_min_version values to their constants
+var tlsVersions = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Build returns a tls.Config usable by both clients and servers. The CA
+// bundle verifies server certificates and, when VerifyPeer is set, client
+// certificates too
+func (c TLSConfig) Build() (*tls.Config, error) {
+	version, ok := tlsVersions[c.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS version %q", c.MinVersion)
+	}
+	cfg := &tls.Config{
+		MinVersion:         version,
+		InsecureSkipVerify: !c.VerifyPeer,
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		cfg.RootCAs = pool
+		cfg.ClientCAs = pool
+		if c.VerifyPeer {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	for _, name := range c.CipherSuites {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		cfg.CipherSuites = append(cfg.CipherSuites, id)
+	}
+	return cfg, nil
+}
+
+// cipherSuiteID looks up a cipher suite by its standard name
+func cipherSuiteID(name string) (uint16, bool) {
+	suites := append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+	for _, suite := range suites {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// OAuthConfig represents a composable struct for OAuth2 clients using the
+// client credentials or authorization code flows
+type OAuthConfig struct {
+	OAuthClientID     string   `type:"string"   name:"oauth_client_id"     default:"" desc:"OAuth2 client ID"`
+	OAuthClientSecret string   `type:"string"   name:"oauth_client_secret" default:"" desc:"OAuth2 client secret"                                    secret:"true"`
+	OAuthTokenURL     string   `type:"string"   name:"oauth_token_url"     default:"" desc:"Token endpoint URL"`
+	OAuthAuthURL      string   `type:"string"   name:"oauth_auth_url"      default:"" desc:"Authorization endpoint URL for the authorization code flow"`
+	OAuthScopes       []string `type:"[]string" name:"oauth_scopes"        default:"" desc:"Scopes to request"`
+	OAuthRedirectURL  string   `type:"string"   name:"oauth_redirect_url"  default:"" desc:"Redirect URL for the authorization code flow"`
+	OAuthAudience     string   `type:"string"   name:"oauth_audience"      default:"" desc:"Audience of the requested tokens"`
+}
+
+// TokenSource returns a client credentials token source for service to
+// service calls. Tokens are fetched with ctx and cached until they expire
+func (c OAuthConfig) TokenSource(
+	ctx context.Context,
+) (oauth2.TokenSource, error) {
+	if err := requireSet([][2]string{
+		{"oauth_client_id", c.OAuthClientID},
+		{"oauth_client_secret", c.OAuthClientSecret},
+		{"oauth_token_url", c.OAuthTokenURL},
+	}); err != nil {
+		return nil, err
+	}
+	cfg := &clientcredentials.Config{
+		ClientID:     c.OAuthClientID,
+		ClientSecret: c.OAuthClientSecret,
+		TokenURL:     c.OAuthTokenURL,
+		Scopes:       c.scopes(),
+	}
+	if c.OAuthAudience != "" {
+		cfg.EndpointParams = url.Values{"audience": {c.OAuthAudience}}
+	}
+	return cfg.TokenSource(ctx), nil
+}
+
+// OAuth2Config returns the oauth2 config for the authorization code flow.
+// It is not named Config to avoid clashing with the embedded coil.Config.
+// Pass AuthCodeOptions to AuthCodeURL to request the audience
+func (c OAuthConfig) OAuth2Config() (*oauth2.Config, error) {
+	if err := requireSet([][2]string{
+		{"oauth_client_id", c.OAuthClientID},
+		{"oauth_auth_url", c.OAuthAuthURL},
+		{"oauth_token_url", c.OAuthTokenURL},
+		{"oauth_redirect_url", c.OAuthRedirectURL},
+	}); err != nil {
+		return nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     c.OAuthClientID,
+		ClientSecret: c.OAuthClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  c.OAuthAuthURL,
+			TokenURL: c.OAuthTokenURL,
+		},
+		RedirectURL: c.OAuthRedirectURL,
+		Scopes:      c.scopes(),
+	}, nil
+}
+
+// AuthCodeOptions returns the options requesting the configured audience
+// from AuthCodeURL, if any
+func (c OAuthConfig) AuthCodeOptions() []oauth2.AuthCodeOption {
+	if c.OAuthAudience == "" {
+		return nil
+	}
+	return []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("audience", c.OAuthAudience),
+	}
+}
+
+// scopes returns the configured scopes without blank entries
+func (c OAuthConfig) scopes() []string {
+	var scopes []string
+	for _, scope := range c.OAuthScopes {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// requireSet reports each named value that is empty as ErrRequired
+func requireSet(values [][2]string) error {
+	var errs ValidationErrors
+	for _, kv := range values {
+		if kv[1] == "" {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrRequired, kv[0]))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
 }
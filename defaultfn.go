@@ -0,0 +1,72 @@
+package coil
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	defaultFnsMu sync.RWMutex
+	// defaultFns holds the resolvers named by defaultfn tags
+	defaultFns = map[string]func() string{
+		"hostname": func() string {
+			host, _ := os.Hostname()
+			return host
+		},
+		"uuid": uuid.NewString,
+		"pid": func() string {
+			return strconv.Itoa(os.Getpid())
+		},
+		"now_rfc3339": func() string {
+			return time.Now().Format(time.RFC3339)
+		},
+	}
+)
+
+// RegisterDefaultFn registers a resolver computing the default of fields
+// tagged defaultfn:"name". It replaces any resolver of the same name,
+// including the built in hostname, uuid, pid and now_rfc3339
+func RegisterDefaultFn(name string, fn func() string) {
+	defaultFnsMu.Lock()
+	defer defaultFnsMu.Unlock()
+	defaultFns[name] = fn
+}
+
+// applyDefaultFns computes the default of every field tagged defaultfn
+// that no source has supplied. The result is kept by the parser, so a
+// reload does not compute it again
+func applyDefaultFns(c Configer) error {
+	b := c.base()
+	parser := c.getParser()
+	var err error
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			if err != nil || def.DefaultFn == "" || parser.IsSet(def.Name) {
+				return
+			}
+			defaultFnsMu.RLock()
+			fn, ok := defaultFns[def.DefaultFn]
+			defaultFnsMu.RUnlock()
+			if !ok {
+				err = fmt.Errorf(
+					"%s: unknown defaultfn %q",
+					def.Name,
+					def.DefaultFn,
+				)
+				return
+			}
+			val := fn()
+			b.mu.Lock()
+			parser.SetDefault(def.Name, val)
+			b.mu.Unlock()
+		},
+	)
+	return err
+}
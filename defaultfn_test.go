@@ -0,0 +1,84 @@
+package coil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// DefaultFnCfg for computed default testing
+type DefaultFnCfg struct {
+	Config
+	Host       string `type:"string" name:"dfn_host"     desc:"Host"        defaultfn:"hostname"`
+	InstanceID string `type:"string" name:"dfn_instance" desc:"Instance ID" defaultfn:"uuid"`
+	PID        int    `type:"int"    name:"dfn_pid"      default:"0"        desc:"Process ID" defaultfn:"pid"`
+	Zone       string `type:"string" name:"dfn_zone"     desc:"Zone"        defaultfn:"test_zone"`
+}
+
+func TestDefaultFn(t *testing.T) {
+	RegisterDefaultFn("test_zone", func() string { return "zone-a" })
+	c, err := NewConfig(
+		&DefaultFnCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--dfn_host=flag.example.com"}),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg := c.(*DefaultFnCfg)
+	if cfg.Host != "flag.example.com" {
+		t.Errorf("Host = %q, want the flag value", cfg.Host)
+	}
+	if _, err := uuid.Parse(cfg.InstanceID); err != nil {
+		t.Errorf("InstanceID = %q, want a UUID", cfg.InstanceID)
+	}
+	if cfg.PID != os.Getpid() {
+		t.Errorf("PID = %d, want %d", cfg.PID, os.Getpid())
+	}
+	if cfg.Zone != "zone-a" {
+		t.Errorf("Zone = %q, want the registered resolver value", cfg.Zone)
+	}
+
+	// The computed value is kept across reloads
+	id := cfg.InstanceID
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if cfg.InstanceID != id {
+		t.Errorf("InstanceID = %q after Reload, want %q", cfg.InstanceID, id)
+	}
+}
+
+func TestDefaultFnEnv(t *testing.T) {
+	origVal := os.Getenv("DFN_PID")
+	defer restoreEnv("DFN_PID", origVal)
+	os.Setenv("DFN_PID", "42")
+
+	RegisterDefaultFn("test_zone", func() string { return "zone-a" })
+	c, err := NewConfig(&DefaultFnCfg{}, WithMerge(false), WithArgs(nil))
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	if pid := c.(*DefaultFnCfg).PID; pid != 42 {
+		t.Errorf("PID = %d, want the env value 42", pid)
+	}
+	host, _ := os.Hostname()
+	if got := c.(*DefaultFnCfg).Host; got != host {
+		t.Errorf("Host = %q, want %q", got, host)
+	}
+}
+
+// UnknownDefaultFnCfg names a resolver that is not registered
+type UnknownDefaultFnCfg struct {
+	Config
+	Value string `type:"string" name:"dfn_value" desc:"Value" defaultfn:"missing"`
+}
+
+func TestDefaultFnUnknown(t *testing.T) {
+	_, err := NewConfig(&UnknownDefaultFnCfg{}, WithMerge(false), WithArgs(nil))
+	want := `dfn_value: unknown defaultfn "missing"`
+	if err == nil || err.Error() != want {
+		t.Errorf("NewConfig() error = %v, want %s", err, want)
+	}
+}
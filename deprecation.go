@@ -0,0 +1,68 @@
+package coil
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// warnDeprecated is the default deprecation handler, printing the warning
+// to stderr
+func warnDeprecated(oldKey, msg string) {
+	fmt.Fprintf(
+		os.Stderr,
+		"coil: config key %q is deprecated: %s\n",
+		oldKey,
+		msg,
+	)
+}
+
+// applyDeprecations reports every deprecated key that was supplied by a
+// source and copies its value to the key named by its newname tag, unless
+// that key was supplied as well
+func applyDeprecations(c Configer, handler func(oldKey, msg string)) {
+	b := c.base()
+	parser := c.getParser()
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			if def.Deprecated == "" || !parser.IsSet(def.Name) {
+				return
+			}
+			if handler != nil {
+				handler(def.Name, def.Deprecated)
+			}
+			if def.NewName != "" && !parser.IsSet(def.NewName) {
+				b.mu.Lock()
+				parser.Set(def.NewName, parser.Get(def.Name))
+				b.mu.Unlock()
+			}
+		},
+	)
+}
+
+// warnEnvAliases reports every field read from one of its envaliases
+// variables because the primary variable is unset
+func warnEnvAliases(c Configer, o *options) {
+	if o.deprecationHandler == nil {
+		return
+	}
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			if len(def.EnvAliases) == 0 {
+				return
+			}
+			primary := primaryEnv(def, o)
+			if _, ok := os.LookupEnv(primary); ok {
+				return
+			}
+			for _, alias := range def.EnvAliases {
+				if _, ok := os.LookupEnv(alias); ok {
+					o.deprecationHandler(alias, "use "+primary+" instead")
+					return
+				}
+			}
+		},
+	)
+}
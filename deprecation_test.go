@@ -0,0 +1,149 @@
+package coil
+
+import (
+	"os"
+	"testing"
+)
+
+// DeprecatedCfg for deprecated key testing
+type DeprecatedCfg struct {
+	Config
+	Hosts DeprecatedStruct `prefix:"dep"`
+}
+
+type DeprecatedStruct struct {
+	OldHost string `type:"string" name:"old_host" default:""          desc:"Replaced by new_host" deprecated:"use new_host instead" newname:"new_host"`
+	NewHost string `type:"string" name:"new_host" default:"localhost" desc:"Host to connect to"`
+}
+
+func TestDeprecatedKeyAliasesNewKey(t *testing.T) {
+	envVars := []string{"DEP_OLD_HOST", "DEP_NEW_HOST"}
+	origVals := make(map[string]string)
+	for _, env := range envVars {
+		origVals[env] = os.Getenv(env)
+		os.Unsetenv(env)
+	}
+	defer func() {
+		for _, env := range envVars {
+			restoreEnv(env, origVals[env])
+		}
+	}()
+	os.Setenv("DEP_OLD_HOST", "legacy.example.com")
+
+	var warnings []string
+	cfg := MustNewConfig(
+		&DeprecatedCfg{},
+		WithMerge(false),
+		WithDeprecationHandler(func(oldKey, msg string) {
+			warnings = append(warnings, oldKey+": "+msg)
+		}),
+	).(*DeprecatedCfg)
+
+	if len(warnings) != 1 ||
+		warnings[0] != "dep_old_host: use new_host instead" {
+		t.Errorf("warnings = %q, want one for dep_old_host", warnings)
+	}
+	if cfg.Hosts.OldHost != "legacy.example.com" {
+		t.Errorf(
+			"OldHost = %q, want %q",
+			cfg.Hosts.OldHost,
+			"legacy.example.com",
+		)
+	}
+	if cfg.Hosts.NewHost != "legacy.example.com" {
+		t.Errorf(
+			"NewHost = %q, want %q",
+			cfg.Hosts.NewHost,
+			"legacy.example.com",
+		)
+	}
+
+	// An explicit value for the new key wins over the deprecated one
+	os.Setenv("DEP_NEW_HOST", "new.example.com")
+	cfg = MustNewConfig(
+		&DeprecatedCfg{},
+		WithMerge(false),
+		WithDeprecationHandler(nil),
+	).(*DeprecatedCfg)
+	if cfg.Hosts.NewHost != "new.example.com" {
+		t.Errorf("NewHost = %q, want %q", cfg.Hosts.NewHost, "new.example.com")
+	}
+}
+
+func TestDeprecatedKeyUnusedNoWarning(t *testing.T) {
+	origVal := os.Getenv("DEP_OLD_HOST")
+	os.Unsetenv("DEP_OLD_HOST")
+	defer restoreEnv("DEP_OLD_HOST", origVal)
+
+	called := false
+	MustNewConfig(
+		&DeprecatedCfg{},
+		WithMerge(false),
+		WithDeprecationHandler(func(string, string) { called = true }),
+	)
+	if called {
+		t.Error("deprecation handler called for unused key")
+	}
+}
+
+// EnvAliasCfg for env alias testing
+type EnvAliasCfg struct {
+	Config
+	Host string `type:"string" name:"database_host" default:"localhost" desc:"Database host" envaliases:"DB_HOST,POSTGRES_HOST"`
+}
+
+func TestEnvAliases(t *testing.T) {
+	envVars := []string{"DATABASE_HOST", "DB_HOST", "POSTGRES_HOST"}
+	origVals := make(map[string]string)
+	for _, env := range envVars {
+		origVals[env] = os.Getenv(env)
+		os.Unsetenv(env)
+	}
+	defer func() {
+		for _, env := range envVars {
+			restoreEnv(env, origVals[env])
+		}
+	}()
+
+	load := func() (string, []string) {
+		var warnings []string
+		cfg := MustNewConfig(
+			&EnvAliasCfg{},
+			WithMerge(false),
+			WithDeprecationHandler(func(oldKey, msg string) {
+				warnings = append(warnings, oldKey+": "+msg)
+			}),
+		).(*EnvAliasCfg)
+		return cfg.Host, warnings
+	}
+
+	os.Setenv("POSTGRES_HOST", "pg.example.com")
+	host, warnings := load()
+	if host != "pg.example.com" {
+		t.Errorf("Host = %q, want the POSTGRES_HOST value", host)
+	}
+	if len(warnings) != 1 ||
+		warnings[0] != "POSTGRES_HOST: use DATABASE_HOST instead" {
+		t.Errorf("warnings = %q, want one for POSTGRES_HOST", warnings)
+	}
+
+	// Aliases are tried in order
+	os.Setenv("DB_HOST", "db.example.com")
+	host, warnings = load()
+	if host != "db.example.com" {
+		t.Errorf("Host = %q, want the DB_HOST value", host)
+	}
+	if len(warnings) != 1 || warnings[0] != "DB_HOST: use DATABASE_HOST instead" {
+		t.Errorf("warnings = %q, want one for DB_HOST", warnings)
+	}
+
+	// The primary name wins without a warning
+	os.Setenv("DATABASE_HOST", "primary.example.com")
+	host, warnings = load()
+	if host != "primary.example.com" {
+		t.Errorf("Host = %q, want the DATABASE_HOST value", host)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("warnings = %q, want none", warnings)
+	}
+}
@@ -0,0 +1,75 @@
+package coil
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Changed is reported as the new value of a secret field that differs
+const Changed = "[CHANGED]"
+
+// Unchanged is reported as the new value of a secret field that is the
+// same in both configs
+const Unchanged = "[UNCHANGED]"
+
+// FieldDiff describes a key whose value differs between two configs
+type FieldDiff struct {
+	Key      string
+	OldValue interface{}
+	NewValue interface{}
+}
+
+// Diff compares two populated configs key by key and returns the keys
+// whose values differ, sorted by key. Keys registered by only one of the
+// configs are reported with a nil value on the other side. Secret values
+// are never included: a secret is reported with Redacted as its old value
+// and Changed or Unchanged as its new one. When nothing differs the slice
+// is empty, secrets included
+func Diff(a, b Configer) []FieldDiff {
+	oldValues := ToMap(a, WithSecrets())
+	newValues := ToMap(b, WithSecrets())
+	keys := make(map[string]bool, len(oldValues)+len(newValues))
+	for key := range oldValues {
+		keys[key] = true
+	}
+	for key := range newValues {
+		keys[key] = true
+	}
+	diffs := []FieldDiff{}
+	var unchanged []FieldDiff
+	for key := range keys {
+		secret := isSecret(a, key) || isSecret(b, key)
+		if reflect.DeepEqual(oldValues[key], newValues[key]) {
+			if secret {
+				unchanged = append(unchanged, FieldDiff{
+					Key:      key,
+					OldValue: Redacted,
+					NewValue: Unchanged,
+				})
+			}
+			continue
+		}
+		diff := FieldDiff{
+			Key:      key,
+			OldValue: oldValues[key],
+			NewValue: newValues[key],
+		}
+		if secret {
+			diff.OldValue, diff.NewValue = Redacted, Changed
+		}
+		diffs = append(diffs, diff)
+	}
+	if len(diffs) > 0 {
+		diffs = append(diffs, unchanged...)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Key < diffs[j].Key
+	})
+	return diffs
+}
+
+// isSecret reports whether key is registered by c as a secret field
+func isSecret(c Configer, key string) bool {
+	def, _, ok := lookupField(c, key)
+	return ok && def.Secret
+}
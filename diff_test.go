@@ -0,0 +1,48 @@
+package coil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	newDB := func(values map[string]interface{}) Configer {
+		c, err := NewConfigFromMap(values, &ExportCfg{})
+		if err != nil {
+			t.Fatalf("NewConfigFromMap() error = %v", err)
+		}
+		return c
+	}
+	a := newDB(map[string]interface{}{"export_dbpass": "one"})
+	b := newDB(map[string]interface{}{
+		"export_dbhost": "db.internal",
+		"export_dbport": 6543,
+		"export_dbpass": "two",
+	})
+
+	want := []FieldDiff{
+		{"export_dbhost", "localhost", "db.internal"},
+		{"export_dbpass", Redacted, Changed},
+		{"export_dbport", 5432, 6543},
+	}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+
+	c := newDB(map[string]interface{}{
+		"export_dbhost": "db.internal",
+		"export_dbpass": "one",
+	})
+	want = []FieldDiff{
+		{"export_dbhost", "localhost", "db.internal"},
+		{"export_dbpass", Redacted, Unchanged},
+	}
+	if got := Diff(a, c); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+
+	same := Diff(a, newDB(map[string]interface{}{"export_dbpass": "one"}))
+	if same == nil || len(same) != 0 {
+		t.Errorf("Diff() of equal configs = %#v, want empty slice", same)
+	}
+}
@@ -0,0 +1,74 @@
+package coil
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Doc renders a Markdown reference table of every key registered by the
+// config, sorted by flag name so the output can be committed and diffed
+func Doc(c Configer) string {
+	var rows [][]string
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			desc := def.Desc
+			if def.Secret {
+				desc = strings.TrimSpace(desc + " (sensitive)")
+			}
+			required := "no"
+			if def.Required {
+				required = "yes"
+			}
+			var defVal string
+			if def.Default != "" {
+				defVal = "`" + def.Default + "`"
+			}
+			rows = append(rows, []string{
+				"`--" + def.Name + "`",
+				"`" + envName(c, def) + "`",
+				def.Type,
+				defVal,
+				required,
+				desc,
+			})
+		},
+	)
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i][0] < rows[j][0]
+	})
+
+	var b strings.Builder
+	b.WriteString("| Flag Name | Env Variable | Type | Default | Required |")
+	b.WriteString(" Description |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, row := range rows {
+		for i, cell := range row {
+			row[i] = escapeTableCell(cell)
+		}
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(row, " | "))
+	}
+	return b.String()
+}
+
+// DocHTML renders the Doc table as HTML
+func DocHTML(c Configer) ([]byte, error) {
+	var buf bytes.Buffer
+	md := goldmark.New(goldmark.WithExtensions(extension.Table))
+	if err := md.Convert([]byte(Doc(c)), &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// escapeTableCell keeps a value on one line and within its table cell
+func escapeTableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
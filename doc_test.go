@@ -0,0 +1,58 @@
+package coil
+
+import (
+	"strings"
+	"testing"
+)
+
+// DocCfg for documentation testing
+type DocCfg struct {
+	Config
+	Zone string         `coil:"name=doc_zone,type=string,desc='Zone | region',required"`
+	DB   DatabaseConfig `prefix:"doc"`
+}
+
+func TestDoc(t *testing.T) {
+	doc := Doc(&DocCfg{})
+	lines := strings.Split(strings.TrimSpace(doc), "\n")
+	if !strings.HasPrefix(lines[0], "| Flag Name | Env Variable |") {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	// Header, separator and one row per key
+	if want := 2 + 1 + 12; len(lines) != want {
+		t.Errorf("Doc() has %d lines, want %d", len(lines), want)
+	}
+	wantRows := []string{
+		"| `--doc_dbhost` | `DOC_DBHOST` | string | `localhost` | no |" +
+			" Database hostname |",
+		"| `--doc_dbpass` | `DOC_DBPASS` | string |  | no |" +
+			" Database password (sensitive) |",
+		"| `--doc_zone` | `DOC_ZONE` | string |  | yes | Zone \\| region |",
+	}
+	for _, row := range wantRows {
+		if !strings.Contains(doc, row+"\n") {
+			t.Errorf("Doc() missing row %q in:\n%s", row, doc)
+		}
+	}
+	if doc != Doc(&DocCfg{}) {
+		t.Error("Doc() output is not deterministic")
+	}
+	for i := 3; i < len(lines); i++ {
+		if lines[i-1] > lines[i] {
+			t.Errorf("rows not sorted: %q before %q", lines[i-1], lines[i])
+		}
+	}
+}
+
+func TestDocHTML(t *testing.T) {
+	html, err := DocHTML(&DocCfg{})
+	if err != nil {
+		t.Fatalf("DocHTML() error = %v", err)
+	}
+	if !strings.Contains(string(html), "<table>") {
+		t.Errorf("DocHTML() did not render a table:\n%s", html)
+	}
+	if !strings.Contains(string(html), "<code>--doc_dbhost</code>") {
+		t.Errorf("DocHTML() missing doc_dbhost:\n%s", html)
+	}
+}
@@ -0,0 +1,64 @@
+package coil
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/cvlstack/coil/envfile"
+)
+
+// loadEnvFile layers the dotenv file named by the env_file flag, or else by
+// WithEnvFile, over the config file. Real environment variables and flags
+// still take precedence. A missing file is only an error when it was named
+// by the flag
+func loadEnvFile(v *viper.Viper, optPath string) error {
+	path := v.GetString("env_file")
+	explicit := path != ""
+	if !explicit {
+		path = optPath
+	}
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !explicit {
+			fmt.Fprintf(
+				os.Stderr,
+				"coil: env file %q not found, skipping\n",
+				path,
+			)
+			return nil
+		}
+		return fmt.Errorf("could not open env file: %w", err)
+	}
+	defer f.Close()
+	values, err := envfile.Parse(f)
+	if err != nil {
+		return fmt.Errorf("could not parse env file %s: %w", path, err)
+	}
+	settings := make(map[string]interface{}, len(values))
+	for key, val := range values {
+		settings[strings.ToLower(key)] = val
+	}
+	return v.MergeConfigMap(settings)
+}
+
+// applyEnvFiles registers the layered dotenv files given to WithEnvFiles
+// as defaults, beneath the config file
+func applyEnvFiles(v *viper.Viper, files *envFiles) error {
+	if files == nil {
+		return nil
+	}
+	values, err := envfile.Load(files.profile, files.dirs...)
+	if err != nil {
+		return err
+	}
+	envfile.InjectIntoViper(v, values)
+	return nil
+}
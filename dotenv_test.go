@@ -0,0 +1,85 @@
+package coil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// DotenvCfg for dotenv testing
+type DotenvCfg struct {
+	Config
+	Host string `type:"string" name:"dotenv_host" default:"localhost" desc:"Host"`
+	Port int    `type:"int"    name:"dotenv_port" default:"80"        desc:"Port"`
+}
+
+func TestWithEnvFile(t *testing.T) {
+	origVal := os.Getenv("DOTENV_PORT")
+	os.Setenv("DOTENV_PORT", "9000")
+	defer restoreEnv("DOTENV_PORT", origVal)
+
+	path := filepath.Join(t.TempDir(), ".env")
+	data := "DOTENV_HOST=db.internal\nDOTENV_PORT=5000\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := NewConfig(&DotenvCfg{}, WithMerge(false), WithEnvFile(path))
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	got := cfg.(*DotenvCfg)
+	if got.Host != "db.internal" {
+		t.Errorf("Host = %q, want %q", got.Host, "db.internal")
+	}
+	// Real environment variables win over the dotenv file
+	if got.Port != 9000 {
+		t.Errorf("Port = %d, want %d", got.Port, 9000)
+	}
+}
+
+func TestWithEnvFileMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.env")
+	_, err := NewConfig(&DotenvCfg{}, WithMerge(false), WithEnvFile(path))
+	if err != nil {
+		t.Errorf("NewConfig() with missing optional env file error = %v", err)
+	}
+
+	// A file named on the command line must exist
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app", "--env_file=" + path}
+	if _, err := NewConfig(&DotenvCfg{}, WithMerge(false)); err == nil {
+		t.Error("NewConfig() with missing --env_file should return an error")
+	}
+}
+
+func TestWithEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".env":            "DOTENV_HOST=base.internal\nDOTENV_PORT=5000\n",
+		".env.production": "DOTENV_HOST=prod.internal\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := NewConfig(
+		&DotenvCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--dotenv_port=9000"}),
+		WithEnvFiles("production", dir),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	got := cfg.(*DotenvCfg)
+	if got.Host != "prod.internal" {
+		t.Errorf("Host = %q, want %q", got.Host, "prod.internal")
+	}
+	// Flags win over the env files
+	if got.Port != 9000 {
+		t.Errorf("Port = %d, want %d", got.Port, 9000)
+	}
+}
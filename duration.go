@@ -0,0 +1,80 @@
+package coil
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationFormats describes the duration formats ParseDurationLenient
+// accepts, and is appended to the help text of duration flags
+const durationFormats = "e.g. 30s, 1h30m, 5 minutes or 2 days"
+
+// durationPart matches one number and unit of a human duration
+var durationPart = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zµμ]+)\s*`)
+
+// durationUnits maps the unit words ParseDurationLenient accepts to the
+// units of time.ParseDuration. Days are converted to hours
+var durationUnits = map[string]string{
+	"ns": "ns", "nanosecond": "ns", "nanoseconds": "ns",
+	"us": "us", "µs": "us", "μs": "us",
+	"microsecond": "us", "microseconds": "us",
+	"ms": "ms", "msec": "ms", "msecs": "ms",
+	"millisecond": "ms", "milliseconds": "ms",
+	"s": "s", "sec": "s", "secs": "s", "second": "s", "seconds": "s",
+	"m": "m", "min": "m", "mins": "m", "minute": "m", "minutes": "m",
+	"h": "h", "hr": "h", "hrs": "h", "hour": "h", "hours": "h",
+	"d": "d", "day": "d", "days": "d",
+}
+
+// ParseDurationLenient parses a duration in the format of
+// time.ParseDuration, or written out the way people tend to write them:
+// "5 minutes", "1 hour 30 minutes", "1h, 30m" or "2 days". Units may be
+// abbreviated (sec, min, hr) and separated by spaces, commas or "and". A
+// day is always 24 hours
+func ParseDurationLenient(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	rest := strings.ToLower(strings.TrimSpace(s))
+	sign := ""
+	if strings.HasPrefix(rest, "-") || strings.HasPrefix(rest, "+") {
+		sign, rest = rest[:1], strings.TrimSpace(rest[1:])
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	for rest != "" {
+		m := durationPart.FindStringSubmatch(rest)
+		if m == nil {
+			return 0, fmt.Errorf("%q is not a valid duration", s)
+		}
+		unit, ok := durationUnits[m[2]]
+		if !ok {
+			return 0, fmt.Errorf("%q has unknown duration unit %q", s, m[2])
+		}
+		num := m[1]
+		if unit == "d" {
+			days, _ := strconv.ParseFloat(num, 64)
+			num, unit = strconv.FormatFloat(days*24, 'f', -1, 64), "h"
+		}
+		b.WriteString(num + unit)
+		rest = strings.TrimPrefix(rest[len(m[0]):], ",")
+		rest = strings.TrimPrefix(strings.TrimSpace(rest), "and ")
+		rest = strings.TrimSpace(rest)
+	}
+	d, err := time.ParseDuration(b.String())
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid duration", s)
+	}
+	return d, nil
+}
+
+// durationUsage appends the accepted formats to a duration flag's help
+func durationUsage(desc string) string {
+	if desc == "" {
+		return durationFormats
+	}
+	return desc + " (" + durationFormats + ")"
+}
@@ -0,0 +1,67 @@
+package coil
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDurationLenient(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"15s", 15 * time.Second},
+		{"1h30m0s", 90 * time.Minute},
+		{"5 minutes", 5 * time.Minute},
+		{"1 hour", time.Hour},
+		{"1 Hour 30 Mins", 90 * time.Minute},
+		{"1h, 30m", 90 * time.Minute},
+		{"2 hours and 15 seconds", 2*time.Hour + 15*time.Second},
+		{"1.5 hrs", 90 * time.Minute},
+		{"2 days", 48 * time.Hour},
+		{"-10 sec", -10 * time.Second},
+		{"250 milliseconds", 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		got, err := ParseDurationLenient(tt.in)
+		if err != nil {
+			t.Errorf("ParseDurationLenient(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf(
+				"ParseDurationLenient(%q) = %v, want %v",
+				tt.in,
+				got,
+				tt.want,
+			)
+		}
+	}
+	for _, in := range []string{"", "5", "five minutes", "5 fortnights"} {
+		if _, err := ParseDurationLenient(in); err == nil {
+			t.Errorf("ParseDurationLenient(%q) should fail", in)
+		}
+	}
+}
+
+// LenientDurationCfg for human readable duration defaults
+type LenientDurationCfg struct {
+	Config
+	Grace time.Duration `type:"duration" name:"grace" default:"5 minutes" desc:"Shutdown grace period"`
+}
+
+func TestDurationDefaultLenient(t *testing.T) {
+	c := MustNewConfig(
+		&LenientDurationCfg{},
+		WithMerge(false),
+		WithArgs([]string{}),
+	).(*LenientDurationCfg)
+	if c.Grace != 5*time.Minute {
+		t.Errorf("Grace = %v, want 5m", c.Grace)
+	}
+	usage := c.flags.Lookup("grace").Usage
+	if !strings.Contains(usage, durationFormats) {
+		t.Errorf("usage %q does not list the accepted formats", usage)
+	}
+}
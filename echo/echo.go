@@ -0,0 +1,31 @@
+// Package echo provides echo middleware making a coil config available to
+// handlers through their context rather than a package level variable
+package echo
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/cvlstack/coil"
+)
+
+// ContextKey is the key the config is stored under in the echo context
+const ContextKey = "coil.config"
+
+// Middleware stores c in the context of every request, where handlers
+// read it with FromContext. Tests can pass a config built with
+// coil.NewConfigFromMap instead of the one the program loads
+func Middleware(c coil.Configer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Set(ContextKey, c)
+			return next(ctx)
+		}
+	}
+}
+
+// FromContext returns the config stored by Middleware, or nil if the
+// middleware did not run for the request
+func FromContext(ctx echo.Context) coil.Configer {
+	c, _ := ctx.Get(ContextKey).(coil.Configer)
+	return c
+}
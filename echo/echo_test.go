@@ -0,0 +1,52 @@
+package echo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/cvlstack/coil"
+)
+
+// EchoCfg for middleware testing
+type EchoCfg struct {
+	coil.Config
+	Greeting string `type:"string" name:"echo_greeting" default:"hello"`
+}
+
+func TestMiddleware(t *testing.T) {
+	c, err := coil.NewConfigFromMap(
+		map[string]interface{}{"echo_greeting": "hi"},
+		&EchoCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	e := echo.New()
+	e.Use(Middleware(c))
+	e.GET("/", func(ctx echo.Context) error {
+		cfg, ok := FromContext(ctx).(*EchoCfg)
+		if !ok {
+			return ctx.String(http.StatusInternalServerError, "no config")
+		}
+		return ctx.String(http.StatusOK, cfg.Greeting)
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hi" {
+		t.Errorf("GET / = %d %q, want 200 hi", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFromContextWithoutMiddleware(t *testing.T) {
+	e := echo.New()
+	ctx := e.NewContext(
+		httptest.NewRequest(http.MethodGet, "/", nil),
+		httptest.NewRecorder(),
+	)
+	if c := FromContext(ctx); c != nil {
+		t.Errorf("FromContext() = %v, want nil", c)
+	}
+}
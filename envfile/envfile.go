@@ -0,0 +1,137 @@
+// Package envfile reads layered dotenv files, as used by twelve-factor
+// apps: a committed base .env, a deployment specific .env.<profile> and a
+// gitignored .env.local, each overriding the one before
+package envfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Load reads .env, .env.<profile> and .env.local, in that order, from each
+// of dirs and merges them, later files overriding earlier ones. Every dir
+// is read for one file name before moving on to the next, so .env.local
+// always wins. The profile file is skipped when profile is empty, dirs
+// default to the working directory and missing files are ignored
+func Load(profile string, dirs ...string) (map[string]string, error) {
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	names := []string{".env"}
+	if profile != "" {
+		names = append(names, ".env."+profile)
+	}
+	names = append(names, ".env.local")
+	values := make(map[string]string)
+	for _, name := range names {
+		for _, dir := range dirs {
+			if err := loadFile(filepath.Join(dir, name), values); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+// loadFile merges the pairs of the file at path into values, unless the
+// file does not exist
+func loadFile(path string, values map[string]string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not open env file: %w", err)
+	}
+	defer f.Close()
+	parsed, err := Parse(f)
+	if err != nil {
+		return fmt.Errorf("could not parse env file %s: %w", path, err)
+	}
+	for key, val := range parsed {
+		values[key] = val
+	}
+	return nil
+}
+
+// InjectIntoViper registers each value as the default of the key of the
+// same name, ignoring case, so every other source takes precedence
+func InjectIntoViper(v *viper.Viper, m map[string]string) {
+	for key, val := range m {
+		v.SetDefault(key, val)
+	}
+}
+
+// Parse reads KEY=VALUE pairs, ignoring blank lines, # comments and
+// an optional export prefix. Double quoted values support \n, \" and \\
+// escapes, single quoted values are taken literally
+func Parse(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, val, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+		val, err := parseValue(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		values[key] = val
+	}
+	return values, scanner.Err()
+}
+
+// parseValue unquotes a value, dropping any trailing comment
+func parseValue(val string) (string, error) {
+	if val == "" {
+		return "", nil
+	}
+	switch quote := val[0]; quote {
+	case '\'':
+		end := strings.IndexByte(val[1:], '\'')
+		if end < 0 {
+			return "", errors.New("unterminated single quote")
+		}
+		return val[1 : end+1], nil
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(val); i++ {
+			switch c := val[i]; {
+			case c == '"':
+				return b.String(), nil
+			case c == '\\' && i+1 < len(val):
+				i++
+				switch val[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(val[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", errors.New("unterminated double quote")
+	}
+	if i := strings.Index(val, " #"); i >= 0 {
+		val = val[:i]
+	}
+	return strings.TrimSpace(val), nil
+}
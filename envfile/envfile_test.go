@@ -0,0 +1,119 @@
+package envfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestParse(t *testing.T) {
+	input := strings.Join([]string{
+		"# comment",
+		"",
+		"PLAIN=value",
+		"export EXPORTED=yes",
+		`DOUBLE="line\nbreak \"quoted\""`,
+		`SINGLE='literal \n # kept'`,
+		"TRAILING=value # comment",
+		"EMPTY=",
+	}, "\n")
+	values, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := map[string]string{
+		"PLAIN":    "value",
+		"EXPORTED": "yes",
+		"DOUBLE":   "line\nbreak \"quoted\"",
+		"SINGLE":   `literal \n # kept`,
+		"TRAILING": "value",
+		"EMPTY":    "",
+	}
+	for key, val := range want {
+		if values[key] != val {
+			t.Errorf("%s = %q, want %q", key, values[key], val)
+		}
+	}
+	if len(values) != len(want) {
+		t.Errorf("parsed %d values, want %d", len(values), len(want))
+	}
+
+	for _, bad := range []string{"NOEQUALS", `OPEN="unterminated`} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("Parse(%q) should return an error", bad)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	base, override := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(base, ".env"):                "A=base\nB=base\nC=base\nD=base\n",
+		filepath.Join(base, ".env.production"):     "B=production\nC=production\n",
+		filepath.Join(base, ".env.local"):          "C=local\n",
+		filepath.Join(override, ".env"):            "D=override\n",
+		filepath.Join(override, ".env.production"): "C=override\n",
+		filepath.Join(base, ".env.staging"):        "A=staging\n",
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		profile string
+		want    map[string]string
+	}{
+		{
+			name:    "profile",
+			profile: "production",
+			want:    map[string]string{"A": "base", "B": "production", "C": "local", "D": "override"},
+		},
+		{
+			name: "no profile",
+			want: map[string]string{"A": "base", "B": "base", "C": "local", "D": "override"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.profile, base, override)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("Load() = %v, want %v", got, tt.want)
+			}
+			for key, val := range tt.want {
+				if got[key] != val {
+					t.Errorf("%s = %q, want %q", key, got[key], val)
+				}
+			}
+		})
+	}
+
+	got, err := Load("production", t.TempDir())
+	if err != nil || len(got) != 0 {
+		t.Errorf("Load() of an empty dir = %v, %v", got, err)
+	}
+	bad := t.TempDir()
+	os.WriteFile(filepath.Join(bad, ".env"), []byte("NOEQUALS\n"), 0o600)
+	if _, err := Load("", bad); err == nil {
+		t.Error("Load() of a malformed file should return an error")
+	}
+}
+
+func TestInjectIntoViper(t *testing.T) {
+	v := viper.New()
+	v.Set("db_port", "9000")
+	InjectIntoViper(v, map[string]string{"DB_HOST": "db.internal", "DB_PORT": "5000"})
+	if got := v.GetString("db_host"); got != "db.internal" {
+		t.Errorf("db_host = %q, want %q", got, "db.internal")
+	}
+	if got := v.GetString("db_port"); got != "9000" {
+		t.Errorf("db_port = %q, want %q", got, "9000")
+	}
+}
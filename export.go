@@ -0,0 +1,401 @@
+package coil
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted is the placeholder exported in place of secret values
+const Redacted = "[REDACTED]"
+
+// ErrUnknownKey is returned when a key is not registered by the config
+var ErrUnknownKey = errors.New("unknown config key")
+
+// ExportOption customises how config values are exported
+type ExportOption func(*exportOptions)
+
+// exportOptions holds the settings applied by ExportOption functions
+type exportOptions struct {
+	secrets bool
+}
+
+// WithSecrets includes the live value of fields tagged secret:"true" instead
+// of redacting them
+func WithSecrets() ExportOption {
+	return func(o *exportOptions) {
+		o.secrets = true
+	}
+}
+
+// ToMap returns the current values of the config keyed by flag name. Fields
+// tagged secret:"true" are redacted unless WithSecrets is passed. Like the
+// other exported readers it holds the config's read lock, so it must not
+// be called from Parse
+func ToMap(c Configer, opts ...ExportOption) map[string]interface{} {
+	o := &exportOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	b := c.base()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return toMap(c, o)
+}
+
+// toMap is ToMap for callers already holding the config's lock
+func toMap(c Configer, o *exportOptions) map[string]interface{} {
+	values := make(map[string]interface{})
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, fv reflect.Value) {
+			if !fv.CanInterface() {
+				return
+			}
+			if def.Secret && !o.secrets {
+				values[def.Name] = Redacted
+				return
+			}
+			values[def.Name] = exportValue(fv, def)
+		},
+	)
+	return values
+}
+
+// ToJSON encodes the current values of the config as a JSON object
+func ToJSON(c Configer, opts ...ExportOption) ([]byte, error) {
+	return json.Marshal(ToMap(c, opts...))
+}
+
+// ToYAML encodes the current values of the config as a YAML document
+func ToYAML(c Configer, opts ...ExportOption) ([]byte, error) {
+	return yaml.Marshal(ToMap(c, opts...))
+}
+
+// Export writes the current values of the config as a config file
+// template in format: yaml, toml, json or env. Keys follow the struct
+// order, secrets are redacted and, except in json which has no comments,
+// each key is preceded by its desc tag as a comment. The env format also
+// lists the default, commented out, above the current value
+func (c *Config) Export(w io.Writer, format string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.root == nil {
+		return errors.New("config has not been loaded")
+	}
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(
+			toMap(c.root, &exportOptions{}),
+			"",
+			"  ",
+		)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	case "yaml", "toml", "env":
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+	var b bytes.Buffer
+	var err error
+	walkConfig(
+		c.root,
+		func(def fieldDef, _ reflect.StructField, fv reflect.Value) {
+			if err != nil || !fv.CanInterface() {
+				return
+			}
+			var val interface{} = Redacted
+			if !def.Secret {
+				val = exportValue(fv, def)
+			}
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			for _, line := range strings.Split(def.Desc, "\n") {
+				if line != "" {
+					fmt.Fprintf(&b, "# %s\n", line)
+				}
+			}
+			switch format {
+			case "yaml":
+				err = exportYAMLKey(&b, def.Name, val)
+			case "toml":
+				err = exportTOMLKey(&b, def.Name, val)
+			case "env":
+				name := envName(c.root, def)
+				fmt.Fprintf(&b, "#%s=%s\n", name, dotenvQuote(def.Default))
+				if val != nil {
+					fmt.Fprintf(&b, "%s=%s\n", name, dotenvQuote(envValue(val, def.Sep)))
+				}
+			}
+		},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// exportYAMLKey writes a single YAML key, commented out when it has no
+// value
+func exportYAMLKey(b *bytes.Buffer, key string, val interface{}) error {
+	if val == nil {
+		fmt.Fprintf(b, "# %s:\n", key)
+		return nil
+	}
+	data, err := yaml.Marshal(map[string]interface{}{key: val})
+	if err != nil {
+		return err
+	}
+	b.Write(data)
+	return nil
+}
+
+// exportTOMLKey writes a single TOML key, commented out when it has no
+// value. Maps are written as inline tables so later keys stay top level
+func exportTOMLKey(b *bytes.Buffer, key string, val interface{}) error {
+	if val == nil {
+		fmt.Fprintf(b, "# %s =\n", key)
+		return nil
+	}
+	enc := toml.NewEncoder(b)
+	enc.SetTablesInline(true)
+	return enc.Encode(map[string]interface{}{key: val})
+}
+
+// dotenvQuote double quotes a value that parseDotenv would not read back
+// as is
+func dotenvQuote(val string) string {
+	if !strings.ContainsAny(val, " \t\n#\"'\\") {
+		return val
+	}
+	return `"` + strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\t", `\t`,
+	).Replace(val) + `"`
+}
+
+// ToEnv returns the current values of the config as sorted KEY=VALUE pairs
+// suitable for exec.Cmd.Env. Names follow the env vars the config reads:
+// the env tag if set, otherwise the uppercased key with any WithEnvPrefix
+// prefix. Unset pointer fields are omitted.
+//
+// Secret fields are included unredacted, so their values are visible to
+// the child process and anything able to inspect its environment
+func ToEnv(c Configer) []string {
+	b := c.base()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var env []string
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, fv reflect.Value) {
+			if !fv.CanInterface() {
+				return
+			}
+			val := exportValue(fv, def)
+			if val == nil {
+				return
+			}
+			env = append(env, envName(c, def)+"="+envValue(val, def.Sep))
+		},
+	)
+	sort.Strings(env)
+	return env
+}
+
+// envName returns the env var a field is read from: its env tag, or else
+// the uppercased key with any WithEnvPrefix prefix
+func envName(c Configer, def fieldDef) string {
+	return primaryEnv(def, c.base().opts)
+}
+
+// primaryEnv returns the env var named by the env tag, or else the
+// uppercased key with the env prefix, passed through the env key replacer.
+// Nil options mean the defaults
+func primaryEnv(def fieldDef, o *options) string {
+	if def.Env != "" {
+		return def.Env
+	}
+	if o == nil {
+		o = newOptions(nil)
+	}
+	name := strings.ToUpper(def.Name)
+	if o.envPrefix != "" {
+		name = strings.ToUpper(o.envPrefix + "_" + def.Name)
+	}
+	if o.envKeyReplacer != nil {
+		name = o.envKeyReplacer.Replace(name)
+	}
+	return name
+}
+
+// envSet reports whether the key's env var or one of its aliases is set
+func envSet(def fieldDef, o *options) bool {
+	names := append([]string{primaryEnv(def, o)}, def.EnvAliases...)
+	for _, name := range names {
+		if _, ok := os.LookupEnv(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// envValue formats an exported value the way the config parses it back
+// from an env var
+func envValue(val interface{}, sep string) string {
+	switch v := val.(type) {
+	case []string:
+		return strings.Join(v, sep)
+	case map[string]string:
+		pairs := make([]string, 0, len(v))
+		for key, item := range v {
+			pairs = append(pairs, key+"="+item)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return fmt.Sprint(v)
+		}
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(rv.Index(i).Interface())
+		}
+		return strings.Join(items, sep)
+	}
+}
+
+// Reveal returns the live value of a config key as a string, including keys
+// tagged secret:"true". It is meant for code that legitimately needs the
+// secret, e.g. to open a connection
+func Reveal(c Configer, key string) (string, error) {
+	b := c.base()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	def, fv, ok := lookupField(c, key)
+	if !ok || !fv.CanInterface() {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, key)
+	}
+	return fmt.Sprint(exportValue(fv, def)), nil
+}
+
+// Value returns the live value of a config key with the field's own type,
+// e.g. a time.Duration rather than its string form. Secrets are included
+func Value(c Configer, key string) (interface{}, error) {
+	b := c.base()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, fv, ok := lookupField(c, key)
+	if !ok || !fv.CanInterface() {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, key)
+	}
+	return fv.Interface(), nil
+}
+
+// exportText formats a text field with its MarshalText method if it has
+// one, falling back to fmt
+func exportText(fv reflect.Value) interface{} {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	m, ok := fv.Interface().(encoding.TextMarshaler)
+	if !ok && fv.CanAddr() {
+		m, ok = fv.Addr().Interface().(encoding.TextMarshaler)
+	}
+	if !ok {
+		return fmt.Sprint(fv.Interface())
+	}
+	text, err := m.MarshalText()
+	if err != nil {
+		return fmt.Sprint(fv.Interface())
+	}
+	return string(text)
+}
+
+// exportValue converts a field value to a form that round-trips through
+// config files
+func exportValue(fv reflect.Value, def fieldDef) interface{} {
+	if def.Type == "text" {
+		return exportText(fv)
+	}
+	if def.Type == "json" {
+		if fv.Kind() == reflect.Ptr && fv.IsNil() {
+			return nil
+		}
+		b, err := json.Marshal(fv.Interface())
+		if err != nil {
+			return nil
+		}
+		return string(b)
+	}
+	if def.Type == "octal" {
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				return nil
+			}
+			fv = fv.Elem()
+		}
+		return formatOctal(fv.Uint())
+	}
+	if def.Type == "bytes" {
+		if fv.IsNil() {
+			return nil
+		}
+		if def.Encoding == "url" {
+			return base64.URLEncoding.EncodeToString(fv.Bytes())
+		}
+		return base64.StdEncoding.EncodeToString(fv.Bytes())
+	}
+	switch v := fv.Interface().(type) {
+	case net.IP:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	case *net.IPNet:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	}
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	switch v := fv.Interface().(type) {
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		return v.Format(def.Layout)
+	case url.URL:
+		return v.String()
+	}
+	return fv.Interface()
+}
@@ -0,0 +1,275 @@
+package coil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cvlstack/coil/envfile"
+)
+
+// ExportCfg for export testing
+type ExportCfg struct {
+	Config
+	DB DatabaseConfig `prefix:"export"`
+}
+
+func newExportCfg(t *testing.T) *ExportCfg {
+	origVal := os.Getenv("EXPORT_DBPASS")
+	os.Setenv("EXPORT_DBPASS", "hunter2")
+	t.Cleanup(func() { restoreEnv("EXPORT_DBPASS", origVal) })
+	return MustNewConfig(&ExportCfg{}, WithMerge(false)).(*ExportCfg)
+}
+
+func TestToMap(t *testing.T) {
+	cfg := newExportCfg(t)
+	values := ToMap(cfg)
+	if values["export_dbhost"] != "localhost" {
+		t.Errorf(
+			"export_dbhost = %v, want %q",
+			values["export_dbhost"],
+			"localhost",
+		)
+	}
+	if values["export_dbport"] != 5432 {
+		t.Errorf("export_dbport = %v, want %d", values["export_dbport"], 5432)
+	}
+	if values["export_dbpass"] != Redacted {
+		t.Errorf(
+			"export_dbpass = %v, want %q",
+			values["export_dbpass"],
+			Redacted,
+		)
+	}
+	values = ToMap(cfg, WithSecrets())
+	if values["export_dbpass"] != "hunter2" {
+		t.Errorf(
+			"export_dbpass with secrets = %v, want %q",
+			values["export_dbpass"],
+			"hunter2",
+		)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := newExportCfg(t)
+	data, err := ToJSON(cfg)
+	if err != nil {
+		t.Fatalf("ToJSON() error = %v", err)
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		t.Fatalf("ToJSON() produced invalid JSON: %v", err)
+	}
+	if values["export_dbpass"] != Redacted {
+		t.Errorf(
+			"export_dbpass = %v, want %q",
+			values["export_dbpass"],
+			Redacted,
+		)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Error("ToJSON() leaked secret value")
+	}
+}
+
+func TestToYAML(t *testing.T) {
+	cfg := newExportCfg(t)
+	data, err := ToYAML(cfg)
+	if err != nil {
+		t.Fatalf("ToYAML() error = %v", err)
+	}
+	if !strings.Contains(string(data), "export_dbhost: localhost") {
+		t.Errorf("ToYAML() missing export_dbhost, got:\n%s", data)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Error("ToYAML() leaked secret value")
+	}
+}
+
+func TestSecretMasking(t *testing.T) {
+	cfg := newExportCfg(t)
+	// The struct field holds the live value
+	if cfg.DB.DBPass != "hunter2" {
+		t.Errorf("DBPass = %q, want %q", cfg.DB.DBPass, "hunter2")
+	}
+	// String representations never do
+	for _, out := range []string{cfg.String(), fmt.Sprintf("%v", cfg)} {
+		if strings.Contains(out, "hunter2") {
+			t.Errorf("string output leaked secret value: %s", out)
+		}
+		if !strings.Contains(out, "export_dbpass="+Redacted) {
+			t.Errorf("string output missing redacted dbpass: %s", out)
+		}
+	}
+}
+
+func TestReveal(t *testing.T) {
+	cfg := newExportCfg(t)
+	val, err := Reveal(cfg, "export_dbpass")
+	if err != nil {
+		t.Fatalf("Reveal() error = %v", err)
+	}
+	if val != "hunter2" {
+		t.Errorf("Reveal() = %q, want %q", val, "hunter2")
+	}
+	if _, err := Reveal(cfg, "missing"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Reveal() error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestValue(t *testing.T) {
+	cfg := MustNewConfig(&EnvExportCfg{}, WithMerge(false))
+	val, err := Value(cfg, "envx_timeout")
+	if err != nil {
+		t.Fatalf("Value() error = %v", err)
+	}
+	if val != 90*time.Second {
+		t.Errorf("Value() = %#v, want %v", val, 90*time.Second)
+	}
+	if _, err := Value(cfg, "missing"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Value() error = %v, want ErrUnknownKey", err)
+	}
+}
+
+// EnvExportCfg for ToEnv testing
+type EnvExportCfg struct {
+	Config
+	Name    string        `type:"string"   name:"envx_name"    default:"svc"   desc:"Name"`
+	Debug   bool          `type:"bool"     name:"envx_debug"   default:"true"  desc:"Debug"`
+	Timeout time.Duration `type:"duration" name:"envx_timeout" default:"1m30s" desc:"Timeout"`
+	Tags    []string      `type:"[]string" name:"envx_tags"    default:"a;b"   desc:"Tags"     sep:";"`
+	Token   string        `type:"string"   name:"envx_token"   default:"t0k3n" desc:"Token"    secret:"true" env:"SERVICE_TOKEN"`
+	Unset   *int          `type:"int"      name:"envx_unset"                   desc:"Unset"`
+}
+
+func TestToEnv(t *testing.T) {
+	origVal := os.Getenv("SERVICE_TOKEN")
+	os.Unsetenv("SERVICE_TOKEN")
+	defer restoreEnv("SERVICE_TOKEN", origVal)
+
+	cfg := MustNewConfig(
+		&EnvExportCfg{},
+		WithMerge(false),
+		WithEnvPrefix("APP"),
+	)
+	want := []string{
+		"APP_ENVX_DEBUG=true",
+		"APP_ENVX_NAME=svc",
+		"APP_ENVX_TAGS=a;b",
+		"APP_ENVX_TIMEOUT=1m30s",
+		"SERVICE_TOKEN=t0k3n",
+	}
+	if got := ToEnv(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestEnvTagOverride(t *testing.T) {
+	origVal := os.Getenv("SERVICE_TOKEN")
+	os.Setenv("SERVICE_TOKEN", "from-env")
+	defer restoreEnv("SERVICE_TOKEN", origVal)
+
+	cfg := MustNewConfig(&EnvExportCfg{}, WithMerge(false)).(*EnvExportCfg)
+	if cfg.Token != "from-env" {
+		t.Errorf("Token = %q, want %q", cfg.Token, "from-env")
+	}
+}
+
+func TestExport(t *testing.T) {
+	cfg := newExportCfg(t)
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"yaml", []string{
+			"# Database hostname\nexport_dbhost: localhost\n",
+			"export_dbport: 5432\n",
+			"export_dbpass: '[REDACTED]'\n",
+		}},
+		{"toml", []string{
+			"# Database hostname\nexport_dbhost = 'localhost'\n",
+			"export_dbport = 5432\n",
+			"export_dbpass = '[REDACTED]'\n",
+		}},
+		{"env", []string{
+			"# Database hostname\n#EXPORT_DBHOST=localhost\nEXPORT_DBHOST=localhost\n",
+			"#EXPORT_DBPORT=5432\nEXPORT_DBPORT=5432\n",
+			"EXPORT_DBPASS=[REDACTED]\n",
+		}},
+		{"json", []string{
+			`"export_dbhost": "localhost"`,
+			`"export_dbpass": "[REDACTED]"`,
+		}},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := cfg.Export(&b, tt.format); err != nil {
+			t.Fatalf("Export(%s) error = %v", tt.format, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("Export(%s) = %q, want it to contain %q", tt.format, b.String(), want)
+			}
+		}
+		if strings.Contains(b.String(), "hunter2") {
+			t.Errorf("Export(%s) leaked a secret", tt.format)
+		}
+	}
+
+	var b strings.Builder
+	if err := cfg.Export(&b, "ini"); err == nil {
+		t.Error("Export(ini) should return an error")
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	cfg := newExportCfg(t)
+	cfg.DB.DBHost = "db.example.com"
+	cfg.DB.DBName = "my app"
+	for _, format := range []string{"yaml", "toml", "json"} {
+		var b strings.Builder
+		if err := cfg.Export(&b, format); err != nil {
+			t.Fatalf("Export(%s) error = %v", format, err)
+		}
+		path := filepath.Join(t.TempDir(), "config."+format)
+		if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewConfig(
+			&ExportCfg{},
+			WithMerge(false),
+			WithArgs([]string{"--config=" + path}),
+		)
+		if err != nil {
+			t.Fatalf("NewConfig() from %s error = %v", format, err)
+		}
+		got := c.(*ExportCfg).DB
+		if got.DBHost != "db.example.com" || got.DBName != "my app" {
+			t.Errorf(
+				"%s round trip DBHost, DBName = %q, %q",
+				format,
+				got.DBHost,
+				got.DBName,
+			)
+		}
+	}
+
+	var b strings.Builder
+	if err := cfg.Export(&b, "env"); err != nil {
+		t.Fatalf("Export(env) error = %v", err)
+	}
+	values, err := envfile.Parse(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if values["EXPORT_DBNAME"] != "my app" {
+		t.Errorf("EXPORT_DBNAME = %q, want %q", values["EXPORT_DBNAME"], "my app")
+	}
+}
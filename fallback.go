@@ -0,0 +1,23 @@
+package coil
+
+import "reflect"
+
+// applyFallback copies the value of every key no source has supplied from
+// the fallback config's parser, where one of its sources supplied it. The
+// copy is kept as a default, so it ranks below every source of the config
+// itself and a reload does not copy it again
+func applyFallback(c Configer, fallback Configer) {
+	if fallback == nil || fallback.getParser() == nil {
+		return
+	}
+	parser, fbParser := c.getParser(), fallback.getParser()
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			if parser.IsSet(def.Name) || !fbParser.IsSet(def.Name) {
+				return
+			}
+			parser.SetDefault(def.Name, fbParser.Get(def.Name))
+		},
+	)
+}
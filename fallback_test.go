@@ -0,0 +1,41 @@
+package coil
+
+import "testing"
+
+// FallbackBaseCfg for fallback testing, sharing keys with DatabaseConfig
+type FallbackBaseCfg struct {
+	Config
+	Host string `type:"string" name:"dbhost" default:"base.example.com" desc:"Database hostname"`
+	Name string `type:"string" name:"dbname" default:""                 desc:"Database name"`
+	Port int    `type:"int"    name:"dbport" default:"5432"             desc:"Database port number"`
+}
+
+func TestWithFallback(t *testing.T) {
+	base, err := NewConfigFromMap(
+		map[string]interface{}{"dbname": "org", "dbport": 6000},
+		&FallbackBaseCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	c, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--dbport=7000"}),
+		WithFallback(base),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg := c.(*RemoteCfg)
+	if cfg.DBName != "org" {
+		t.Errorf("DBName = %q, want the fallback value", cfg.DBName)
+	}
+	if cfg.DBPort != 7000 {
+		t.Errorf("DBPort = %d, want the flag value 7000", cfg.DBPort)
+	}
+	// Defaults of the fallback do not replace those of the config
+	if cfg.DBHost != "localhost" {
+		t.Errorf("DBHost = %q, want the config's default", cfg.DBHost)
+	}
+}
@@ -0,0 +1,213 @@
+// Package gcp implements a coil remote source backed by Google Cloud
+// Secret Manager
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/cvlstack/coil"
+	"github.com/cvlstack/coil/remote"
+)
+
+const defaultPollInterval = 30 * time.Second
+
+// Option customises a Secret Manager source
+type Option func(*source)
+
+// WithPollInterval sets how often Watch checks the secrets for a new
+// version, as Secret Manager has no change notifications. Defaults to 30
+// seconds
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *source) {
+		s.pollInterval = interval
+	}
+}
+
+// WithContext stops Watch polling once ctx is done, closing the channels
+// it returned. Without it the poller runs as long as the program
+func WithContext(ctx context.Context) Option {
+	return func(s *source) {
+		s.ctx = ctx
+	}
+}
+
+// source reads one secret per key from a project
+type source struct {
+	project      string
+	pollInterval time.Duration
+	ctx          context.Context
+
+	once   sync.Once
+	client *secretmanager.Client
+	err    error
+	dial   func() (*secretmanager.Client, error)
+
+	watchMu  sync.Mutex
+	watchers []*watcher
+}
+
+// watcher is a key passed to Watch and the last value sent for it
+type watcher struct {
+	key  string
+	last string
+	ch   chan string
+}
+
+// send hands val to the watcher without waiting for it to be received,
+// replacing a value still waiting, so one idle receiver cannot hold up
+// the others. Only the poller sends
+func (w *watcher) send(val string) {
+	select {
+	case <-w.ch:
+	default:
+	}
+	w.ch <- val
+}
+
+// newSource returns a source for project with opts applied
+func newSource(project string, opts []Option) *source {
+	s := &source{
+		project:      project,
+		pollInterval: defaultPollInterval,
+		ctx:          context.Background(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SecretManagerSource returns a source reading the key dbhost from the
+// latest version of the secret projects/<project>/secrets/dbhost. Keys
+// without a secret are left to other sources. Other errors reading one
+// wrap remote.ErrUnreadable, so the key keeps its default rather than
+// failing the load, unless it is required
+func SecretManagerSource(
+	project string,
+	client *secretmanager.Client,
+	opts ...Option,
+) remote.RemoteSource {
+	s := newSource(project, opts)
+	s.client = client
+	return s
+}
+
+// WithSecretManagerSource reads keys from the Secret Manager of project,
+// like coil.WithRemoteSource(SecretManagerSource(project, client)), with a
+// client using Application Default Credentials. Failing to create the
+// client fails the load
+func WithSecretManagerSource(project string, opts ...Option) coil.Option {
+	s := newSource(project, opts)
+	s.dial = func() (*secretmanager.Client, error) {
+		return secretmanager.NewClient(context.Background())
+	}
+	return coil.WithRemoteSource(s)
+}
+
+// secretClient returns the client, creating it on first use
+func (s *source) secretClient() (*secretmanager.Client, error) {
+	s.once.Do(func() {
+		if s.client == nil && s.dial != nil {
+			s.client, s.err = s.dial()
+		}
+	})
+	if s.err != nil {
+		return nil, fmt.Errorf(
+			"could not create secret manager client: %w",
+			s.err,
+		)
+	}
+	return s.client, nil
+}
+
+// Get returns the latest version of the secret named key
+func (s *source) Get(key string) (string, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext returns the latest version of the secret named key, giving up
+// once ctx is done
+func (s *source) GetContext(ctx context.Context, key string) (string, error) {
+	client, err := s.secretClient()
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf(
+		"projects/%s/secrets/%s/versions/latest",
+		s.project,
+		key,
+	)
+	resp, err := client.AccessSecretVersion(
+		ctx,
+		&secretmanagerpb.AccessSecretVersionRequest{Name: name},
+	)
+	switch status.Code(err) {
+	case codes.OK:
+		return string(resp.GetPayload().GetData()), nil
+	case codes.NotFound:
+		return "", remote.ErrNotFound
+	}
+	if ctx.Err() != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%w: %w", remote.ErrUnreadable, err)
+}
+
+// Watch returns a channel receiving the value of key each time a new
+// version of its secret is added. A single poller per source reads every
+// watched secret each poll interval. A value not yet received is
+// replaced by the next one
+func (s *source) Watch(key string) (<-chan string, error) {
+	last, err := s.Get(key)
+	if err != nil && !errors.Is(err, remote.ErrNotFound) {
+		return nil, err
+	}
+	ch := make(chan string, 1)
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	s.watchers = append(s.watchers, &watcher{key: key, last: last, ch: ch})
+	if len(s.watchers) == 1 {
+		go s.poll()
+	}
+	return ch, nil
+}
+
+// poll reads the watched secrets every poll interval and sends the changed
+// values to their watchers, until the source's context is done
+func (s *source) poll() {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.watchMu.Lock()
+			for _, w := range s.watchers {
+				close(w.ch)
+			}
+			s.watchers = nil
+			s.watchMu.Unlock()
+			return
+		case <-ticker.C:
+		}
+		s.watchMu.Lock()
+		watchers := append([]*watcher(nil), s.watchers...)
+		s.watchMu.Unlock()
+		for _, w := range watchers {
+			val, err := s.GetContext(s.ctx, w.key)
+			if err != nil || val == w.last {
+				continue
+			}
+			w.last = val
+			w.send(val)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/cvlstack/coil"
+	"github.com/cvlstack/coil/remote"
+)
+
+// fakeServer serves secrets by resource name
+type fakeServer struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+// set adds a new version of the secret name
+func (s *fakeServer) set(name, val string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[name] = val
+}
+
+func (s *fakeServer) AccessSecretVersion(
+	ctx context.Context,
+	req *secretmanagerpb.AccessSecretVersionRequest,
+) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	if req.Name == "projects/app/secrets/slow/versions/latest" {
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if req.Name == "projects/app/secrets/dbuser/versions/latest" {
+		return nil, status.Error(codes.PermissionDenied, "denied")
+	}
+	s.mu.Lock()
+	val, ok := s.secrets[req.Name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, status.Error(codes.NotFound, "no such secret")
+	}
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name:    req.Name,
+		Payload: &secretmanagerpb.SecretPayload{Data: []byte(val)},
+	}, nil
+}
+
+// testClient returns a client of a fake Secret Manager serving secrets
+func testClient(
+	t *testing.T,
+	secrets map[string]string,
+) *secretmanager.Client {
+	t.Helper()
+	client, _ := testServer(t, secrets)
+	return client
+}
+
+// testServer is testClient also returning the fake server, so a test can
+// add secret versions
+func testServer(
+	t *testing.T,
+	secrets map[string]string,
+) (*secretmanager.Client, *fakeServer) {
+	t.Helper()
+	fake := &fakeServer{secrets: secrets}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	secretmanagerpb.RegisterSecretManagerServiceServer(srv, fake)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	client, err := secretmanager.NewClient(
+		context.Background(),
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, fake
+}
+
+func TestSecretManagerSourceGet(t *testing.T) {
+	client := testClient(t, map[string]string{
+		"projects/app/secrets/dbpass/versions/latest": "hunter2",
+	})
+	src := SecretManagerSource("app", client)
+	val, err := src.Get("dbpass")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if val != "hunter2" {
+		t.Errorf("Get() = %q, want %q", val, "hunter2")
+	}
+	if _, err := src.Get("missing"); !errors.Is(err, remote.ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+	_, err = src.Get("dbuser")
+	if !errors.Is(err, remote.ErrUnreadable) ||
+		status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Get() error = %v, want an unreadable PermissionDenied", err)
+	}
+}
+
+func TestSecretManagerSourceGetContext(t *testing.T) {
+	src := SecretManagerSource("app", testClient(t, nil))
+	cs, ok := src.(remote.ContextSource)
+	if !ok {
+		t.Fatal("SecretManagerSource() does not implement remote.ContextSource")
+	}
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		20*time.Millisecond,
+	)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := cs.GetContext(ctx, "slow")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("GetContext() error = %v, want DeadlineExceeded", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("GetContext() did not give up at the deadline")
+	}
+}
+
+// AppCfg for loading through Secret Manager
+type AppCfg struct {
+	coil.Config
+	coil.DatabaseConfig
+	Token string `type:"string" name:"api_token" required:"true"`
+}
+
+// DeniedCfg requires a key whose secret cannot be read
+type DeniedCfg struct {
+	coil.Config
+	User string `type:"string" name:"dbuser" required:"true"`
+}
+
+func TestSecretManagerSourceRequired(t *testing.T) {
+	client := testClient(t, map[string]string{
+		"projects/app/secrets/dbpass/versions/latest":    "hunter2",
+		"projects/app/secrets/api_token/versions/latest": "t0k3n",
+	})
+	c, err := coil.NewConfig(
+		&AppCfg{},
+		coil.WithMerge(false),
+		coil.WithArgs([]string{}),
+		coil.WithRemoteSource(SecretManagerSource("app", client)),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg := c.(*AppCfg)
+	if cfg.DBPass != "hunter2" || cfg.Token != "t0k3n" {
+		t.Errorf(
+			"DBPass, Token = %q, %q, want the secrets",
+			cfg.DBPass,
+			cfg.Token,
+		)
+	}
+	// The unreadable dbuser secret falls back to its default
+	if cfg.DBUser != "" {
+		t.Errorf("DBUser = %q, want the default", cfg.DBUser)
+	}
+
+	client = testClient(t, nil)
+	_, err = coil.NewConfig(
+		&AppCfg{},
+		coil.WithMerge(false),
+		coil.WithArgs([]string{}),
+		coil.WithRemoteSource(SecretManagerSource("app", client)),
+	)
+	if !errors.Is(err, coil.ErrRequired) {
+		t.Errorf("NewConfig() error = %v, want ErrRequired", err)
+	}
+
+	// A required key whose secret cannot be read fails with the read error
+	client = testClient(t, nil)
+	_, err = coil.NewConfig(
+		&DeniedCfg{},
+		coil.WithMerge(false),
+		coil.WithArgs([]string{}),
+		coil.WithRemoteSource(SecretManagerSource("app", client)),
+	)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("NewConfig() error = %v, want PermissionDenied", err)
+	}
+}
+
+func TestSecretManagerSourceWatch(t *testing.T) {
+	client, fake := testServer(t, map[string]string{
+		"projects/app/secrets/dbpass/versions/latest": "hunter2",
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := SecretManagerSource(
+		"app",
+		client,
+		WithPollInterval(10*time.Millisecond),
+		WithContext(ctx),
+	)
+	ch, err := src.Watch("dbpass")
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	fake.set("projects/app/secrets/dbpass/versions/latest", "rotated")
+	select {
+	case val := <-ch:
+		if val != "rotated" {
+			t.Errorf("Watch() = %q, want %q", val, "rotated")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch() did not report the new version")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("Watch() sent a value after ctx was cancelled")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch() channel not closed after ctx was cancelled")
+	}
+}
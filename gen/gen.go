@@ -0,0 +1,367 @@
+// Package gen generates strongly typed accessors for coil configs from
+// their source, using go/types rather than reflection so misspelt fields
+// fail at build time. It backs the coilgen command
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/cvlstack/coil"
+)
+
+// coilPath is the import path of the coil package
+const coilPath = "github.com/cvlstack/coil"
+
+// DefaultOutput is the file name coilgen writes by default
+const DefaultOutput = "zz_coil_generated.go"
+
+// field is a config field reachable from a config struct
+type field struct {
+	// name is the accessor suffix, e.g. DBHost or PrimaryDBHost
+	name string
+	// path selects the field from the config, e.g. DatabaseConfig.DBHost
+	path string
+	typ  types.Type
+	key  string
+}
+
+// config is a struct embedding coil.Config and its fields
+type config struct {
+	name     string
+	fields   []field
+	methods  map[string]bool
+	validate bool
+}
+
+// Generate returns the source of the accessor file for the package in dir:
+// for every struct embedding coil.Config a getter and setter per config
+// field, a Keys method listing the flag names and, unless the struct
+// already has one, a Validate stub. The file named output is ignored while
+// loading, so accessors generated earlier do not affect the result
+func Generate(dir, output string) ([]byte, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedTypes | packages.NeedSyntax |
+			packages.NeedTypesInfo | packages.NeedImports,
+		Dir: dir,
+	}
+	stale := filepath.Join(dir, output)
+	if src, err := os.ReadFile(stale); err == nil {
+		// Blank out the previous output, which may no longer compile
+		f, err := parser.ParseFile(
+			token.NewFileSet(),
+			stale,
+			src,
+			parser.PackageClauseOnly,
+		)
+		if err != nil {
+			return nil, err
+		}
+		abs, err := filepath.Abs(stale)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Overlay = map[string][]byte{
+			abs: []byte("package " + f.Name.Name + "\n"),
+		}
+	}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s", dir)
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return nil, pkg.Errors[0]
+	}
+	configs, err := findConfigs(pkg.Types)
+	if err != nil {
+		return nil, err
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no struct in %s embeds coil.Config", pkg.Name)
+	}
+	return render(pkg.Types, configs)
+}
+
+// findConfigs returns the structs of the package that embed coil.Config,
+// sorted by name
+func findConfigs(pkg *types.Package) ([]*config, error) {
+	var configs []*config
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+		st, ok := tn.Type().Underlying().(*types.Struct)
+		if !ok || !embedsConfig(st) {
+			continue
+		}
+		c := &config{name: name, methods: make(map[string]bool)}
+		mset := types.NewMethodSet(types.NewPointer(tn.Type()))
+		for i := 0; i < mset.Len(); i++ {
+			c.methods[mset.At(i).Obj().Name()] = true
+		}
+		c.validate = !c.methods["Validate"]
+		if err := c.collect(st, "", "", ""); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		configs = append(configs, c)
+	}
+	return configs, nil
+}
+
+// collect records the config fields of st. Fields of named nested structs
+// are prefixed with the field name, while embedded structs are promoted as
+// Go promotes their fields
+func (c *config) collect(st *types.Struct, name, path, prefix string) error {
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		_, isStruct := f.Type().Underlying().(*types.Struct)
+		if !f.Exported() && !(f.Anonymous() && isStruct) ||
+			isCoilType(f.Type(), "Config") {
+			continue
+		}
+		tags, err := coil.ParseTags(reflect.StructTag(st.Tag(i)))
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name(), err)
+		}
+		if tags["skip"] == "true" {
+			continue
+		}
+		fieldPath := f.Name()
+		if path != "" {
+			fieldPath = path + "." + f.Name()
+		}
+		if nested, ok := nestedStruct(f.Type()); ok &&
+			tags["type"] != "json" {
+			nestedName := name
+			if !f.Anonymous() {
+				nestedName += f.Name()
+			}
+			err := c.collect(
+				nested,
+				nestedName,
+				fieldPath,
+				joinPrefix(prefix, tags["prefix"]),
+			)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if tags["name"] == "" {
+			continue
+		}
+		c.fields = append(c.fields, field{
+			name: name + f.Name(),
+			path: fieldPath,
+			typ:  f.Type(),
+			key:  joinPrefix(prefix, tags["name"]),
+		})
+	}
+	return nil
+}
+
+// render formats the accessor file for the configs of pkg
+func render(pkg *types.Package, configs []*config) ([]byte, error) {
+	imports := make(map[string]string)
+	qualifier := func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		imports[p.Path()] = p.Name()
+		return p.Name()
+	}
+	var body bytes.Buffer
+	for _, c := range configs {
+		if err := c.render(&body, qualifier); err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by coilgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg.Name())
+	if len(imports) > 0 {
+		paths := make([]string, 0, len(imports))
+		for path := range imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		b.WriteString("import (\n")
+		for _, path := range paths {
+			fmt.Fprintf(&b, "\t%s\n", strconv.Quote(path))
+		}
+		b.WriteString(")\n\n")
+	}
+	b.Write(body.Bytes())
+	return format.Source(b.Bytes())
+}
+
+// render writes the methods of one config
+func (c *config) render(b *bytes.Buffer, qualifier types.Qualifier) error {
+	seen := make(map[string]string)
+	for _, f := range c.fields {
+		for _, method := range []string{"Get" + f.name, "Set" + f.name} {
+			if other, ok := seen[method]; ok {
+				return fmt.Errorf(
+					"%s: %s and %s both generate %s",
+					c.name,
+					other,
+					f.path,
+					method,
+				)
+			}
+			seen[method] = f.path
+			if c.methods[method] {
+				return fmt.Errorf(
+					"%s: method %s already exists",
+					c.name,
+					method,
+				)
+			}
+		}
+		typ := types.TypeString(f.typ, qualifier)
+		fmt.Fprintf(b, "// Get%s returns the value of the %s key\n", f.name, f.key)
+		fmt.Fprintf(
+			b,
+			"func (c *%s) Get%s() %s {\n\treturn c.%s\n}\n\n",
+			c.name,
+			f.name,
+			typ,
+			f.path,
+		)
+		fmt.Fprintf(b, "// Set%s sets the value of the %s key\n", f.name, f.key)
+		fmt.Fprintf(
+			b,
+			"func (c *%s) Set%s(v %s) {\n\tc.%s = v\n}\n\n",
+			c.name,
+			f.name,
+			typ,
+			f.path,
+		)
+	}
+
+	if c.methods["Keys"] {
+		return fmt.Errorf("%s: method Keys already exists", c.name)
+	}
+	keys := make([]string, len(c.fields))
+	for i, f := range c.fields {
+		keys[i] = strconv.Quote(f.key)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(
+		b,
+		"// Keys returns the flag names of %s, without any WithPrefix prefix\n",
+		c.name,
+	)
+	fmt.Fprintf(
+		b,
+		"func (c *%s) Keys() []string {\n\treturn []string{%s}\n}\n\n",
+		c.name,
+		strings.Join(keys, ", "),
+	)
+
+	if c.validate {
+		fmt.Fprintf(
+			b,
+			"// Validate checks the values of %s once it has been populated.\n"+
+				"// Declare your own Validate and regenerate to replace it\n",
+			c.name,
+		)
+		fmt.Fprintf(
+			b,
+			"func (c *%s) Validate() error {\n\treturn nil\n}\n\n",
+			c.name,
+		)
+	}
+	return nil
+}
+
+// embedsConfig reports whether a struct embeds coil.Config
+func embedsConfig(st *types.Struct) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i).Anonymous() && isCoilType(st.Field(i).Type(), "Config") {
+			return true
+		}
+	}
+	return false
+}
+
+// isCoilType reports whether t is the named type of the coil package
+func isCoilType(t types.Type, name string) bool {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return named.Obj().Pkg().Path() == coilPath && named.Obj().Name() == name
+}
+
+// nestedStruct returns the struct of a field whose own fields are config
+// fields, which excludes time.Time, url.URL and types with an
+// UnmarshalText method
+func nestedStruct(t types.Type) (*types.Struct, bool) {
+	if isTextType(t) {
+		return nil, false
+	}
+	if named, ok := t.(*types.Named); ok {
+		obj := named.Obj()
+		if obj.Pkg() != nil && (obj.Pkg().Path() == "time" &&
+			obj.Name() == "Time" || obj.Pkg().Path() == "net/url" &&
+			obj.Name() == "URL") {
+			return nil, false
+		}
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	return st, ok
+}
+
+// isTextType reports whether a type has an UnmarshalText method, through
+// its pointer if need be
+func isTextType(t types.Type) bool {
+	obj, _, _ := types.LookupFieldOrMethod(
+		types.NewPointer(t),
+		true,
+		nil,
+		"UnmarshalText",
+	)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// joinPrefix prepends a non-empty prefix to name
+func joinPrefix(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	if name == "" {
+		return prefix
+	}
+	return prefix + "_" + name
+}
+
+// Write generates the accessor file for the package in dir and writes it
+// to output within dir
+func Write(dir, output string) error {
+	src, err := Generate(dir, output)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, output), src, 0o644)
+}
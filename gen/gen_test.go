@@ -0,0 +1,45 @@
+package gen
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	// testdata/app holds a stale zz_coil_generated.go that no longer
+	// compiles, which Generate must ignore
+	src, err := Generate("testdata/app", DefaultOutput)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "", src, 0); err != nil {
+		t.Fatalf("Generate() output does not parse: %v\n%s", err, src)
+	}
+	out := string(src)
+	for _, want := range []string{
+		"// Code generated by coilgen. DO NOT EDIT.",
+		"func (c *AppConfig) GetDBHost() string {\n\treturn c.DatabaseConfig.DBHost\n}",
+		"func (c *AppConfig) SetDBHost(v string) {\n\tc.DatabaseConfig.DBHost = v\n}",
+		"func (c *AppConfig) GetReplicaDBHost() string {\n\treturn c.Replica.DBHost\n}",
+		"func (c *AppConfig) GetTimeout() time.Duration {",
+		`"replica_dbhost"`,
+		"func (c *AppConfig) Validate() error {",
+		"func (c *ValidatedConfig) Keys() []string {\n\treturn []string{\"port\"}\n}",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Generate() missing %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{
+		"GetNotes",
+		"GetRemoved",
+		"func (c *ValidatedConfig) Validate",
+		"Plain",
+	} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("Generate() should not contain %q", unwanted)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"time"
+
+	"github.com/cvlstack/coil"
+)
+
+type AppConfig struct {
+	coil.Config
+	coil.DatabaseConfig
+	Replica coil.DatabaseConfig `prefix:"replica"`
+	Name    string              `type:"string"   name:"name"    default:"app"`
+	Timeout time.Duration       `type:"duration" name:"timeout" default:"5s"`
+	Notes   string
+}
+
+type ValidatedConfig struct {
+	coil.Config
+	Port int `type:"int" name:"port" default:"8080"`
+}
+
+func (c *ValidatedConfig) Validate() error {
+	return nil
+}
+
+type Plain struct {
+	Port int `type:"int" name:"port" default:"8080"`
+}
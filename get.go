@@ -0,0 +1,81 @@
+package coil
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// GetString returns the live value of a config key as a string, formatted
+// the way the config reads it back from an env var, and whether the key
+// is registered. Secrets are included. Like the other getters it holds
+// the config's read lock, so it must not be called from Parse or Validate
+func GetString(c Configer, key string) (string, bool) {
+	b := c.base()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	def, fv, ok := lookupField(c, key)
+	if !ok || !fv.CanInterface() {
+		return "", false
+	}
+	val := exportValue(fv, def)
+	if val == nil {
+		return "", true
+	}
+	return envValue(val, def.Sep), true
+}
+
+// GetInt returns the live value of a config key as an int, and whether
+// the key is registered. Values that are not numbers yield 0
+func GetInt(c Configer, key string) (int, bool) {
+	val, ok := liveValue(c, key)
+	return cast.ToInt(val), ok
+}
+
+// GetBool returns the live value of a config key as a bool, and whether
+// the key is registered
+func GetBool(c Configer, key string) (bool, bool) {
+	val, ok := liveValue(c, key)
+	return cast.ToBool(val), ok
+}
+
+// GetFloat64 returns the live value of a config key as a float64, and
+// whether the key is registered
+func GetFloat64(c Configer, key string) (float64, bool) {
+	val, ok := liveValue(c, key)
+	return cast.ToFloat64(val), ok
+}
+
+// GetDuration returns the live value of a config key as a time.Duration,
+// and whether the key is registered
+func GetDuration(c Configer, key string) (time.Duration, bool) {
+	val, ok := liveValue(c, key)
+	return cast.ToDuration(val), ok
+}
+
+// GetStringSlice returns the live value of a config key as a []string,
+// and whether the key is registered
+func GetStringSlice(c Configer, key string) ([]string, bool) {
+	val, ok := liveValue(c, key)
+	return cast.ToStringSlice(val), ok
+}
+
+// liveValue returns the live value of a registered key, with pointer
+// fields dereferenced and nil when unset, and whether the key exists
+func liveValue(c Configer, key string) (interface{}, bool) {
+	b := c.base()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, fv, ok := lookupField(c, key)
+	if !ok || !fv.CanInterface() {
+		return nil, false
+	}
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil, true
+		}
+		fv = fv.Elem()
+	}
+	return fv.Interface(), true
+}
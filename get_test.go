@@ -0,0 +1,114 @@
+package coil
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// GetCfg for typed accessor testing
+type GetCfg struct {
+	Config
+	Name    string        `type:"string"   name:"get_name"    default:"svc"`
+	Port    int           `type:"int"      name:"get_port"    default:"8080"`
+	Small   int16         `type:"int"      name:"get_small"   default:"7"`
+	Debug   bool          `type:"bool"     name:"get_debug"   default:"true"`
+	Ratio   float32       `type:"float32"  name:"get_ratio"   default:"0.5"`
+	Timeout time.Duration `type:"duration" name:"get_timeout" default:"1m30s"`
+	Tags    []string      `type:"[]string" name:"get_tags"    default:"a;b"  sep:";"`
+	Unset   *int          `type:"int"      name:"get_unset"`
+}
+
+func TestTypedAccessors(t *testing.T) {
+	c, err := NewConfigFromMap(
+		map[string]interface{}{"get_port": "9090"},
+		&GetCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	if got, ok := GetString(c, "get_name"); got != "svc" || !ok {
+		t.Errorf("GetString(get_name) = %q, %v", got, ok)
+	}
+	if got, ok := GetString(c, "get_timeout"); got != "1m30s" || !ok {
+		t.Errorf("GetString(get_timeout) = %q, %v", got, ok)
+	}
+	if got, ok := GetString(c, "get_tags"); got != "a;b" || !ok {
+		t.Errorf("GetString(get_tags) = %q, %v", got, ok)
+	}
+	if got, ok := GetInt(c, "get_port"); got != 9090 || !ok {
+		t.Errorf("GetInt(get_port) = %d, %v", got, ok)
+	}
+	if got, ok := GetInt(c, "get_small"); got != 7 || !ok {
+		t.Errorf("GetInt(get_small) = %d, %v", got, ok)
+	}
+	if got, ok := GetBool(c, "get_debug"); !got || !ok {
+		t.Errorf("GetBool(get_debug) = %v, %v", got, ok)
+	}
+	if got, ok := GetFloat64(c, "get_ratio"); got != 0.5 || !ok {
+		t.Errorf("GetFloat64(get_ratio) = %v, %v", got, ok)
+	}
+	if got, ok := GetDuration(c, "get_timeout"); got != 90*time.Second || !ok {
+		t.Errorf("GetDuration(get_timeout) = %v, %v", got, ok)
+	}
+	got, ok := GetStringSlice(c, "get_tags")
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) || !ok {
+		t.Errorf("GetStringSlice(get_tags) = %q, %v", got, ok)
+	}
+
+	// Unset pointers exist with the zero value, unknown keys do not
+	if got, ok := GetInt(c, "get_unset"); got != 0 || !ok {
+		t.Errorf("GetInt(get_unset) = %d, %v", got, ok)
+	}
+	if got, ok := GetString(c, "get_unset"); got != "" || !ok {
+		t.Errorf("GetString(get_unset) = %q, %v", got, ok)
+	}
+	if _, ok := GetString(c, "missing"); ok {
+		t.Error("GetString(missing) reported the key as registered")
+	}
+	if _, ok := GetBool(c, "missing"); ok {
+		t.Error("GetBool(missing) reported the key as registered")
+	}
+}
+
+// Run with -race to check the getters hold the read lock while the values
+// are rewritten
+func TestGettersConcurrentWrites(t *testing.T) {
+	c, err := NewConfigFromMap(nil, &GetCfg{})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			GetString(c, "get_name")
+			GetInt(c, "get_port")
+			GetStringSlice(c, "get_tags")
+		}
+	}()
+	for i := 1; i <= 50; i++ {
+		err := c.base().MergeFromMap(map[string]interface{}{
+			"get_name": "svc" + strconv.Itoa(i),
+			"get_port": i,
+			"get_tags": "x;y",
+		})
+		if err != nil {
+			t.Errorf("MergeFromMap() error = %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	if got, _ := GetString(c, "get_name"); got != "svc50" {
+		t.Errorf("GetString(get_name) = %q, want svc50", got)
+	}
+}
@@ -0,0 +1,33 @@
+// Package gin provides gin middleware making a coil config available to
+// handlers through their context rather than a package level variable
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/cvlstack/coil"
+)
+
+// ContextKey is the key the config is stored under in the gin context
+const ContextKey = "coil.config"
+
+// Inject stores c in the context of every request, where handlers read it
+// with FromContext. Tests can pass a config built with
+// coil.NewConfigFromMap instead of the one the program loads
+func Inject(c coil.Configer) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(ContextKey, c)
+		ctx.Next()
+	}
+}
+
+// FromContext returns the config stored by Inject, or nil if the
+// middleware did not run for the request
+func FromContext(ctx *gin.Context) coil.Configer {
+	val, ok := ctx.Get(ContextKey)
+	if !ok {
+		return nil
+	}
+	c, _ := val.(coil.Configer)
+	return c
+}
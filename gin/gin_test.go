@@ -0,0 +1,51 @@
+package gin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cvlstack/coil"
+)
+
+// GinCfg for middleware testing
+type GinCfg struct {
+	coil.Config
+	Greeting string `type:"string" name:"gin_greeting" default:"hello"`
+}
+
+func TestInject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, err := coil.NewConfigFromMap(
+		map[string]interface{}{"gin_greeting": "hi"},
+		&GinCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	r := gin.New()
+	r.Use(Inject(c))
+	r.GET("/", func(ctx *gin.Context) {
+		cfg, ok := FromContext(ctx).(*GinCfg)
+		if !ok {
+			ctx.String(http.StatusInternalServerError, "no config")
+			return
+		}
+		ctx.String(http.StatusOK, cfg.Greeting)
+	})
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hi" {
+		t.Errorf("GET / = %d %q, want 200 hi", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFromContextWithoutInject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if c := FromContext(ctx); c != nil {
+		t.Errorf("FromContext() = %v, want nil", c)
+	}
+}
@@ -0,0 +1,41 @@
+package coil
+
+import "sync/atomic"
+
+// global holds the config set with SetGlobalConfig. The config is wrapped
+// as atomic.Value requires every stored value to have the same type
+var global atomic.Value
+
+// globalConfig is the value stored in global
+type globalConfig struct {
+	c Configer
+}
+
+// SetGlobalConfig makes c the config returned by GlobalConfig, like
+// slog.SetDefault. It is safe to call while other goroutines read the
+// global config, which see either the old or the new one
+func SetGlobalConfig(c Configer) {
+	global.Store(globalConfig{c})
+}
+
+// GlobalConfig returns the config set with SetGlobalConfig or Init. It
+// panics if none was set, as reading a config that was never loaded is a
+// programming error
+func GlobalConfig() Configer {
+	g, _ := global.Load().(globalConfig)
+	if g.c == nil {
+		panic("coil: GlobalConfig called before SetGlobalConfig or Init")
+	}
+	return g.c
+}
+
+// Init loads c like NewConfig and makes it the global config. The global
+// config is left unchanged if c cannot be loaded
+func Init(c Configer, opts ...Option) (Configer, error) {
+	cfg, err := NewConfig(c, opts...)
+	if err != nil {
+		return cfg, err
+	}
+	SetGlobalConfig(cfg)
+	return cfg, nil
+}
@@ -0,0 +1,64 @@
+package coil
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGlobalConfig(t *testing.T) {
+	defer global.Store(globalConfig{})
+	global.Store(globalConfig{})
+
+	func() {
+		defer func() {
+			r := recover()
+			msg, _ := r.(string)
+			if !strings.Contains(msg, "SetGlobalConfig") {
+				t.Errorf("GlobalConfig() panic = %v, want a clear message", r)
+			}
+		}()
+		GlobalConfig()
+	}()
+
+	cfg, err := Init(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--dbhost=db.internal"}),
+	)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if got := GlobalConfig(); got != cfg {
+		t.Errorf("GlobalConfig() = %v, want the config from Init", got)
+	}
+	if got := GlobalConfig().(*RemoteCfg).DBHost; got != "db.internal" {
+		t.Errorf("DBHost = %q, want db.internal", got)
+	}
+
+	if _, err := Init(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--dbport=0"}),
+	); err == nil {
+		t.Error("Init() with an invalid port should return an error")
+	}
+	if got := GlobalConfig(); got != cfg {
+		t.Error("a failed Init() replaced the global config")
+	}
+
+	// Configs of different types can replace each other while being read
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = GlobalConfig()
+			}
+		}()
+	}
+	SetGlobalConfig(&Config{})
+	SetGlobalConfig(cfg)
+	wg.Wait()
+}
@@ -0,0 +1,141 @@
+package coil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// ConfigGroup populates several configs from one command line and one
+// parser, e.g. a gateway loading the configs of the services behind it.
+// Each member can be namespaced with WithPrefix so their keys do not
+// collide
+type ConfigGroup struct {
+	opts    []Option
+	members []groupMember
+	err     error
+}
+
+// groupMember is a config registered with a ConfigGroup
+type groupMember struct {
+	name   string
+	config Configer
+	opts   []Option
+}
+
+// NewConfigGroup creates an empty group. The options apply to every member
+// and may include WithViper to share an existing parser
+func NewConfigGroup(opts ...Option) *ConfigGroup {
+	return &ConfigGroup{opts: opts}
+}
+
+// Add registers a config under name. The options apply to this member only,
+// on top of the group's
+func (g *ConfigGroup) Add(
+	name string,
+	c Configer,
+	opts ...Option,
+) *ConfigGroup {
+	if g.err != nil {
+		return g
+	}
+	if g.Get(name) != nil {
+		g.err = fmt.Errorf("duplicate config %q", name)
+		return g
+	}
+	g.members = append(g.members, groupMember{name, c, opts})
+	return g
+}
+
+// Get returns the config registered under name, or nil
+func (g *ConfigGroup) Get(name string) Configer {
+	for _, m := range g.members {
+		if m.name == name {
+			return m.config
+		}
+	}
+	return nil
+}
+
+// Parse defines the flags of every member on one flagset, parses it once
+// and populates each member from a shared parser. Flags defined by more
+// than one member are an error. Validation errors of all members are
+// returned together, each prefixed with its member's name
+func (g *ConfigGroup) Parse() error {
+	if g.err != nil {
+		return g.err
+	}
+	shared := newOptions(g.opts).viper
+	if shared == nil {
+		shared = viper.New()
+	}
+	opts := make([]*options, len(g.members))
+	fs := newFlagSet()
+	owners := make(map[string]string)
+	for i, m := range g.members {
+		memberOpts := append([]Option{}, g.opts...)
+		memberOpts = append(memberOpts, m.opts...)
+		o := newOptions(append(memberOpts, WithViper(shared)))
+		opts[i] = o
+		t := reflect.TypeOf(m.config).Elem()
+		if err := checkFieldPrefixes(t, o.fieldPrefixes); err != nil {
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+		m.config.base().prefix = o.prefix
+		m.config.base().fieldPrefixes = o.fieldPrefixes
+		mfs := pflag.NewFlagSet(m.name, pflag.ContinueOnError)
+		err := defineFlagsFromStructWithPrefix(
+			t,
+			mfs,
+			o.prefix,
+			o.fieldPrefixes,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+		mfs.VisitAll(func(f *pflag.Flag) {
+			if owner, ok := owners[f.Name]; ok {
+				if err == nil {
+					err = fmt.Errorf(
+						"flag %q is defined by both %q and %q",
+						f.Name,
+						owner,
+						m.name,
+					)
+				}
+				return
+			}
+			owners[f.Name] = m.name
+			fs.AddFlag(f)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for i, m := range g.members {
+		if err := parseFlags(m.config, fs, opts[i]); err != nil {
+			return err
+		}
+	}
+	var errs ValidationErrors
+	for i, m := range g.members {
+		err := populate(context.Background(), m.config, opts[i])
+		if err == nil {
+			continue
+		}
+		memberErrs, ok := err.(ValidationErrors)
+		if !ok {
+			memberErrs = ValidationErrors{err}
+		}
+		for _, err := range memberErrs {
+			errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
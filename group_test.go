@@ -0,0 +1,100 @@
+package coil
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// GroupDBCfg for group testing
+type GroupDBCfg struct {
+	Config
+	DB DatabaseConfig
+}
+
+// GroupRequiredCfg for group validation testing
+type GroupRequiredCfg struct {
+	Config
+	Token string `type:"string" name:"group_token" desc:"Token" required:"true"`
+}
+
+func TestConfigGroup(t *testing.T) {
+	origVal := os.Getenv("ORDERS_DBPORT")
+	os.Setenv("ORDERS_DBPORT", "6000")
+	defer restoreEnv("ORDERS_DBPORT", origVal)
+
+	v := viper.New()
+	orders, users := &GroupDBCfg{}, &GroupDBCfg{}
+	err := NewConfigGroup(
+		WithMerge(false),
+		WithViper(v),
+		WithArgs([]string{"--users_dbhost=users.internal"}),
+	).
+		Add("orders", orders, WithPrefix("orders")).
+		Add("users", users, WithPrefix("users")).
+		Parse()
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if orders.DB.DBHost != "localhost" || orders.DB.DBPort != 6000 {
+		t.Errorf(
+			"orders = %s:%d, want localhost:6000",
+			orders.DB.DBHost,
+			orders.DB.DBPort,
+		)
+	}
+	if users.DB.DBHost != "users.internal" || users.DB.DBPort != 5432 {
+		t.Errorf(
+			"users = %s:%d, want users.internal:5432",
+			users.DB.DBHost,
+			users.DB.DBPort,
+		)
+	}
+	if orders.getParser() != v || users.getParser() != v {
+		t.Error("members should share the parser given by WithViper")
+	}
+	if _, ok := ToMap(users)["users_dbhost"]; !ok {
+		t.Errorf("ToMap() keys = %v, want users_ prefix", ToMap(users))
+	}
+}
+
+func TestConfigGroupGet(t *testing.T) {
+	orders := &GroupDBCfg{}
+	g := NewConfigGroup().Add("orders", orders, WithPrefix("orders"))
+	if g.Get("orders") != orders {
+		t.Error("Get(orders) did not return the registered config")
+	}
+	if g.Get("missing") != nil {
+		t.Error("Get(missing) should return nil")
+	}
+}
+
+func TestConfigGroupErrors(t *testing.T) {
+	err := NewConfigGroup(WithMerge(false), WithArgs(nil)).
+		Add("a", &GroupDBCfg{}).
+		Add("b", &GroupDBCfg{}).
+		Parse()
+	if err == nil || !strings.Contains(err.Error(), "defined by both") {
+		t.Errorf("Parse() error = %v, want flag collision", err)
+	}
+
+	err = NewConfigGroup().
+		Add("a", &GroupDBCfg{}).
+		Add("a", &GroupDBCfg{}).
+		Parse()
+	if err == nil || !strings.Contains(err.Error(), "duplicate config") {
+		t.Errorf("Parse() error = %v, want duplicate config", err)
+	}
+
+	err = NewConfigGroup(WithMerge(false), WithArgs(nil)).
+		Add("db", &GroupDBCfg{}).
+		Add("auth", &GroupRequiredCfg{}).
+		Parse()
+	if !errors.Is(err, ErrRequired) ||
+		!strings.Contains(err.Error(), "auth: ") {
+		t.Errorf("Parse() error = %v, want auth ErrRequired", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Package httpclient provides a composable coil config for HTTP clients,
+// building a tuned *http.Client with optional retries
+package httpclient
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cvlstack/coil"
+)
+
+// HTTPClientConfig represents a composable struct for outgoing HTTP
+// clients
+type HTTPClientConfig struct {
+	HTTPTimeout             time.Duration  `type:"duration" name:"http_client_timeout"                 default:"30s"   desc:"Timeout for a whole request, including reading the body, 0 for none"                   min:"0s"`
+	HTTPDialTimeout         time.Duration  `type:"duration" name:"http_client_dial_timeout"            default:"10s"   desc:"Timeout for establishing connections"                                                  min:"0s"`
+	HTTPKeepAlive           time.Duration  `type:"duration" name:"http_client_keep_alive"              default:"30s"   desc:"Keep-alive period of connections, negative to close each connection after one request"`
+	HTTPMaxIdleConns        int            `type:"int"      name:"http_client_max_idle_conns"          default:"100"   desc:"Maximum number of idle connections across all hosts, 0 for no limit"                   min:"0"`
+	HTTPMaxIdleConnsPerHost int            `type:"int"      name:"http_client_max_idle_conns_per_host" default:"10"    desc:"Maximum number of idle connections per host"                                           min:"0"`
+	HTTPMaxConnsPerHost     int            `type:"int"      name:"http_client_max_conns_per_host"      default:"0"     desc:"Maximum number of connections per host, 0 for no limit"                                min:"0"`
+	HTTPIdleConnTimeout     time.Duration  `type:"duration" name:"http_client_idle_conn_timeout"       default:"90s"   desc:"How long an idle connection is kept open, 0 for no limit"                              min:"0s"`
+	HTTPTLSHandshakeTimeout time.Duration  `type:"duration" name:"http_client_tls_handshake_timeout"   default:"10s"   desc:"Timeout for TLS handshakes"                                                            min:"0s"`
+	HTTPProxyURL            string         `type:"string"   name:"http_client_proxy_url"               default:""      desc:"Proxy URL, empty to use HTTP_PROXY, HTTPS_PROXY and NO_PROXY"`
+	HTTPTLSEnabled          bool           `type:"bool"     name:"http_client_tls"                     default:"false" desc:"Apply the http_client_tls_* settings to HTTPS connections instead of Go's defaults"`
+	HTTPTLS                 coil.TLSConfig `prefix:"http_client"`
+	RetryCount              int            `type:"int"      name:"http_client_retry_count"             default:"0"     desc:"Times a failed idempotent request is retried, 0 to disable"                            min:"0"`
+	RetryBackoff            time.Duration  `type:"duration" name:"http_client_retry_backoff"           default:"100ms" desc:"Delay before the first retry, doubled for each further one"                            min:"0s"`
+}
+
+// Build returns an HTTP client for the timeout, connection pool, proxy and
+// TLS settings. When RetryCount is set, the transport retries idempotent
+// requests failing with a network error or a 429 or 5xx status
+func (c HTTPClientConfig) Build() (*http.Client, error) {
+	transport, err := c.transport()
+	if err != nil {
+		return nil, err
+	}
+	var rt http.RoundTripper = transport
+	if c.RetryCount > 0 {
+		rt = &retryTransport{
+			base:    transport,
+			count:   c.RetryCount,
+			backoff: c.RetryBackoff,
+		}
+	}
+	return &http.Client{Transport: rt, Timeout: c.HTTPTimeout}, nil
+}
+
+// transport returns the pooled transport for the connection settings
+func (c HTTPClientConfig) transport() (*http.Transport, error) {
+	proxy := http.ProxyFromEnvironment
+	if c.HTTPProxyURL != "" {
+		u, err := url.Parse(c.HTTPProxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", c.HTTPProxyURL)
+		}
+		proxy = http.ProxyURL(u)
+	}
+	tlsCfg, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   c.HTTPDialTimeout,
+		KeepAlive: c.HTTPKeepAlive,
+	}
+	return &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsCfg,
+		TLSHandshakeTimeout: c.HTTPTLSHandshakeTimeout,
+		DisableKeepAlives:   c.HTTPKeepAlive < 0,
+		MaxIdleConns:        c.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost: c.HTTPMaxIdleConnsPerHost,
+		MaxConnsPerHost:     c.HTTPMaxConnsPerHost,
+		IdleConnTimeout:     c.HTTPIdleConnTimeout,
+		// A custom dialer and TLS config turn off HTTP/2 unless asked for
+		ForceAttemptHTTP2: true,
+	}, nil
+}
+
+// tlsConfig builds the TLS settings, or returns nil for Go's defaults when
+// they are disabled
+func (c HTTPClientConfig) tlsConfig() (*tls.Config, error) {
+	if !c.HTTPTLSEnabled {
+		return nil, nil
+	}
+	return c.HTTPTLS.Build()
+}
+
+// retryTransport retries idempotent requests that fail with a network
+// error or a status the server may recover from, waiting backoff before
+// the first retry and doubling it for each further one
+type retryTransport struct {
+	base    http.RoundTripper
+	count   int
+	backoff time.Duration
+}
+
+// RoundTrip sends req, retrying it while it can be replayed
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	wait := t.backoff
+	for i := 0; i < t.count && retryable(req, resp, err); i++ {
+		if req.Body != nil && req.Body != http.NoBody {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				break
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		wait *= 2
+		resp, err = t.base.RoundTrip(req)
+	}
+	return resp, err
+}
+
+// retryable reports whether a request may be sent again after the given
+// outcome: it must be idempotent, replayable and not cancelled, and have
+// failed with a network error or a 429 or 5xx status
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= http.StatusInternalServerError
+}
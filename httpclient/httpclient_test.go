@@ -0,0 +1,146 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cvlstack/coil"
+)
+
+// HTTPClientCfg for tag testing
+type HTTPClientCfg struct {
+	coil.Config
+	coil.APIServiceConfig
+	HTTPClientConfig
+}
+
+func TestHTTPClientConfigDefaults(t *testing.T) {
+	cfg, err := coil.ParseArgs(&HTTPClientCfg{}, []string{
+		"--http_client_retry_count=2",
+		"--http_client_tls",
+		"--http_client_tls_min_version=1.3",
+	})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	c := cfg.(*HTTPClientCfg)
+	if c.HTTPTimeout != 30*time.Second || c.Timeout != 15*time.Second {
+		t.Errorf("HTTPTimeout = %v and Timeout = %v, want 30s and 15s",
+			c.HTTPTimeout, c.Timeout)
+	}
+	if c.RetryCount != 2 || c.RetryBackoff != 100*time.Millisecond {
+		t.Errorf("RetryCount = %d and RetryBackoff = %v, want 2 and 100ms",
+			c.RetryCount, c.RetryBackoff)
+	}
+	if !c.HTTPTLSEnabled || c.HTTPTLS.MinVersion != "1.3" {
+		t.Errorf("HTTPTLSEnabled = %v and HTTPTLS.MinVersion = %q, want "+
+			"true and 1.3", c.HTTPTLSEnabled, c.HTTPTLS.MinVersion)
+	}
+
+	args := []string{"--http_client_max_idle_conns=-1"}
+	if _, err := coil.ParseArgs(&HTTPClientCfg{}, args); err == nil {
+		t.Errorf("ParseArgs(%q) should return an error", args)
+	}
+}
+
+func TestHTTPClientConfigBuild(t *testing.T) {
+	client, err := HTTPClientConfig{
+		HTTPTimeout:             5 * time.Second,
+		HTTPMaxIdleConns:        20,
+		HTTPMaxIdleConnsPerHost: 4,
+		HTTPMaxConnsPerHost:     8,
+		HTTPKeepAlive:           -1,
+		HTTPProxyURL:            "http://proxy.internal:3128",
+		HTTPTLSEnabled:          true,
+		HTTPTLS: coil.TLSConfig{
+			VerifyPeer: true,
+			MinVersion: "1.3",
+		},
+	}.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if client.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want 5s", client.Timeout)
+	}
+	transport := client.Transport.(*http.Transport)
+	if transport.MaxIdleConns != 20 || transport.MaxIdleConnsPerHost != 4 ||
+		transport.MaxConnsPerHost != 8 {
+		t.Errorf("pool = %d/%d/%d, want 20/4/8", transport.MaxIdleConns,
+			transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+	if tlsCfg := transport.TLSClientConfig; tlsCfg == nil ||
+		tlsCfg.MinVersion != tls.VersionTLS13 || tlsCfg.InsecureSkipVerify {
+		t.Errorf("TLSClientConfig = %+v, want verified TLS 1.3", tlsCfg)
+	}
+	if !transport.DisableKeepAlives {
+		t.Error("DisableKeepAlives = false for a negative keep-alive")
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	proxy, err := transport.Proxy(req)
+	if err != nil || proxy.String() != "http://proxy.internal:3128" {
+		t.Errorf("Proxy() = %v, %v, want the proxy URL", proxy, err)
+	}
+
+	for _, cfg := range []HTTPClientConfig{
+		{HTTPProxyURL: "::"},
+		{HTTPTLSEnabled: true, HTTPTLS: coil.TLSConfig{MinVersion: "2.0"}},
+	} {
+		if _, err := cfg.Build(); err == nil {
+			t.Errorf("Build() with %+v should return an error", cfg)
+		}
+	}
+}
+
+func TestHTTPClientConfigRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write(body)
+		},
+	))
+	defer srv.Close()
+
+	client, err := HTTPClientConfig{
+		RetryCount:   2,
+		RetryBackoff: time.Millisecond,
+	}.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("x"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "x" {
+		t.Errorf("Do() = %d %q, want 200 with the replayed body",
+			resp.StatusCode, body)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("server called %d times, want 3", got)
+	}
+
+	calls.Store(0)
+	resp, err = client.Post(srv.URL, "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	resp.Body.Close()
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server called %d times for a POST, want 1", got)
+	}
+}
@@ -0,0 +1,212 @@
+// Package kafka provides a composable coil config for Kafka clients, built
+// with sarama or kafka-go
+package kafka
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	xdgscram "github.com/xdg-go/scram"
+
+	"github.com/cvlstack/coil"
+)
+
+// SASL mechanisms accepted by kafka_sasl_mechanism
+const (
+	MechanismPlain       = "PLAIN"
+	MechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	MechanismSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+// KafkaConfig represents a composable struct for Kafka consumers and
+// producers
+type KafkaConfig struct {
+	KafkaBrokers       []string       `type:"[]string" name:"kafka_brokers"        default:"localhost:9092" desc:"Kafka broker addresses"`
+	KafkaTopic         string         `type:"string"   name:"kafka_topic"          default:""               desc:"Topic to consume from or produce to"`
+	KafkaGroupID       string         `type:"string"   name:"kafka_group_id"       default:""               desc:"Consumer group ID"`
+	KafkaClientID      string         `type:"string"   name:"kafka_client_id"      default:""               desc:"Client ID sent to the brokers"`
+	KafkaVersion       string         `type:"string"   name:"kafka_version"        default:""               desc:"Broker protocol version for sarama, empty for its default"`
+	KafkaTLSEnabled    bool           `type:"bool"     name:"kafka_tls"            default:"false"          desc:"Connect using TLS"`
+	KafkaTLS           coil.TLSConfig `prefix:"kafka"`
+	KafkaSASLMechanism string         `type:"string"   name:"kafka_sasl_mechanism" default:""               desc:"SASL mechanism (PLAIN, SCRAM-SHA-256, SCRAM-SHA-512), empty to disable"`
+	KafkaSASLUser      string         `type:"string"   name:"kafka_sasl_user"      default:""               desc:"SASL username"`
+	KafkaSASLPass      string         `type:"string"   name:"kafka_sasl_pass"      default:""               desc:"SASL password"                                                          secret:"true"`
+	KafkaDialTimeout   time.Duration  `type:"duration" name:"kafka_dial_timeout"   default:"10s"            desc:"Timeout for establishing broker connections"`
+	KafkaReadTimeout   time.Duration  `type:"duration" name:"kafka_read_timeout"   default:"30s"            desc:"Timeout for broker responses"`
+	KafkaWriteTimeout  time.Duration  `type:"duration" name:"kafka_write_timeout"  default:"30s"            desc:"Timeout for broker requests"`
+}
+
+// SaramaConfig returns a validated sarama config for the TLS, SASL, client
+// and timeout settings. Brokers, topic and group ID are passed to the
+// sarama constructors separately
+func (c KafkaConfig) SaramaConfig() (*sarama.Config, error) {
+	cfg := sarama.NewConfig()
+	if c.KafkaClientID != "" {
+		cfg.ClientID = c.KafkaClientID
+	}
+	if c.KafkaVersion != "" {
+		version, err := sarama.ParseKafkaVersion(c.KafkaVersion)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Version = version
+	}
+	cfg.Net.DialTimeout = c.KafkaDialTimeout
+	cfg.Net.ReadTimeout = c.KafkaReadTimeout
+	cfg.Net.WriteTimeout = c.KafkaWriteTimeout
+
+	tlsCfg, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Net.TLS.Enable = tlsCfg != nil
+	cfg.Net.TLS.Config = tlsCfg
+
+	switch mechanism := strings.ToUpper(c.KafkaSASLMechanism); mechanism {
+	case "":
+	case MechanismPlain:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case MechanismSCRAMSHA256:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hash: xdgscram.SHA256}
+		}
+	case MechanismSCRAMSHA512:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hash: xdgscram.SHA512}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism %q", mechanism)
+	}
+	if cfg.Net.SASL.Mechanism != "" {
+		cfg.Net.SASL.Enable = true
+		cfg.Net.SASL.User = c.KafkaSASLUser
+		cfg.Net.SASL.Password = c.KafkaSASLPass
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// KafkaGoReaderConfig returns the kafka-go reader config for the brokers,
+// topic and group ID, dialing with the TLS, SASL and timeout settings
+func (c KafkaConfig) KafkaGoReaderConfig() (kafkago.ReaderConfig, error) {
+	dialer, err := c.dialer()
+	if err != nil {
+		return kafkago.ReaderConfig{}, err
+	}
+	return kafkago.ReaderConfig{
+		Brokers: c.KafkaBrokers,
+		Topic:   c.KafkaTopic,
+		GroupID: c.KafkaGroupID,
+		Dialer:  dialer,
+	}, nil
+}
+
+// KafkaGoWriter returns a kafka-go writer for the brokers and topic. The
+// caller owns the writer and must close it
+func (c KafkaConfig) KafkaGoWriter() (*kafkago.Writer, error) {
+	tlsCfg, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	mechanism, err := c.saslMechanism()
+	if err != nil {
+		return nil, err
+	}
+	return &kafkago.Writer{
+		Addr:         kafkago.TCP(c.KafkaBrokers...),
+		Topic:        c.KafkaTopic,
+		ReadTimeout:  c.KafkaReadTimeout,
+		WriteTimeout: c.KafkaWriteTimeout,
+		Transport: &kafkago.Transport{
+			DialTimeout: c.KafkaDialTimeout,
+			ClientID:    c.KafkaClientID,
+			TLS:         tlsCfg,
+			SASL:        mechanism,
+		},
+	}, nil
+}
+
+// dialer returns the kafka-go dialer for the connection settings
+func (c KafkaConfig) dialer() (*kafkago.Dialer, error) {
+	tlsCfg, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	mechanism, err := c.saslMechanism()
+	if err != nil {
+		return nil, err
+	}
+	return &kafkago.Dialer{
+		ClientID:      c.KafkaClientID,
+		Timeout:       c.KafkaDialTimeout,
+		DualStack:     true,
+		TLS:           tlsCfg,
+		SASLMechanism: mechanism,
+	}, nil
+}
+
+// tlsConfig builds the TLS settings, or returns nil when TLS is disabled
+func (c KafkaConfig) tlsConfig() (*tls.Config, error) {
+	if !c.KafkaTLSEnabled {
+		return nil, nil
+	}
+	return c.KafkaTLS.Build()
+}
+
+// saslMechanism returns the kafka-go SASL mechanism, or nil when SASL is
+// disabled
+func (c KafkaConfig) saslMechanism() (sasl.Mechanism, error) {
+	switch mechanism := strings.ToUpper(c.KafkaSASLMechanism); mechanism {
+	case "":
+		return nil, nil
+	case MechanismPlain:
+		return plain.Mechanism{
+			Username: c.KafkaSASLUser,
+			Password: c.KafkaSASLPass,
+		}, nil
+	case MechanismSCRAMSHA256:
+		return scram.Mechanism(scram.SHA256, c.KafkaSASLUser, c.KafkaSASLPass)
+	case MechanismSCRAMSHA512:
+		return scram.Mechanism(scram.SHA512, c.KafkaSASLUser, c.KafkaSASLPass)
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism %q", mechanism)
+	}
+}
+
+// scramClient adapts xdg-go/scram to sarama's SCRAMClient interface
+type scramClient struct {
+	hash xdgscram.HashGeneratorFcn
+	conv *xdgscram.ClientConversation
+}
+
+// Begin starts a conversation for the given credentials
+func (s *scramClient) Begin(user, pass, authzID string) error {
+	client, err := s.hash.NewClient(user, pass, authzID)
+	if err != nil {
+		return err
+	}
+	s.conv = client.NewConversation()
+	return nil
+}
+
+// Step answers a server challenge
+func (s *scramClient) Step(challenge string) (string, error) {
+	return s.conv.Step(challenge)
+}
+
+// Done reports whether the conversation has finished
+func (s *scramClient) Done() bool {
+	return s.conv.Done()
+}
@@ -0,0 +1,158 @@
+package kafka
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/cvlstack/coil"
+)
+
+// KafkaCfg for tag testing
+type KafkaCfg struct {
+	coil.Config
+	Kafka KafkaConfig
+}
+
+func TestKafkaConfigDefaults(t *testing.T) {
+	cfg, err := coil.ParseArgs(&KafkaCfg{}, []string{
+		"--kafka_brokers=k1:9092,k2:9092",
+		"--kafka_tls_ca_file=ca.pem",
+	})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	kafka := cfg.(*KafkaCfg).Kafka
+	if want := []string{"k1:9092", "k2:9092"}; !reflect.DeepEqual(
+		kafka.KafkaBrokers,
+		want,
+	) {
+		t.Errorf("KafkaBrokers = %q, want %q", kafka.KafkaBrokers, want)
+	}
+	if kafka.KafkaTLS.CAFile != "ca.pem" {
+		t.Errorf("KafkaTLS.CAFile = %q, want ca.pem", kafka.KafkaTLS.CAFile)
+	}
+	if kafka.KafkaDialTimeout != 10*time.Second {
+		t.Errorf("KafkaDialTimeout = %v, want 10s", kafka.KafkaDialTimeout)
+	}
+}
+
+func TestKafkaConfigSaramaConfig(t *testing.T) {
+	cfg, err := KafkaConfig{
+		KafkaClientID:      "orders",
+		KafkaVersion:       "3.6.0",
+		KafkaSASLMechanism: "scram-sha-512",
+		KafkaSASLUser:      "svc",
+		KafkaSASLPass:      "secret",
+		KafkaDialTimeout:   time.Second,
+		KafkaReadTimeout:   2 * time.Second,
+		KafkaWriteTimeout:  3 * time.Second,
+	}.SaramaConfig()
+	if err != nil {
+		t.Fatalf("SaramaConfig() error = %v", err)
+	}
+	if cfg.ClientID != "orders" {
+		t.Errorf("ClientID = %q, want %q", cfg.ClientID, "orders")
+	}
+	if cfg.Version != sarama.V3_6_0_0 {
+		t.Errorf("Version = %v, want %v", cfg.Version, sarama.V3_6_0_0)
+	}
+	if !cfg.Net.SASL.Enable ||
+		cfg.Net.SASL.Mechanism != sarama.SASLTypeSCRAMSHA512 {
+		t.Errorf(
+			"SASL = %v/%q, want enabled %q",
+			cfg.Net.SASL.Enable,
+			cfg.Net.SASL.Mechanism,
+			sarama.SASLTypeSCRAMSHA512,
+		)
+	}
+	if err := cfg.Net.SASL.SCRAMClientGeneratorFunc().Begin(
+		"svc",
+		"secret",
+		"",
+	); err != nil {
+		t.Errorf("SCRAM client Begin() error = %v", err)
+	}
+	if cfg.Net.TLS.Enable {
+		t.Error("TLS enabled, want disabled")
+	}
+	if cfg.Net.DialTimeout != time.Second ||
+		cfg.Net.ReadTimeout != 2*time.Second ||
+		cfg.Net.WriteTimeout != 3*time.Second {
+		t.Errorf(
+			"timeouts = %v/%v/%v, want 1s/2s/3s",
+			cfg.Net.DialTimeout,
+			cfg.Net.ReadTimeout,
+			cfg.Net.WriteTimeout,
+		)
+	}
+}
+
+func TestKafkaConfigKafkaGo(t *testing.T) {
+	c := KafkaConfig{
+		KafkaBrokers:       []string{"k1:9092"},
+		KafkaTopic:         "orders",
+		KafkaGroupID:       "billing",
+		KafkaSASLMechanism: MechanismPlain,
+		KafkaDialTimeout:   time.Second,
+	}
+	reader, err := c.KafkaGoReaderConfig()
+	if err != nil {
+		t.Fatalf("KafkaGoReaderConfig() error = %v", err)
+	}
+	if reader.Topic != "orders" || reader.GroupID != "billing" {
+		t.Errorf(
+			"reader topic/group = %q/%q, want orders/billing",
+			reader.Topic,
+			reader.GroupID,
+		)
+	}
+	if reader.Dialer.SASLMechanism == nil ||
+		reader.Dialer.SASLMechanism.Name() != MechanismPlain {
+		t.Errorf("reader SASL = %v, want PLAIN", reader.Dialer.SASLMechanism)
+	}
+	if reader.Dialer.Timeout != time.Second {
+		t.Errorf("reader dial timeout = %v, want 1s", reader.Dialer.Timeout)
+	}
+
+	writer, err := c.KafkaGoWriter()
+	if err != nil {
+		t.Fatalf("KafkaGoWriter() error = %v", err)
+	}
+	defer writer.Close()
+	if writer.Addr.String() != "k1:9092" || writer.Topic != "orders" {
+		t.Errorf(
+			"writer addr/topic = %s/%q, want k1:9092/orders",
+			writer.Addr,
+			writer.Topic,
+		)
+	}
+}
+
+func TestKafkaConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  KafkaConfig
+	}{
+		{"bad mechanism", KafkaConfig{KafkaSASLMechanism: "GSSAPI"}},
+		{"bad version", KafkaConfig{KafkaVersion: "banana"}},
+		{"bad tls", KafkaConfig{
+			KafkaTLSEnabled: true,
+			KafkaTLS:        coil.TLSConfig{CAFile: "missing.pem"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cfg.SaramaConfig(); err == nil {
+				t.Error("SaramaConfig() error = nil, want error")
+			}
+		})
+	}
+	if _, err := (KafkaConfig{
+		KafkaSASLMechanism: "GSSAPI",
+	}).KafkaGoReaderConfig(); err == nil {
+		t.Error("KafkaGoReaderConfig() error = nil, want error")
+	}
+}
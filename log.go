@@ -0,0 +1,223 @@
+package coil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Log levels below debug and above error, which slog does not name
+const (
+	LevelTrace = slog.LevelDebug - 4
+	LevelFatal = slog.LevelError + 4
+)
+
+// SlogHandler builds a slog.Handler from the config. Text and logfmt formats
+// use slog's key=value output, anything else is written as JSON. The static
+// fields and service metadata are prepended to every record; static fields
+// that do not parse are skipped, and reported by Validate. AddSource adds
+// the caller's file and line, CallerSkip frames above the slog call
+func (c LogConfig) SlogHandler() slog.Handler {
+	opts := &slog.HandlerOptions{
+		AddSource: c.AddSource,
+		Level:     c.slogLevel(),
+	}
+	var h slog.Handler
+	switch strings.ToLower(c.Format) {
+	case "text", "logfmt":
+		h = slog.NewTextHandler(c.writer(), opts)
+	default:
+		h = slog.NewJSONHandler(c.writer(), opts)
+	}
+	if fields, _ := c.fields(); len(fields) > 0 {
+		attrs := make([]slog.Attr, 0, len(fields))
+		for _, key := range sortedKeys(fields) {
+			attrs = append(attrs, slog.Any(key, fields[key]))
+		}
+		h = &staticHandler{Handler: h, attrs: attrs}
+	}
+	if c.CallerSkip > 0 {
+		h = &callerSkipHandler{Handler: h, skip: c.CallerSkip}
+	}
+	return h
+}
+
+// callerSkipHandler reports the caller skip frames above the one slog
+// recorded, so records logged through a wrapper point at its caller
+type callerSkipHandler struct {
+	slog.Handler
+	skip int
+}
+
+// Handle moves the record's PC up the stack of the logging goroutine,
+// where the frame slog recorded is found by its return address
+func (h *callerSkipHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.PC != 0 {
+		pcs := make([]uintptr, 64)
+		n := runtime.Callers(2, pcs)
+		for i, pc := range pcs[:n] {
+			if pc == r.PC && i+h.skip < n {
+				r.PC = pcs[i+h.skip]
+				break
+			}
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs keeps the caller skip on the derived handler
+func (h *callerSkipHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &callerSkipHandler{Handler: h.Handler.WithAttrs(attrs), skip: h.skip}
+}
+
+// WithGroup keeps the caller skip on the derived handler
+func (h *callerSkipHandler) WithGroup(name string) slog.Handler {
+	return &callerSkipHandler{Handler: h.Handler.WithGroup(name), skip: h.skip}
+}
+
+// staticHandler prepends a fixed set of attributes to every record
+type staticHandler struct {
+	slog.Handler
+	attrs []slog.Attr
+}
+
+// Handle passes the record on with the static attributes first
+func (h *staticHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	out.AddAttrs(h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(a)
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+// WithAttrs hands the static attributes to the wrapped handler ahead of
+// attrs, keeping them first
+func (h *staticHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.Handler.WithAttrs(h.attrs).WithAttrs(attrs)
+}
+
+// WithGroup hands the static attributes to the wrapped handler so they
+// stay outside the group
+func (h *staticHandler) WithGroup(name string) slog.Handler {
+	return h.Handler.WithAttrs(h.attrs).WithGroup(name)
+}
+
+// ParseStaticFields decodes StaticFields, which must be a flat JSON object
+// of string values such as {"version":"1.2.3","region":"us-east-1"}. An
+// empty value yields an empty map
+func (c LogConfig) ParseStaticFields() (map[string]string, error) {
+	fields := make(map[string]string)
+	if strings.TrimSpace(c.StaticFields) == "" {
+		return fields, nil
+	}
+	if err := json.Unmarshal([]byte(c.StaticFields), &fields); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf(
+				"field %q is not a string, static fields must be flat",
+				typeErr.Field,
+			)
+		}
+		return nil, err
+	}
+	return fields, nil
+}
+
+// Validate reports static fields that are not a flat JSON object of strings
+func (c LogConfig) Validate() error {
+	if _, err := c.ParseStaticFields(); err != nil {
+		return fmt.Errorf("log_static_fields: %w", err)
+	}
+	return nil
+}
+
+// slogLevel maps the configured level name onto a slog.Level, defaulting
+// to info
+func (c LogConfig) slogLevel() slog.Level {
+	switch strings.ToLower(c.Level) {
+	case "trace":
+		return LevelTrace
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	case "fatal":
+		return LevelFatal
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithWriter returns a copy of the config writing to w instead of the
+// destination selected by Output, e.g. a buffer in tests
+func (c LogConfig) WithWriter(w io.Writer) LogConfig {
+	c.out = w
+	return c
+}
+
+// writer returns the destination selected by Output. Files are rotated by
+// lumberjack using the MaxSize, MaxBackups, MaxAge and Compress settings
+func (c LogConfig) writer() io.Writer {
+	if c.out != nil {
+		return c.out
+	}
+	switch strings.ToLower(c.Output) {
+	case "stderr":
+		return os.Stderr
+	case "file":
+		return &lumberjack.Logger{
+			Filename:   c.FilePath,
+			MaxSize:    c.MaxSize,
+			MaxBackups: c.MaxBackups,
+			MaxAge:     c.MaxAge,
+			Compress:   c.Compress,
+		}
+	default:
+		return os.Stdout
+	}
+}
+
+// fields merges the static fields with the service metadata attached to
+// every log entry, the metadata taking precedence. The metadata is kept
+// even if the static fields do not parse, in which case the error is
+// returned
+func (c LogConfig) fields() (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	static, err := c.ParseStaticFields()
+	for key, val := range static {
+		fields[key] = val
+	}
+	for key, val := range map[string]string{
+		"service":     c.ServiceName,
+		"environment": c.Environment,
+		"instance_id": c.InstanceID,
+	} {
+		if val != "" {
+			fields[key] = val
+		}
+	}
+	return fields, err
+}
+
+// sortedKeys returns the keys of a map in a stable order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,157 @@
+package coil
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	cfg := LogConfig{
+		Level:        "warn",
+		Format:       "json",
+		Output:       "file",
+		FilePath:     path,
+		MaxSize:      1,
+		StaticFields: `{"team":"core"}`,
+		ServiceName:  "billing",
+	}
+	logger := slog.New(cfg.SlogHandler())
+	if logger.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("info should be disabled at warn level")
+	}
+	logger.Warn("disk low")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading log file: %v", err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("log entry is not JSON: %v: %s", err, data)
+	}
+	for key, want := range map[string]string{
+		"msg":     "disk low",
+		"team":    "core",
+		"service": "billing",
+	} {
+		if entry[key] != want {
+			t.Errorf("entry[%q] = %v, want %q", key, entry[key], want)
+		}
+	}
+}
+
+func TestSlogLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  slog.Level
+	}{
+		{"trace", LevelTrace},
+		{"debug", slog.LevelDebug},
+		{"", slog.LevelInfo},
+		{"WARN", slog.LevelWarn},
+		{"error", slog.LevelError},
+		{"fatal", LevelFatal},
+	}
+	for _, tt := range tests {
+		if got := (LogConfig{Level: tt.level}).slogLevel(); got != tt.want {
+			t.Errorf("slogLevel(%q) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestParseStaticFields(t *testing.T) {
+	fields, err := LogConfig{
+		StaticFields: `{"version":"1.2.3","region":"us-east-1"}`,
+	}.ParseStaticFields()
+	if err != nil {
+		t.Fatalf("ParseStaticFields() error = %v", err)
+	}
+	if fields["version"] != "1.2.3" || fields["region"] != "us-east-1" {
+		t.Errorf("ParseStaticFields() = %v", fields)
+	}
+
+	for _, static := range []string{`{`, `["a"]`, `{"build":{"id":"1"}}`, `{"n":1}`} {
+		cfg := LogConfig{StaticFields: static}
+		if _, err := cfg.ParseStaticFields(); err == nil {
+			t.Errorf("ParseStaticFields(%s) should fail", static)
+		}
+		err := cfg.Validate()
+		if err == nil || !strings.HasPrefix(err.Error(), "log_static_fields: ") {
+			t.Errorf("Validate(%s) error = %v", static, err)
+		}
+	}
+	if err := (LogConfig{}).Validate(); err != nil {
+		t.Errorf("Validate() without static fields error = %v", err)
+	}
+}
+
+func TestSlogHandlerStaticFieldsFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	cfg := LogConfig{
+		Format:       "text",
+		Output:       "file",
+		FilePath:     path,
+		StaticFields: `{"region":"eu"}`,
+		Environment:  "prod",
+	}
+	slog.New(cfg.SlogHandler()).Info("ready", "port", 8080)
+	slog.New(cfg.SlogHandler()).WithGroup("req").Info("done", "id", 7)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2: %s", len(lines), data)
+	}
+	if !strings.HasSuffix(lines[0], `msg=ready environment=prod region=eu port=8080`) {
+		t.Errorf("first entry = %s", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], `msg=done environment=prod region=eu req.id=7`) {
+		t.Errorf("grouped entry = %s", lines[1])
+	}
+}
+
+// logWrapped logs through a helper, as a logging wrapper would
+func logWrapped(logger *slog.Logger) {
+	logger.Info("wrapped")
+}
+
+func TestSlogHandlerCallerSkip(t *testing.T) {
+	for skip, want := range map[int]string{
+		0: "logWrapped",
+		1: "TestSlogHandlerCallerSkip",
+	} {
+		var b strings.Builder
+		cfg := LogConfig{AddSource: true, CallerSkip: skip}.WithWriter(&b)
+		logWrapped(slog.New(cfg.SlogHandler()).With("attempt", skip))
+		var entry struct {
+			Source struct{ Function string }
+		}
+		if err := json.Unmarshal([]byte(b.String()), &entry); err != nil {
+			t.Fatalf("log entry is not JSON: %v: %s", err, b.String())
+		}
+		if !strings.HasSuffix(entry.Source.Function, "."+want) {
+			t.Errorf(
+				"skip %d: source function = %q, want %s",
+				skip,
+				entry.Source.Function,
+				want,
+			)
+		}
+	}
+
+	var b strings.Builder
+	cfg := LogConfig{}.WithWriter(&b)
+	slog.New(cfg.SlogHandler()).Info("plain")
+	if strings.Contains(b.String(), "source") {
+		t.Errorf("source added without AddSource: %s", b.String())
+	}
+}
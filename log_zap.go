@@ -0,0 +1,55 @@
+//go:build zap
+
+package coil
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ZapLogger builds a zap.Logger from the config. Text and logfmt formats use
+// zap's console encoder, anything else is written as JSON. Only available
+// when built with the zap tag
+func (c LogConfig) ZapLogger() (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(c.zapLevelName())
+	if err != nil {
+		return nil, err
+	}
+	fields, err := c.fields()
+	if err != nil {
+		return nil, fmt.Errorf("invalid log_static_fields: %w", err)
+	}
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	var enc zapcore.Encoder
+	switch strings.ToLower(c.Format) {
+	case "text", "logfmt":
+		enc = zapcore.NewConsoleEncoder(encCfg)
+	default:
+		enc = zapcore.NewJSONEncoder(encCfg)
+	}
+	core := zapcore.NewCore(enc, zapcore.AddSync(c.writer()), level)
+	zapFields := make([]zap.Field, 0, len(fields))
+	for _, key := range sortedKeys(fields) {
+		zapFields = append(zapFields, zap.Any(key, fields[key]))
+	}
+	return zap.New(core).With(zapFields...), nil
+}
+
+// zapLevelName maps the configured level name onto one zap understands.
+// zap has no trace level, so trace logs at debug
+func (c LogConfig) zapLevelName() string {
+	switch level := strings.ToLower(c.Level); level {
+	case "":
+		return "info"
+	case "trace":
+		return "debug"
+	case "warning":
+		return "warn"
+	default:
+		return level
+	}
+}
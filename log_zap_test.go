@@ -0,0 +1,19 @@
+//go:build zap
+
+package coil
+
+import "testing"
+
+func TestZapLogger(t *testing.T) {
+	logger, err := LogConfig{Level: "trace", Output: "stderr"}.ZapLogger()
+	if err != nil {
+		t.Fatalf("ZapLogger() error = %v", err)
+	}
+	if !logger.Core().Enabled(-1) {
+		t.Error("debug should be enabled at trace level")
+	}
+	_, err = LogConfig{Level: "info", StaticFields: "{"}.ZapLogger()
+	if err == nil {
+		t.Error("ZapLogger() with invalid static fields should fail")
+	}
+}
@@ -0,0 +1,46 @@
+//go:build zerolog
+
+package coil
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// ZerologLogger builds a zerolog.Logger from the config. Text and logfmt
+// formats use zerolog's console writer, anything else is written as JSON.
+// Only available when built with the zerolog tag
+func (c LogConfig) ZerologLogger() zerolog.Logger {
+	w := c.writer()
+	switch strings.ToLower(c.Format) {
+	case "text", "logfmt":
+		w = zerolog.ConsoleWriter{Out: w, NoColor: true}
+	}
+	fields, _ := c.fields()
+	return zerolog.New(w).
+		Level(c.zerologLevel()).
+		With().
+		Timestamp().
+		Fields(fields).
+		Logger()
+}
+
+// zerologLevel maps the configured level name onto a zerolog.Level,
+// defaulting to info
+func (c LogConfig) zerologLevel() zerolog.Level {
+	switch strings.ToLower(c.Level) {
+	case "trace":
+		return zerolog.TraceLevel
+	case "debug":
+		return zerolog.DebugLevel
+	case "warn", "warning":
+		return zerolog.WarnLevel
+	case "error":
+		return zerolog.ErrorLevel
+	case "fatal":
+		return zerolog.FatalLevel
+	default:
+		return zerolog.InfoLevel
+	}
+}
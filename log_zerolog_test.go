@@ -0,0 +1,16 @@
+//go:build zerolog
+
+package coil
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestZerologLogger(t *testing.T) {
+	logger := LogConfig{Level: "error", Output: "stderr"}.ZerologLogger()
+	if got := logger.GetLevel(); got != zerolog.ErrorLevel {
+		t.Errorf("GetLevel() = %v, want %v", got, zerolog.ErrorLevel)
+	}
+}
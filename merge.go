@@ -0,0 +1,72 @@
+package coil
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Merge overlays the keys other has set, by any source, onto the config,
+// e.g. a per-tenant config loaded on top of a shared base. Keys other
+// leaves unset keep their current values. Both configs must be loaded,
+// and keys of other that the config does not register return
+// ErrUnknownKey
+func (c *Config) Merge(other Configer) error {
+	o := other.base()
+	if o.root == nil || o.viper == nil {
+		return errors.New("config to merge has not been loaded")
+	}
+	values := make(map[string]interface{})
+	o.mu.RLock()
+	walkConfig(
+		o.root,
+		func(def fieldDef, _ reflect.StructField, fv reflect.Value) {
+			if o.viper.IsSet(def.Name) && fv.CanInterface() {
+				values[def.Name] = exportValue(fv, def)
+			}
+		},
+	)
+	o.mu.RUnlock()
+	return c.MergeFromMap(values)
+}
+
+// MergeFromMap overlays the given values, keyed by flag name, onto the
+// config, e.g. for inline overrides. The values take precedence over every
+// other source, including on later reloads. Unknown keys return
+// ErrUnknownKey before anything is changed, and values that do not parse
+// are reported as ValidationErrors. The fields are written under the
+// config's write lock and subscribers are notified of the changed keys
+func (c *Config) MergeFromMap(values map[string]interface{}) error {
+	if c.root == nil || c.viper == nil {
+		return errors.New("config has not been loaded")
+	}
+	for key := range values {
+		if _, _, ok := lookupField(c.root, key); !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownKey, key)
+		}
+	}
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	c.mu.Lock()
+	old := c.liveValues()
+	for key, val := range values {
+		c.viper.Set(key, val)
+	}
+	setPropertiesFromFlagsWithPrefix(
+		reflect.ValueOf(c.root),
+		c.viper,
+		c.prefix,
+		c.fieldPrefixes,
+	)
+	var errs ValidationErrors
+	if c.opts != nil {
+		errs = runParsers(c.root, c.opts.parsers)
+	}
+	c.publish(old, c.liveValues())
+	c.mu.Unlock()
+	errs = append(errs, invalidValues(c.root)...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package coil
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MergeCfg for merge testing
+type MergeCfg struct {
+	Config
+	Host    string            `type:"string"   name:"merge_host"    default:"localhost" desc:"Host"`
+	Port    int               `type:"int"      name:"merge_port"    default:"8080"      desc:"Port"`
+	Timeout time.Duration     `type:"duration" name:"merge_timeout" default:"5s"        desc:"Timeout"`
+	Labels  map[string]string `type:"map"      name:"merge_labels"  default:"tier=web"  desc:"Labels"`
+	DB      DatabaseConfig    `prefix:"merge"`
+}
+
+func TestMerge(t *testing.T) {
+	c, err := NewConfigFromMap(
+		map[string]interface{}{"merge_host": "shared", "merge_port": 9000},
+		&MergeCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	base := c.(*MergeCfg)
+	tenant, err := NewConfigFromMap(
+		map[string]interface{}{
+			"merge_port":    9100,
+			"merge_timeout": "1m",
+			"merge_dbname":  "tenant_a",
+		},
+		&MergeCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+
+	if err := base.Merge(tenant); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if base.Host != "shared" {
+		t.Errorf("Host = %q, want %q kept from the base", base.Host, "shared")
+	}
+	if base.Port != 9100 {
+		t.Errorf("Port = %d, want 9100", base.Port)
+	}
+	if base.Timeout != time.Minute {
+		t.Errorf("Timeout = %v, want 1m", base.Timeout)
+	}
+	if base.DB.DBName != "tenant_a" {
+		t.Errorf("DB.DBName = %q, want %q", base.DB.DBName, "tenant_a")
+	}
+	if !reflect.DeepEqual(base.Labels, map[string]string{"tier": "web"}) {
+		t.Errorf("Labels = %v, want tier=web", base.Labels)
+	}
+	if got := base.getParser().GetInt("merge_port"); got != 9100 {
+		t.Errorf("parser merge_port = %d, want 9100", got)
+	}
+}
+
+func TestMergeFromMap(t *testing.T) {
+	c, err := NewConfigFromMap(nil, &MergeCfg{})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	cfg := c.(*MergeCfg)
+	err = cfg.MergeFromMap(map[string]interface{}{
+		"merge_host":   "override",
+		"merge_labels": map[string]string{"tier": "db"},
+	})
+	if err != nil {
+		t.Fatalf("MergeFromMap() error = %v", err)
+	}
+	if cfg.Host != "override" || cfg.Labels["tier"] != "db" {
+		t.Errorf("MergeFromMap() = %q, %v, want override, tier=db",
+			cfg.Host, cfg.Labels)
+	}
+	if cfg.Port != 8080 {
+		t.Errorf("Port = %d, want default 8080", cfg.Port)
+	}
+
+	// Overrides survive a reload
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if cfg.Host != "override" {
+		t.Errorf("Host = %q after Reload, want %q", cfg.Host, "override")
+	}
+}
+
+func TestMergeFromMapErrors(t *testing.T) {
+	c, err := NewConfigFromMap(nil, &MergeCfg{})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	cfg := c.(*MergeCfg)
+	err = cfg.MergeFromMap(map[string]interface{}{
+		"merge_host":  "ignored",
+		"merge_other": 1,
+	})
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("MergeFromMap() error = %v, want ErrUnknownKey", err)
+	}
+	if cfg.Host != "localhost" {
+		t.Errorf("Host = %q, want it unchanged after an unknown key", cfg.Host)
+	}
+
+	err = cfg.MergeFromMap(map[string]interface{}{"merge_dbport": 0})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || !strings.Contains(err.Error(), "merge_dbport") {
+		t.Errorf("MergeFromMap() error = %v, want merge_dbport error", err)
+	}
+
+	if err := cfg.Merge(&MergeCfg{}); err == nil {
+		t.Error("Merge() of an unloaded config should fail")
+	}
+}
@@ -0,0 +1,136 @@
+package coil
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// SchemaVersionKey is the config file key declaring the schema version the
+// file was written for
+const SchemaVersionKey = "schema_version"
+
+// MigrationFunc rewrites config file values written for one schema version
+// into the keys and values of the next, e.g. renaming keys or injecting
+// defaults. Keys are lowercase
+type MigrationFunc func(
+	values map[string]interface{},
+) (map[string]interface{}, error)
+
+// migration is a registered step between two schema versions
+type migration struct {
+	to string
+	fn MigrationFunc
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = make(map[string]migration)
+)
+
+// RegisterMigration registers the step from one schema version to the
+// next. Configs whose SchemaVersion differs from the config file's
+// schema_version apply the chain of steps leading to it. Registering a
+// second step from the same version panics
+func RegisterMigration(fromVersion, toVersion string, fn MigrationFunc) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	if _, ok := migrations[fromVersion]; ok {
+		panic(fmt.Sprintf(
+			"coil: migration from schema version %q already registered",
+			fromVersion,
+		))
+	}
+	migrations[fromVersion] = migration{to: toVersion, fn: fn}
+}
+
+// migrationPath returns the steps leading from one schema version to
+// another
+func migrationPath(from, to string) ([]migration, error) {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	var path []migration
+	seen := map[string]bool{}
+	for version := from; version != to; {
+		step, ok := migrations[version]
+		if !ok || seen[version] {
+			return nil, fmt.Errorf(
+				"no migration from schema version %q to %q",
+				from,
+				to,
+			)
+		}
+		seen[version] = true
+		path = append(path, step)
+		version = step.to
+	}
+	return path, nil
+}
+
+// migrate rewrites the values of the config file when its schema_version
+// differs from the config's SchemaVersion, merging the result back into
+// the parser. A file without a schema_version is only migrated if a step
+// from the empty version is registered
+func migrate(c Configer) error {
+	target := c.base().SchemaVersion
+	parser := c.getParser()
+	path := parser.ConfigFileUsed()
+	if target == "" || path == "" {
+		return nil
+	}
+	file := viper.New()
+	file.SetConfigFile(path)
+	if err := file.ReadInConfig(); err != nil {
+		return err
+	}
+	values := file.AllSettings()
+	from := file.GetString(SchemaVersionKey)
+	if from == target {
+		return nil
+	}
+	if from == "" {
+		migrationsMu.RLock()
+		_, ok := migrations[""]
+		migrationsMu.RUnlock()
+		if !ok {
+			return nil
+		}
+	}
+	values, err := MigrateValues(values, from, target)
+	if err != nil {
+		return err
+	}
+	b := c.base()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return parser.MergeConfigMap(values)
+}
+
+// MigrateValues applies the chain of registered steps leading from one
+// schema version to another to config file values, setting their
+// schema_version to the target. It is what NewConfig applies to an
+// outdated config file, exposed for tools rewriting the file itself
+func MigrateValues(
+	values map[string]interface{},
+	from, to string,
+) (map[string]interface{}, error) {
+	steps, err := migrationPath(from, to)
+	if err != nil {
+		return nil, err
+	}
+	version := from
+	for _, step := range steps {
+		if values, err = step.fn(values); err != nil {
+			return nil, fmt.Errorf(
+				"migrating config from schema version %q to %q: %w",
+				version,
+				step.to,
+				err,
+			)
+		}
+		version = step.to
+	}
+	values[SchemaVersionKey] = to
+	return values, nil
+}
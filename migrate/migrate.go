@@ -0,0 +1,168 @@
+// Package migrate rewrites config files written for an old schema version
+// using the migrations registered with coil.RegisterMigration. It backs
+// the coil-migrate command
+package migrate
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/spf13/viper"
+
+	"github.com/cvlstack/coil"
+)
+
+// Run migrates the config file named by args and returns the exit code.
+// It accepts:
+//
+//	--input old.yaml       config file to migrate (YAML, TOML or JSON)
+//	--output new.yaml      file to write, in the format of its extension
+//	--from-version V1      schema version of the input, by default its
+//	                       schema_version key
+//	--to-version V2        schema version to migrate to
+//	--dry-run              print the changes instead of writing output
+//
+// When c is not nil, keys it does not register are passed through with a
+// warning. Failing migration steps exit with 1, bad arguments with 2
+func Run(c coil.Configer, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("coil-migrate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	input := fs.String("input", "", "Config file to migrate")
+	output := fs.String("output", "", "File to write the migrated config to")
+	from := fs.String(
+		"from-version",
+		"",
+		"Schema version of the input, by default its "+coil.SchemaVersionKey,
+	)
+	to := fs.String("to-version", "", "Schema version to migrate to")
+	dryRun := fs.Bool(
+		"dry-run",
+		false,
+		"Print the changes instead of writing the output",
+	)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *input == "" || *to == "" || *output == "" && !*dryRun {
+		fmt.Fprintln(
+			stderr,
+			"coil-migrate: --input, --to-version and --output are required",
+		)
+		fs.Usage()
+		return 2
+	}
+	old, err := readFile(*input)
+	if err != nil {
+		fmt.Fprintf(stderr, "coil-migrate: %v\n", err)
+		return 1
+	}
+	version := *from
+	if v, ok := old[coil.SchemaVersionKey]; ok && version == "" {
+		version = fmt.Sprint(v)
+	}
+	values, err := coil.MigrateValues(copyValues(old), version, *to)
+	if err != nil {
+		fmt.Fprintf(stderr, "coil-migrate: %v\n", err)
+		return 1
+	}
+	for _, key := range unknownKeys(c, values) {
+		fmt.Fprintf(
+			stderr,
+			"coil-migrate: warning: unknown key %q passed through\n",
+			key,
+		)
+	}
+	if *dryRun {
+		writeDiff(stdout, *input, old, values)
+		return 0
+	}
+	if err := writeFile(*output, values); err != nil {
+		fmt.Fprintf(stderr, "coil-migrate: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// readFile reads the values of a config file, keyed by lower case name
+func readFile(path string) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	return v.AllSettings(), nil
+}
+
+// writeFile writes values to path in the format of its extension
+func writeFile(path string, values map[string]interface{}) error {
+	v := viper.New()
+	for key, val := range values {
+		v.Set(key, val)
+	}
+	if err := v.WriteConfigAs(path); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return nil
+}
+
+// copyValues returns a shallow copy of values, so migration steps that
+// edit their input leave the original for the diff
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(values))
+	for key, val := range values {
+		out[key] = val
+	}
+	return out
+}
+
+// unknownKeys returns the sorted keys of values that c does not register
+func unknownKeys(c coil.Configer, values map[string]interface{}) []string {
+	if c == nil {
+		return nil
+	}
+	known := map[string]bool{coil.SchemaVersionKey: true}
+	for _, key := range coil.Keys(c) {
+		known[key.Name] = true
+	}
+	var unknown []string
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// writeDiff prints the keys removed, added or changed by the migration
+func writeDiff(w io.Writer, path string, old, new map[string]interface{}) {
+	keys := make(map[string]bool, len(old)+len(new))
+	for key := range old {
+		keys[key] = true
+	}
+	for key := range new {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	fmt.Fprintf(w, "--- %s\n+++ %s (migrated)\n", path, path)
+	for _, key := range sorted {
+		oldVal, inOld := old[key]
+		newVal, inNew := new[key]
+		if inOld && inNew && reflect.DeepEqual(oldVal, newVal) {
+			continue
+		}
+		if inOld {
+			fmt.Fprintf(w, "-%s: %v\n", key, oldVal)
+		}
+		if inNew {
+			fmt.Fprintf(w, "+%s: %v\n", key, newVal)
+		}
+	}
+}
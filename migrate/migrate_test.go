@@ -0,0 +1,140 @@
+package migrate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cvlstack/coil"
+)
+
+// AppCfg for migration testing
+type AppCfg struct {
+	coil.Config
+	coil.DatabaseConfig
+}
+
+func init() {
+	coil.RegisterMigration(
+		"migrate-1",
+		"migrate-2",
+		func(values map[string]interface{}) (map[string]interface{}, error) {
+			values["dbhost"] = values["db_host"]
+			delete(values, "db_host")
+			return values, nil
+		},
+	)
+	coil.RegisterMigration(
+		"migrate-broken",
+		"migrate-2",
+		func(map[string]interface{}) (map[string]interface{}, error) {
+			return nil, errors.New("unsupported layout")
+		},
+	)
+}
+
+func writeInput(t *testing.T, version string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "old.yaml")
+	data := "schema_version: " + version + "\n" +
+		"db_host: db.internal\ndbport: 6543\nlegacy: true\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	input := writeInput(t, "migrate-1")
+	output := filepath.Join(t.TempDir(), "new.yaml")
+	var stdout, stderr strings.Builder
+	code := Run(&AppCfg{}, []string{
+		"--input", input,
+		"--output", output,
+		"--to-version", "migrate-2",
+	}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Run() = %d, stderr:\n%s", code, stderr.String())
+	}
+	values, err := readFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["dbhost"] != "db.internal" || values["db_host"] != nil {
+		t.Errorf("migrated values = %v, want db_host renamed", values)
+	}
+	if values["legacy"] != true {
+		t.Errorf("unknown key legacy = %v, want it passed through", values)
+	}
+	if values[coil.SchemaVersionKey] != "migrate-2" {
+		t.Errorf("schema_version = %v, want migrate-2", values)
+	}
+	if !strings.Contains(stderr.String(), `unknown key "legacy"`) {
+		t.Errorf("stderr = %q, want a warning for legacy", stderr.String())
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	input := writeInput(t, "migrate-1")
+	output := filepath.Join(t.TempDir(), "new.yaml")
+	var stdout, stderr strings.Builder
+	code := Run(nil, []string{
+		"--input", input,
+		"--output", output,
+		"--to-version", "migrate-2",
+		"--dry-run",
+	}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Run() = %d, stderr:\n%s", code, stderr.String())
+	}
+	for _, want := range []string{
+		"-db_host: db.internal\n",
+		"+dbhost: db.internal\n",
+		"-schema_version: migrate-1\n+schema_version: migrate-2\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("diff missing %q in:\n%s", want, stdout.String())
+		}
+	}
+	if strings.Contains(stdout.String(), "dbport") {
+		t.Errorf("diff lists an unchanged key:\n%s", stdout.String())
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Error("dry run wrote the output file")
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "new.yaml")
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"missing input", []string{"--to-version", "migrate-2"}, 2},
+		{"unknown flag", []string{"--bogus"}, 2},
+		{"failing step", []string{
+			"--input", writeInput(t, "migrate-broken"),
+			"--output", output,
+			"--to-version", "migrate-2",
+		}, 1},
+		{"no migration path", []string{
+			"--input", writeInput(t, "migrate-0"),
+			"--output", output,
+			"--to-version", "migrate-2",
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			if got := Run(nil, tt.args, &stdout, &stderr); got != tt.want {
+				t.Errorf("Run() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Error("a failed migration wrote the output file")
+	}
+}
@@ -0,0 +1,130 @@
+package coil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// MigrateCfg for migration testing
+type MigrateCfg struct {
+	Config
+	Host    string `type:"string" name:"migrate_host"    default:"localhost" desc:"Host"`
+	Timeout string `type:"string" name:"migrate_timeout" default:"10s"       desc:"Timeout"`
+}
+
+func writeMigrateFile(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// registerTestMigration registers a migration for the duration of a test
+func registerTestMigration(
+	t *testing.T,
+	fromVersion, toVersion string,
+	fn MigrationFunc,
+) {
+	t.Helper()
+	RegisterMigration(fromVersion, toVersion, fn)
+	t.Cleanup(func() {
+		migrationsMu.Lock()
+		defer migrationsMu.Unlock()
+		delete(migrations, fromVersion)
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	registerTestMigration(
+		t,
+		"mig-1",
+		"mig-2",
+		func(values map[string]interface{}) (map[string]interface{}, error) {
+			values["migrate_host"] = values["old_host"]
+			delete(values, "old_host")
+			return values, nil
+		},
+	)
+	registerTestMigration(
+		t,
+		"mig-2",
+		"mig-3",
+		func(values map[string]interface{}) (map[string]interface{}, error) {
+			values["migrate_timeout"] = "30s"
+			return values, nil
+		},
+	)
+	path := writeMigrateFile(
+		t,
+		"schema_version: mig-1\nold_host: db.internal\n",
+	)
+
+	c, err := ParseArgs(
+		&MigrateCfg{Config: Config{SchemaVersion: "mig-3"}},
+		[]string{"--config=" + path},
+	)
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	cfg := c.(*MigrateCfg)
+	if cfg.Host != "db.internal" || cfg.Timeout != "30s" {
+		t.Errorf(
+			"Host, Timeout = %q, %q, want %q, %q",
+			cfg.Host,
+			cfg.Timeout,
+			"db.internal",
+			"30s",
+		)
+	}
+	if cfg.SchemaVersion != "mig-3" {
+		t.Errorf("SchemaVersion = %q, want %q", cfg.SchemaVersion, "mig-3")
+	}
+
+	// A file already at the compiled version is left alone
+	path = writeMigrateFile(
+		t,
+		"schema_version: mig-3\nold_host: db.internal\n",
+	)
+	c, err = ParseArgs(
+		&MigrateCfg{Config: Config{SchemaVersion: "mig-3"}},
+		[]string{"--config=" + path},
+	)
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	if host := c.(*MigrateCfg).Host; host != "localhost" {
+		t.Errorf("Host = %q, want %q", host, "localhost")
+	}
+}
+
+func TestMigrationErrors(t *testing.T) {
+	errBad := errors.New("bad value")
+	registerTestMigration(
+		t,
+		"mig-err-1",
+		"mig-err-2",
+		func(values map[string]interface{}) (map[string]interface{}, error) {
+			return nil, errBad
+		},
+	)
+	path := writeMigrateFile(t, "schema_version: mig-err-1\n")
+	_, err := ParseArgs(
+		&MigrateCfg{Config: Config{SchemaVersion: "mig-err-2"}},
+		[]string{"--config=" + path},
+	)
+	if !errors.Is(err, errBad) {
+		t.Errorf("ParseArgs() error = %v, want %v", err, errBad)
+	}
+
+	path = writeMigrateFile(t, "schema_version: mig-unknown\n")
+	_, err = ParseArgs(
+		&MigrateCfg{Config: Config{SchemaVersion: "mig-err-2"}},
+		[]string{"--config=" + path},
+	)
+	if err == nil {
+		t.Error("ParseArgs() without a migration path should return an error")
+	}
+}
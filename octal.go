@@ -0,0 +1,88 @@
+package coil
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// Formats appended to the help text of uint8 and octal flags
+const (
+	uint8Formats = "0-255, or 0x, 0o or 0b prefixed, e.g. 0o17"
+	octalFormats = "octal, e.g. 0644 or 0o644"
+)
+
+// parseUnsigned parses the value of a uint8 or octal key into an unsigned
+// integer of the given bit size. Strings are read in base 8 for octal
+// keys, with an optional 0o prefix, and with Go's base prefixes otherwise.
+// Numbers, such as those decoded from a config file, are taken as they
+// are. Empty values yield 0
+func parseUnsigned(val interface{}, typ string, bits int) (uint64, error) {
+	s, ok := val.(string)
+	if !ok && val != nil {
+		n, err := cast.ToUint64E(val)
+		if err != nil {
+			return 0, fmt.Errorf("%v is not an unsigned integer", val)
+		}
+		if bits < 64 && n >= 1<<bits {
+			return 0, fmt.Errorf("%d does not fit in %d bits", n, bits)
+		}
+		return n, nil
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if typ == "octal" {
+		digits := strings.TrimPrefix(strings.TrimPrefix(s, "0o"), "0O")
+		n, err := strconv.ParseUint(digits, 8, bits)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a valid %d bit octal value", s, bits)
+		}
+		return n, nil
+	}
+	n, err := strconv.ParseUint(s, 0, bits)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid %d bit unsigned value", s, bits)
+	}
+	return n, nil
+}
+
+// uintBits returns the bit size of an unsigned kind
+func uintBits(kind reflect.Kind) int {
+	switch kind {
+	case reflect.Uint8:
+		return 8
+	case reflect.Uint16:
+		return 16
+	case reflect.Uint32:
+		return 32
+	case reflect.Uint:
+		return strconv.IntSize
+	}
+	return 64
+}
+
+// unsignedUsage appends the accepted formats to a uint8 or octal flag's
+// help
+func unsignedUsage(typ, desc string) string {
+	formats := uint8Formats
+	if typ == "octal" {
+		formats = octalFormats
+	}
+	if desc == "" {
+		return formats
+	}
+	return desc + " (" + formats + ")"
+}
+
+// formatOctal writes an octal value the way it is usually given, e.g. 0644
+func formatOctal(n uint64) string {
+	if n == 0 {
+		return "0"
+	}
+	return "0" + strconv.FormatUint(n, 8)
+}
@@ -0,0 +1,324 @@
+package coil
+
+import (
+	"context"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/cvlstack/coil/remote"
+)
+
+// Option customises how NewConfig loads a configuration
+type Option func(*options)
+
+// options holds the settings applied by Option functions
+type options struct {
+	merge              bool
+	globalParse        bool
+	deprecationHandler func(oldKey, msg string)
+	envFile            string
+	envFiles           *envFiles
+	envPrefix          string
+	envKeyReplacer     *strings.Replacer
+	strict             bool
+	profile            string
+	args               []string
+	hasArgs            bool
+	prefix             string
+	fieldPrefixes      map[string]string
+	viper              *viper.Viper
+	remote             remote.RemoteSource
+	secretsManager     remote.RemoteSource
+	vault              *vaultSource
+	vaultRenewal       context.Context
+	adminToken         string
+	adminReload        bool
+	configPaths        []string
+	configName         string
+	configType         string
+	fallback           Configer
+	defaults           map[string]interface{}
+	parsers            map[string]parseFunc
+}
+
+// newOptions applies the given options on top of the defaults
+func newOptions(opts []Option) *options {
+	o := &options{
+		merge:              true,
+		deprecationHandler: warnDeprecated,
+		envKeyReplacer:     defaultEnvKeyReplacer,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithMerge controls whether the config flags are added to the global
+// pflag.CommandLine, e.g. so they show up in its usage output. Defaults to
+// true
+func WithMerge(merge bool) Option {
+	return func(o *options) {
+		o.merge = merge
+	}
+}
+
+// WithGlobalParse restores the legacy behaviour of parsing the global
+// pflag.CommandLine rather than a flagset scoped to the config. Defaults to
+// false
+//
+// Deprecated: global parsing breaks when several configs are loaded, tests
+// run in parallel or another library owns the command line. It is kept for
+// existing callers only
+func WithGlobalParse(enabled bool) Option {
+	return func(o *options) {
+		o.globalParse = enabled
+	}
+}
+
+// WithDeprecationHandler replaces the warning printed to stderr when a key
+// tagged deprecated is used. A nil handler silences the warnings
+func WithDeprecationHandler(fn func(oldKey, msg string)) Option {
+	return func(o *options) {
+		o.deprecationHandler = fn
+	}
+}
+
+// WithEnvFile loads a dotenv file beneath the environment and flags. The
+// env_file flag takes precedence, and a missing file only prints a warning
+func WithEnvFile(path string) Option {
+	return func(o *options) {
+		o.envFile = path
+	}
+}
+
+// envFiles holds the arguments given to WithEnvFiles
+type envFiles struct {
+	profile string
+	dirs    []string
+}
+
+// WithEnvFiles loads the layered .env, .env.<profile> and .env.local files
+// from dirs, or the working directory, with envfile.Load. Their values are
+// registered as defaults, so every other source takes precedence
+func WithEnvFiles(profile string, dirs ...string) Option {
+	return func(o *options) {
+		o.envFiles = &envFiles{profile: profile, dirs: dirs}
+	}
+}
+
+// WithEnvPrefix namespaces every environment variable lookup, so a key
+// named dbhost is read from PREFIX_DBHOST instead of DBHOST
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithStrictMode fails loading when an env var starting with the prefix
+// given to WithEnvPrefix is not read by any key, so a typo such as
+// APP_DBHSOT is reported as ErrUnknownKey instead of the default silently
+// applying. It has no effect without an env prefix
+func WithStrictMode() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// defaultEnvKeyReplacer maps the . and - of flag names onto the _ allowed
+// in env var names
+var defaultEnvKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// WithEnvKeyReplacer sets how flag names, with any env prefix, are turned
+// into env var names once uppercased. By default . and - become _, so a
+// key named db-host is read from DB_HOST
+func WithEnvKeyReplacer(r *strings.Replacer) Option {
+	return func(o *options) {
+		o.envKeyReplacer = r
+	}
+}
+
+// WithNoEnvKeyReplacer reads env vars named exactly after the uppercased
+// flag names, without the default replacement of . and - with _
+func WithNoEnvKeyReplacer() Option {
+	return func(o *options) {
+		o.envKeyReplacer = nil
+	}
+}
+
+// WithProfile selects the profile whose config section, overlay file and
+// profile_default tags apply. The profile flag and COIL_PROFILE env var
+// take precedence
+func WithProfile(profile string) Option {
+	return func(o *options) {
+		o.profile = profile
+	}
+}
+
+// WithArgs parses the given arguments, without the program name, instead of
+// os.Args. It takes precedence over WithGlobalParse, and an invalid flag
+// value is returned as an error rather than ignored
+func WithArgs(args []string) Option {
+	return func(o *options) {
+		o.args = args
+		o.hasArgs = true
+	}
+}
+
+// WithPrefix prepends prefix and an underscore to every key of the config,
+// as a prefix tag does for a nested struct. It keeps configs of the same
+// type apart, e.g. in a ConfigGroup
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// WithFieldPrefix replaces the prefix tag of the nested struct field named
+// fieldName, at the top level of the config, with prefix. It lets the
+// registration of a config decide the keys of a reused struct, e.g. a
+// DatabaseConfig field named Replica read from replica_dbhost. An empty
+// prefix removes the field's prefix. The prefix given by WithPrefix still
+// comes first
+func WithFieldPrefix(fieldName, prefix string) Option {
+	return func(o *options) {
+		if o.fieldPrefixes == nil {
+			o.fieldPrefixes = make(map[string]string)
+		}
+		o.fieldPrefixes[fieldName] = prefix
+	}
+}
+
+// WithViper populates the config from an existing parser, binding the
+// config's flags to it instead of creating a parser of its own. The config
+// file named by --config is only read if the parser has not read one
+func WithViper(v *viper.Viper) Option {
+	return func(o *options) {
+		o.viper = v
+	}
+}
+
+// WithRemoteSource reads keys from a remote store such as etcd, Consul or
+// AWS SSM. A key found in the store overrides the environment, dotenv and
+// config files, but not a flag given on the command line
+func WithRemoteSource(src remote.RemoteSource) Option {
+	return func(o *options) {
+		o.remote = src
+	}
+}
+
+// WithSecretsSource reads keys from a secret store such as AWS Secrets
+// Manager at the same priority as env vars: its values override dotenv
+// and config files, but a set env var, a remote source or a flag wins.
+// The stores' sub-packages wrap it, e.g. aws.WithSecretsManagerSource
+func WithSecretsSource(src remote.RemoteSource) Option {
+	return func(o *options) {
+		o.secretsManager = src
+	}
+}
+
+// WithVaultSource reads the key-value secret at path from the Vault server
+// at addr, matching its keys to flag names case-insensitively. Like a
+// remote source, the secrets override everything but command line flags.
+// KV v2 secrets are read through their data path, e.g. secret/data/app
+func WithVaultSource(addr, token, path string) Option {
+	return func(o *options) {
+		o.vault = &vaultSource{addr: addr, token: token, path: path}
+	}
+}
+
+// WithVaultRenewal keeps the Vault token given to WithVaultSource alive
+// until ctx is done, renewing it before it expires. The secret is read
+// again on each renewal and the config reloaded when it has changed
+func WithVaultRenewal(ctx context.Context) Option {
+	return func(o *options) {
+		o.vaultRenewal = ctx
+	}
+}
+
+// WithAdminAuth requires requests to AdminHTTPHandler to carry the given
+// bearer token in their Authorization header
+func WithAdminAuth(bearerToken string) Option {
+	return func(o *options) {
+		o.adminToken = bearerToken
+	}
+}
+
+// WithAdminReload enables the POST /config/reload endpoint of
+// AdminHTTPHandler. Defaults to false
+func WithAdminReload(enabled bool) Option {
+	return func(o *options) {
+		o.adminReload = enabled
+	}
+}
+
+// WithConfigSearchPaths looks for a config file in each directory in turn
+// when no --config flag is given, reading the first one found. Nothing is
+// read when none of them holds one. See XDGConfigPaths for the usual
+// locations
+func WithConfigSearchPaths(paths ...string) Option {
+	return func(o *options) {
+		o.configPaths = append(o.configPaths, paths...)
+	}
+}
+
+// WithConfigName sets the file name, without extension, looked for in the
+// WithConfigSearchPaths directories. Defaults to config
+func WithConfigName(name string) Option {
+	return func(o *options) {
+		o.configName = name
+	}
+}
+
+// WithConfigType sets the format of the config file, e.g. yaml, both for
+// the file given by --config and the searched one, whose search it
+// restricts to that format. By default files are parsed by their
+// extension, and the format of a file without a known extension is
+// detected from its first characters: { for JSON, --- for YAML and [ for
+// TOML
+func WithConfigType(configType string) Option {
+	return func(o *options) {
+		o.configType = configType
+	}
+}
+
+// WithFallback reads every key that none of the config's own sources
+// supplies from fallback instead, if one of the fallback's sources supplied
+// it. Defaults of the config still apply to keys neither supplies, e.g.
+//
+//	base, _ := coil.NewConfig(&OrgConfig{}, coil.WithConfigSearchPaths("/etc/org"))
+//	svc, _ := coil.NewConfig(&ServiceConfig{}, coil.WithFallback(base))
+func WithFallback(fallback Configer) Option {
+	return func(o *options) {
+		o.fallback = fallback
+	}
+}
+
+// WithDefaults replaces the default tag of the keys in defaults, keyed by
+// flag name, with values computed at runtime, e.g. from service discovery.
+// Every source still takes precedence over them. Keys that are not
+// registered by the config return ErrUnknownKey
+func WithDefaults(defaults map[string]interface{}) Option {
+	return func(o *options) {
+		o.defaults = defaults
+	}
+}
+
+// WithCustomParser registers fn to convert the raw string value of the key
+// flagName, e.g. a resource quantity or a duration with business day
+// units, instead of coil's own conversion. The result is assigned to the
+// field and must be of its type, or of the type it points to. Fields tagged
+// type:"custom" are declared as string flags and require a parser. Errors
+// and panics in fn are returned as ValidationErrors
+func WithCustomParser(
+	flagName string,
+	fn func(string) (interface{}, error),
+) Option {
+	return func(o *options) {
+		if o.parsers == nil {
+			o.parsers = make(map[string]parseFunc)
+		}
+		o.parsers[flagName] = fn
+	}
+}
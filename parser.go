@@ -0,0 +1,104 @@
+package coil
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// parseFunc converts the raw string value of a key into the value
+// assigned to its field, see WithCustomParser
+type parseFunc func(string) (interface{}, error)
+
+// applyParsers assigns the fields whose keys have a parser registered with
+// WithCustomParser, overriding the conversion coil made. Fields tagged
+// type:"custom" must have one
+func applyParsers(c Configer, parsers map[string]parseFunc) ValidationErrors {
+	var errs ValidationErrors
+	for key := range parsers {
+		if _, _, ok := lookupField(c, key); !ok {
+			errs = append(errs, fmt.Errorf("%w: %q", ErrUnknownKey, key))
+		}
+	}
+	b := c.base()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append(errs, runParsers(c, parsers)...)
+}
+
+// runParsers assigns the fields whose keys have a parser. The caller must
+// hold the write lock
+func runParsers(c Configer, parsers map[string]parseFunc) ValidationErrors {
+	var errs ValidationErrors
+	parser := c.getParser()
+	walkConfig(
+		c,
+		func(def fieldDef, field reflect.StructField, fv reflect.Value) {
+			fn, ok := parsers[def.Name]
+			if !ok {
+				if def.Type == "custom" {
+					errs = append(errs, fmt.Errorf(
+						"%s: no parser registered with WithCustomParser",
+						def.Name,
+					))
+				}
+				return
+			}
+			// Pointers stay nil unless a source supplies a value
+			if field.Type.Kind() == reflect.Ptr &&
+				def.Default == "" &&
+				!parser.IsSet(def.Name) {
+				fv.Set(reflect.Zero(field.Type))
+				return
+			}
+			val := def.Default
+			if parser.IsSet(def.Name) {
+				val = parser.GetString(def.Name)
+			}
+			if err := setParsed(fv, fn, val); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", def.Name, err))
+			}
+		},
+	)
+	return errs
+}
+
+// setParsed assigns the value fn parses from val to fv. A nil value
+// assigns the zero value, and a pointer field is also given a value of the
+// type it points to
+func setParsed(fv reflect.Value, fn parseFunc, val string) error {
+	parsed, err := callParser(fn, val)
+	if err != nil {
+		return err
+	}
+	if parsed == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+	pv := reflect.ValueOf(parsed)
+	switch {
+	case pv.Type().AssignableTo(fv.Type()):
+		fv.Set(pv)
+	case fv.Kind() == reflect.Ptr && pv.Type().AssignableTo(fv.Type().Elem()):
+		ptr := reflect.New(fv.Type().Elem())
+		ptr.Elem().Set(pv)
+		fv.Set(ptr)
+	default:
+		return fmt.Errorf(
+			"%w: parser returned %s for a %s field",
+			ErrTypeMismatch,
+			pv.Type(),
+			fv.Type(),
+		)
+	}
+	return nil
+}
+
+// callParser runs fn, turning a panic into an error
+func callParser(fn parseFunc, val string) (parsed interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parser panicked: %v", r)
+		}
+	}()
+	return fn(val)
+}
@@ -0,0 +1,139 @@
+package coil
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ParserCfg for custom parser testing
+type ParserCfg struct {
+	Config
+	Grace   time.Duration `type:"custom" name:"parser_grace"   default:"1bd"`
+	Host    string        `type:"string" name:"parser_host"    default:"localhost"`
+	Workers *int          `type:"custom" name:"parser_workers"`
+}
+
+// businessDays parses durations counted in 8 hour business days
+func businessDays(s string) (interface{}, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "bd"))
+	if err != nil {
+		return nil, err
+	}
+	return time.Duration(n) * 8 * time.Hour, nil
+}
+
+func parserOpts(extra ...Option) []Option {
+	return append([]Option{
+		WithMerge(false),
+		WithCustomParser("parser_grace", businessDays),
+		WithCustomParser("parser_workers", func(s string) (interface{}, error) {
+			return strconv.Atoi(s)
+		}),
+	}, extra...)
+}
+
+func TestWithCustomParser(t *testing.T) {
+	c, err := NewConfig(&ParserCfg{}, parserOpts(WithArgs(nil))...)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg := c.(*ParserCfg)
+	if cfg.Grace != 8*time.Hour {
+		t.Errorf("Grace = %v, want %v", cfg.Grace, 8*time.Hour)
+	}
+	if cfg.Workers != nil {
+		t.Errorf("Workers = %d, want nil", *cfg.Workers)
+	}
+
+	c, err = NewConfig(&ParserCfg{}, parserOpts(
+		WithArgs([]string{"--parser_grace=3bd", "--parser_workers=4"}),
+		WithCustomParser("parser_host", func(s string) (interface{}, error) {
+			return strings.ToUpper(s), nil
+		}),
+	)...)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg = c.(*ParserCfg)
+	if cfg.Grace != 24*time.Hour {
+		t.Errorf("Grace = %v, want %v", cfg.Grace, 24*time.Hour)
+	}
+	if cfg.Workers == nil || *cfg.Workers != 4 {
+		t.Errorf("Workers = %v, want 4", cfg.Workers)
+	}
+	if cfg.Host != "LOCALHOST" {
+		t.Errorf("Host = %q, want %q", cfg.Host, "LOCALHOST")
+	}
+}
+
+func TestWithCustomParserErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		is   error
+		want string
+	}{
+		{
+			name: "parse error",
+			opts: parserOpts(WithArgs([]string{"--parser_grace=soon"})),
+			want: "parser_grace: strconv.Atoi",
+		},
+		{
+			name: "panic",
+			opts: parserOpts(
+				WithArgs(nil),
+				WithCustomParser("parser_host", func(string) (interface{}, error) {
+					panic("boom")
+				}),
+			),
+			want: "parser_host: parser panicked: boom",
+		},
+		{
+			name: "type mismatch",
+			opts: parserOpts(
+				WithArgs(nil),
+				WithCustomParser("parser_host", func(string) (interface{}, error) {
+					return 42, nil
+				}),
+			),
+			is:   ErrTypeMismatch,
+			want: "parser returned int for a string field",
+		},
+		{
+			name: "missing parser",
+			opts: []Option{
+				WithMerge(false),
+				WithArgs(nil),
+				WithCustomParser("parser_grace", businessDays),
+			},
+			want: "parser_workers: no parser registered",
+		},
+		{
+			name: "unknown key",
+			opts: parserOpts(
+				WithArgs(nil),
+				WithCustomParser("parser_missing", businessDays),
+			),
+			is:   ErrUnknownKey,
+			want: `"parser_missing"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewConfig(&ParserCfg{}, tt.opts...)
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("NewConfig() error = %v, want ValidationErrors", err)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("NewConfig() error = %v, want %v", err, tt.is)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package coil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Print writes the current values of the config, one line per key in
+// struct order, as "[SOURCE] key = value" where SOURCE is the layer the
+// value came from: flag, env, file or default. Secrets are redacted. It is
+// meant for logging the effective config at startup
+func (c *Config) Print(w io.Writer) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.root == nil {
+		return errors.New("config has not been loaded")
+	}
+	var b bytes.Buffer
+	walkConfig(
+		c.root,
+		func(def fieldDef, _ reflect.StructField, fv reflect.Value) {
+			if !fv.CanInterface() {
+				return
+			}
+			var val interface{} = Redacted
+			if !def.Secret {
+				val = exportValue(fv, def)
+			}
+			if val == nil {
+				val = ""
+			}
+			fmt.Fprintf(&b, "[%s] %s = %v\n", c.valueSource(def), def.Name, val)
+		},
+	)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// valueSource names the layer a key is read from, checked in the order
+// the parser gives them precedence
+func (c *Config) valueSource(def fieldDef) string {
+	if flagChanged(c.flags, def.Name) {
+		return "flag"
+	}
+	if envSet(def, c.opts) {
+		return "env"
+	}
+	if c.viper.InConfig(def.Name) {
+		return "file"
+	}
+	return "default"
+}
@@ -0,0 +1,60 @@
+package coil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// PrintCfg for print testing
+type PrintCfg struct {
+	Config
+	DB DatabaseConfig `prefix:"print"`
+}
+
+func TestPrint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "print_dbname: orders\nprint_dbpass: hunter2\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	origVal := os.Getenv("PRINT_DBUSER")
+	os.Setenv("PRINT_DBUSER", "app")
+	t.Cleanup(func() { restoreEnv("PRINT_DBUSER", origVal) })
+	c, err := NewConfig(
+		&PrintCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--config=" + path, "--print_dbport=6543"}),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	var b bytes.Buffer
+	if err := c.(*PrintCfg).Print(&b); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"[default] print_dbhost = localhost\n",
+		"[flag] print_dbport = 6543\n",
+		"[env] print_dbuser = app\n",
+		"[file] print_dbname = orders\n",
+		"[file] print_dbpass = [REDACTED]\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Print() missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Errorf("Print() leaked a secret:\n%s", out)
+	}
+	if !strings.HasPrefix(out, "[default] print_dbdriver") {
+		t.Errorf("Print() not in struct order:\n%s", out)
+	}
+
+	if err := (&Config{}).Print(&b); err == nil {
+		t.Error("Print() on an unloaded config should fail")
+	}
+}
@@ -0,0 +1,94 @@
+package coil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ProfileEnv names the env var selecting the active profile
+const ProfileEnv = "COIL_PROFILE"
+
+// Profile returns the active profile, or an empty string if none is set
+func (c *Config) Profile() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.profile
+}
+
+// activeProfile resolves the profile from the profile flag, then the
+// COIL_PROFILE env var, then WithProfile
+func activeProfile(v *viper.Viper, o *options) string {
+	if profile := v.GetString("profile"); profile != "" {
+		return profile
+	}
+	if profile := os.Getenv(ProfileEnv); profile != "" {
+		return profile
+	}
+	return o.profile
+}
+
+// applyProfile layers the profile section and overlay file over the config
+// file and registers the profile_default values of matching fields
+func applyProfile(c Configer, profile string) error {
+	if profile == "" {
+		return nil
+	}
+	parser := c.getParser()
+	if err := mergeProfileConfig(parser, profile); err != nil {
+		return err
+	}
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			if val, ok := profileDefault(def.ProfileDefault, profile); ok {
+				parser.SetDefault(def.Name, val)
+			}
+		},
+	)
+	return nil
+}
+
+// mergeProfileConfig merges the section named after the profile in the
+// config file, then a <profile> file with the same extension next to it
+func mergeProfileConfig(v *viper.Viper, profile string) error {
+	file := v.ConfigFileUsed()
+	if file == "" {
+		return nil
+	}
+	if section, ok := v.Get(profile).(map[string]interface{}); ok {
+		if err := v.MergeConfigMap(section); err != nil {
+			return err
+		}
+	}
+	overlay := filepath.Join(filepath.Dir(file), profile+filepath.Ext(file))
+	if overlay == file {
+		return nil
+	}
+	if _, err := os.Stat(overlay); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	v.SetConfigFile(overlay)
+	defer v.SetConfigFile(file)
+	return v.MergeInConfig()
+}
+
+// profileDefault looks up the default for a profile in a
+// profile_default tag of comma separated profile=value pairs
+func profileDefault(tag, profile string) (string, bool) {
+	if tag == "" {
+		return "", false
+	}
+	for _, pair := range strings.Split(tag, ",") {
+		name, val, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(name) == profile {
+			return val, true
+		}
+	}
+	return "", false
+}
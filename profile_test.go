@@ -0,0 +1,111 @@
+package coil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ProfileCfg for profile testing
+type ProfileCfg struct {
+	Config
+	URL     string `type:"string" name:"prof_url"     default:"http://localhost:8080" desc:"API URL" profile_default:"prod=https://api.example.com,staging=https://staging.example.com"`
+	Workers int    `type:"int"    name:"prof_workers" default:"1"                     desc:"Workers"`
+	Region  string `type:"string" name:"prof_region"  default:"local"                 desc:"Region"`
+}
+
+// clearProfileEnv unsets COIL_PROFILE for the duration of the test
+func clearProfileEnv(t *testing.T) {
+	origVal := os.Getenv(ProfileEnv)
+	os.Unsetenv(ProfileEnv)
+	t.Cleanup(func() { restoreEnv(ProfileEnv, origVal) })
+}
+
+func TestProfileDefault(t *testing.T) {
+	clearProfileEnv(t)
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{"", "http://localhost:8080"},
+		{"prod", "https://api.example.com"},
+		{"staging", "https://staging.example.com"},
+		{"dev", "http://localhost:8080"},
+	}
+	for _, tt := range tests {
+		cfg := MustNewConfig(
+			&ProfileCfg{},
+			WithMerge(false),
+			WithProfile(tt.profile),
+		).(*ProfileCfg)
+		if cfg.URL != tt.want {
+			t.Errorf(
+				"profile %q: URL = %q, want %q",
+				tt.profile,
+				cfg.URL,
+				tt.want,
+			)
+		}
+		if cfg.Profile() != tt.profile {
+			t.Errorf("Profile() = %q, want %q", cfg.Profile(), tt.profile)
+		}
+	}
+}
+
+func TestProfileConfigFiles(t *testing.T) {
+	clearProfileEnv(t)
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml": "prof_workers: 2\nprod:\n  prof_workers: 8\n",
+		"prod.yaml":   "prof_region: eu-west-1\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app", "--config=" + filepath.Join(dir, "config.yaml")}
+
+	cfg := MustNewConfig(&ProfileCfg{}, WithMerge(false)).(*ProfileCfg)
+	if cfg.Workers != 2 || cfg.Region != "local" {
+		t.Errorf(
+			"without profile Workers, Region = %d, %q, want 2, local",
+			cfg.Workers,
+			cfg.Region,
+		)
+	}
+
+	os.Setenv(ProfileEnv, "prod")
+	cfg = MustNewConfig(&ProfileCfg{}, WithMerge(false)).(*ProfileCfg)
+	if cfg.Workers != 8 || cfg.Region != "eu-west-1" {
+		t.Errorf(
+			"prod Workers, Region = %d, %q, want 8, eu-west-1",
+			cfg.Workers,
+			cfg.Region,
+		)
+	}
+}
+
+func TestProfilePrecedence(t *testing.T) {
+	clearProfileEnv(t)
+	os.Setenv(ProfileEnv, "staging")
+	cfg := MustNewConfig(
+		&ProfileCfg{},
+		WithMerge(false),
+		WithProfile("dev"),
+	).(*ProfileCfg)
+	if cfg.Profile() != "staging" {
+		t.Errorf("Profile() = %q, want env profile staging", cfg.Profile())
+	}
+
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app", "--profile=prod"}
+	cfg = MustNewConfig(&ProfileCfg{}, WithMerge(false)).(*ProfileCfg)
+	if cfg.Profile() != "prod" {
+		t.Errorf("Profile() = %q, want flag profile %q", cfg.Profile(), "prod")
+	}
+}
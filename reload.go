@@ -0,0 +1,75 @@
+package coil
+
+import (
+	"context"
+	"errors"
+)
+
+// Reload re-reads the config file, dotenv file and environment and updates
+// the config fields in place, so holders of the config pointer see the new
+// values. The fields are written while the config's write lock is held,
+// so readers using RLock never see a partial update.
+// BeforeReload hooks run before the values change and AfterReload hooks
+// once they have been updated, even if validation fails
+func (c *Config) Reload() error {
+	if c.root == nil || c.viper == nil {
+		return errors.New("config has not been loaded")
+	}
+	c.mu.RLock()
+	before := append([]func(){}, c.beforeReload...)
+	after := append([]func(){}, c.afterReload...)
+	c.mu.RUnlock()
+	for _, fn := range before {
+		fn()
+	}
+	err := c.reload()
+	for _, fn := range after {
+		fn()
+	}
+	return err
+}
+
+// reload repopulates the config, one reload at a time. The fields and the
+// parser are written under the write lock, but remote sources are read and
+// validation runs without it
+func (c *Config) reload() error {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	if c.viper.ConfigFileUsed() != "" {
+		// Print and the admin handler read the parser under the read lock
+		c.mu.Lock()
+		err := c.viper.ReadInConfig()
+		c.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	c.mu.RLock()
+	o := c.opts
+	old := c.liveValues()
+	c.mu.RUnlock()
+	if o == nil {
+		o = newOptions(nil)
+	}
+	err := populate(context.Background(), c.root, o)
+	c.mu.Lock()
+	c.publish(old, c.liveValues())
+	c.mu.Unlock()
+	return err
+}
+
+// BeforeReload registers a hook run before Reload updates the values, e.g.
+// to drain connections
+func (c *Config) BeforeReload(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.beforeReload = append(c.beforeReload, fn)
+}
+
+// AfterReload registers a hook run after Reload has updated the values,
+// e.g. to invalidate caches
+func (c *Config) AfterReload(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.afterReload = append(c.afterReload, fn)
+}
@@ -0,0 +1,159 @@
+package coil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// ReloadCfg for reload testing
+type ReloadCfg struct {
+	Config
+	Host string `type:"string" name:"reload_host" default:"localhost" desc:"Host"`
+	Port int    `type:"int"    name:"reload_port" default:"80"        desc:"Port"`
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("reload_host: first.example.com\nreload_port: 8080\n")
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app", "--config=" + path}
+
+	cfg := MustNewConfig(&ReloadCfg{}, WithMerge(false)).(*ReloadCfg)
+	if cfg.Host != "first.example.com" {
+		t.Fatalf("Host = %q, want %q", cfg.Host, "first.example.com")
+	}
+
+	var events []string
+	cfg.BeforeReload(func() { events = append(events, "before:"+cfg.Host) })
+	cfg.AfterReload(func() { events = append(events, "after:"+cfg.Host) })
+
+	write("reload_host: second.example.com\nreload_port: 9090\n")
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if cfg.Host != "second.example.com" || cfg.Port != 9090 {
+		t.Errorf(
+			"after Reload() Host, Port = %q, %d, want %q, %d",
+			cfg.Host,
+			cfg.Port,
+			"second.example.com",
+			9090,
+		)
+	}
+	want := []string{"before:first.example.com", "after:second.example.com"}
+	if len(events) != 2 || events[0] != want[0] || events[1] != want[1] {
+		t.Errorf("hooks ran as %q, want %q", events, want)
+	}
+}
+
+func TestReloadConcurrentReads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(i int) {
+		data := fmt.Sprintf("reload_host: host%d\nreload_port: %d\n", i, i)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Error(err)
+		}
+	}
+	write(0)
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app", "--config=" + path}
+
+	cfg := MustNewConfig(&ReloadCfg{}, WithMerge(false)).(*ReloadCfg)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			cfg.RLock()
+			host, port := cfg.Host, cfg.Port
+			cfg.RUnlock()
+			if host != fmt.Sprintf("host%d", port) {
+				t.Errorf("read torn values %q and %d", host, port)
+				return
+			}
+		}
+	}()
+	for i := 1; i <= 50; i++ {
+		write(i)
+		if err := cfg.Reload(); err != nil {
+			t.Errorf("Reload() error = %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+// Test the exported readers hold the read lock while Reload writes the
+// fields and the parser. Run with -race
+func TestReloadConcurrentExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(i int) {
+		data := fmt.Sprintf("reload_host: host%d\nreload_port: %d\n", i, i)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Error(err)
+		}
+	}
+	write(0)
+	origArgs := os.Args
+	defer func() { os.Args = origArgs }()
+	os.Args = []string{"app", "--config=" + path}
+
+	cfg := MustNewConfig(&ReloadCfg{}, WithMerge(false)).(*ReloadCfg)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			values := ToMap(cfg)
+			want := fmt.Sprintf("host%d", values["reload_port"])
+			if values["reload_host"] != want {
+				t.Errorf("ToMap() read torn values %v", values)
+				return
+			}
+			Reveal(cfg, "reload_host")
+			Value(cfg, "reload_port")
+			ToEnv(cfg)
+			cfg.Print(io.Discard)
+		}
+	}()
+	for i := 1; i <= 50; i++ {
+		write(i)
+		if err := cfg.Reload(); err != nil {
+			t.Errorf("Reload() error = %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestReloadUnloaded(t *testing.T) {
+	cfg := &ReloadCfg{}
+	if err := cfg.Reload(); err == nil {
+		t.Error("Reload() on an unloaded config should return an error")
+	}
+}
@@ -0,0 +1,76 @@
+package coil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/pflag"
+
+	"github.com/cvlstack/coil/remote"
+)
+
+// applyRemote overrides each key found in the remote source, unless its
+// flag was given on the command line. Keys removed from the store keep
+// their last value until the config is loaded again
+func applyRemote(
+	ctx context.Context,
+	c Configer,
+	src remote.RemoteSource,
+) error {
+	if src == nil {
+		return nil
+	}
+	return overrideKeys(c, func(key string) (string, error) {
+		val, err := remote.Get(ctx, src, key)
+		if err != nil && !errors.Is(err, remote.ErrNotFound) {
+			return "", fmt.Errorf(
+				"could not read %s from remote source: %w",
+				key,
+				err,
+			)
+		}
+		return val, err
+	})
+}
+
+// overrideKeys sets every key the lookup finds on the parser, above the
+// environment and config files but beneath flags given on the command
+// line. Keys the lookup reports as remote.ErrNotFound are left alone, as
+// are those reported as remote.ErrUnreadable unless they are required
+func overrideKeys(c Configer, lookup func(key string) (string, error)) error {
+	b := c.base()
+	parser := c.getParser()
+	var err error
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			if err != nil || flagChanged(b.flags, def.Name) {
+				return
+			}
+			val, lookupErr := lookup(def.Name)
+			if errors.Is(lookupErr, remote.ErrNotFound) ||
+				errors.Is(lookupErr, remote.ErrUnreadable) && !def.Required {
+				return
+			}
+			if lookupErr != nil {
+				err = lookupErr
+				return
+			}
+			b.mu.Lock()
+			parser.Set(def.Name, val)
+			b.mu.Unlock()
+		},
+	)
+	return err
+}
+
+// flagChanged reports whether the named flag was set on the command line
+func flagChanged(fs *pflag.FlagSet, name string) bool {
+	if fs == nil {
+		return false
+	}
+	f := fs.Lookup(name)
+	return f != nil && f.Changed
+}
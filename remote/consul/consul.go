@@ -0,0 +1,248 @@
+// Package consul implements a coil remote source backed by the Consul KV
+// store
+package consul
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/cvlstack/coil"
+	"github.com/cvlstack/coil/remote"
+)
+
+// retryDelay is how long Watch waits after a failed query
+const retryDelay = 5 * time.Second
+
+// waitTime is how long a blocking query of WatchPrefix waits for a change
+// before Consul answers with the current values
+const waitTime = 55 * time.Second
+
+// Option customises a Consul source
+type Option func(*source)
+
+// WithContext stops Watch once ctx is done, closing the channels it
+// returned. Without it the watches run as long as the program
+func WithContext(ctx context.Context) Option {
+	return func(s *source) {
+		s.ctx = ctx
+	}
+}
+
+// source reads keys from Consul. An error creating the client is returned
+// by every call
+type source struct {
+	kv  *api.KV
+	err error
+	ctx context.Context
+}
+
+// newSource returns a source for kv with opts applied
+func newSource(kv *api.KV, err error, opts []Option) *source {
+	s := &source{kv: kv, err: err, ctx: context.Background()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// NewSource returns a source reading keys from the Consul agent at addr
+func NewSource(addr string, opts ...Option) remote.RemoteSource {
+	cfg := api.DefaultConfig()
+	cfg.Address = addr
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		return newSource(nil, err, opts)
+	}
+	return newSource(client.KV(), nil, opts)
+}
+
+// Get returns the value stored under key
+func (s *source) Get(key string) (string, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext returns the value stored under key, giving up once ctx is
+// done
+func (s *source) GetContext(ctx context.Context, key string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	pair, _, err := s.kv.Get(key, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	if pair == nil {
+		return "", remote.ErrNotFound
+	}
+	return string(pair.Value), nil
+}
+
+// Watch returns a channel receiving the value of key each time it changes,
+// using Consul blocking queries, until the source's context is done. A
+// value not yet received is replaced by the next one. Failed queries are
+// retried
+func (s *source) Watch(key string) (<-chan string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	ch := make(chan string, 1)
+	go func() {
+		defer close(ch)
+		var index uint64
+		for s.ctx.Err() == nil {
+			opts := (&api.QueryOptions{WaitIndex: index}).WithContext(s.ctx)
+			pair, meta, err := s.kv.Get(key, opts)
+			if err != nil {
+				select {
+				case <-s.ctx.Done():
+				case <-time.After(retryDelay):
+				}
+				continue
+			}
+			// The index can go backwards, e.g. after a snapshot restore
+			if meta.LastIndex < index {
+				index = 0
+			}
+			if index != 0 && meta.LastIndex != index && pair != nil {
+				send(ch, string(pair.Value))
+			}
+			index = meta.LastIndex
+		}
+	}()
+	return ch, nil
+}
+
+// send hands val to ch without waiting for it to be received, replacing a
+// value still waiting. Only the watch goroutine sends
+func send(ch chan string, val string) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- val
+}
+
+// PrefixSource reads the keys beneath a KV prefix, stripped of the prefix,
+// so the key dbhost is read from <prefix>/dbhost
+type PrefixSource struct {
+	source
+	prefix string
+}
+
+// NewPrefixSource returns a source reading the keys beneath prefix from
+// the Consul agent at addr, authenticating with token if it is not empty
+func NewPrefixSource(
+	addr, token, prefix string,
+	opts ...Option,
+) (*PrefixSource, error) {
+	cfg := api.DefaultConfig()
+	cfg.Address = addr
+	cfg.Token = token
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &PrefixSource{
+		source: *newSource(client.KV(), nil, opts),
+		prefix: prefix,
+	}, nil
+}
+
+// WithPrefixSource reads keys from beneath prefix in the Consul KV store
+// of the agent at addr, authenticating with token if it is not empty. It
+// is coil.WithRemoteSource with a NewPrefixSource, and an error creating
+// the client is returned when the config is loaded
+func WithPrefixSource(addr, token, prefix string, opts ...Option) coil.Option {
+	src, err := NewPrefixSource(addr, token, prefix, opts...)
+	if err != nil {
+		return coil.WithRemoteSource(newSource(nil, err, opts))
+	}
+	return coil.WithRemoteSource(src)
+}
+
+// Values returns every key beneath the prefix, stripped of the prefix
+func (s *PrefixSource) Values() (map[string]string, error) {
+	values, _, err := s.list(context.Background(), 0)
+	return values, err
+}
+
+// Get returns the value stored under key beneath the prefix
+func (s *PrefixSource) Get(key string) (string, error) {
+	return s.source.Get(s.prefix + key)
+}
+
+// GetContext returns the value stored under key beneath the prefix, giving
+// up once ctx is done
+func (s *PrefixSource) GetContext(
+	ctx context.Context,
+	key string,
+) (string, error) {
+	return s.source.GetContext(ctx, s.prefix+key)
+}
+
+// Watch returns a channel receiving the value of key beneath the prefix
+// each time it changes
+func (s *PrefixSource) Watch(key string) (<-chan string, error) {
+	return s.source.Watch(s.prefix + key)
+}
+
+// WatchPrefix long-polls the prefix with Consul blocking queries until ctx
+// is done, calling onChange with every key beneath it each time the index
+// advances. It is not named Watch as RemoteSource already defines Watch
+// for a single key. Failed queries are retried
+func (s *PrefixSource) WatchPrefix(
+	ctx context.Context,
+	onChange func(map[string]string),
+) {
+	go func() {
+		var index uint64
+		for ctx.Err() == nil {
+			values, last, err := s.list(ctx, index)
+			if err != nil {
+				select {
+				case <-ctx.Done():
+				case <-time.After(retryDelay):
+				}
+				continue
+			}
+			// The index can go backwards, e.g. after a snapshot restore
+			if last < index {
+				index = 0
+			}
+			if index != 0 && last != index {
+				onChange(values)
+			}
+			index = last
+		}
+	}()
+}
+
+// list reads every key beneath the prefix, blocking until the index moves
+// past index when it is not zero, and returns the values with the new index
+func (s *PrefixSource) list(
+	ctx context.Context,
+	index uint64,
+) (map[string]string, uint64, error) {
+	opts := (&api.QueryOptions{WaitIndex: index, WaitTime: waitTime}).
+		WithContext(ctx)
+	pairs, meta, err := s.kv.List(s.prefix, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key := strings.TrimPrefix(pair.Key, s.prefix)
+		if key == "" || strings.HasSuffix(key, "/") {
+			continue
+		}
+		values[key] = string(pair.Value)
+	}
+	return values, meta.LastIndex, nil
+}
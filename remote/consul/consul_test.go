@@ -0,0 +1,278 @@
+package consul
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cvlstack/coil"
+	"github.com/cvlstack/coil/remote"
+)
+
+func TestSourceGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/kv/dbhost" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("X-Consul-Index", "7")
+			fmt.Fprintf(
+				w,
+				`[{"Key":"dbhost","Value":%q}]`,
+				base64.StdEncoding.EncodeToString([]byte("db.internal")),
+			)
+		},
+	))
+	defer srv.Close()
+
+	src := NewSource(strings.TrimPrefix(srv.URL, "http://"))
+	val, err := src.Get("dbhost")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if val != "db.internal" {
+		t.Errorf("Get() = %q, want %q", val, "db.internal")
+	}
+	if _, err := src.Get("missing"); !errors.Is(err, remote.ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSourceGetContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		},
+	))
+	defer srv.Close()
+
+	src := NewSource(strings.TrimPrefix(srv.URL, "http://"))
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		20*time.Millisecond,
+	)
+	defer cancel()
+	_, err := remote.Get(ctx, src, "dbhost")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// kvServer is a Consul KV endpoint holding values under an index, whose
+// blocking queries return once the index moves
+type kvServer struct {
+	mu      sync.Mutex
+	values  map[string]string
+	index   int
+	changed chan struct{}
+	tokens  []string
+}
+
+func newKVServer(t *testing.T, values map[string]string) (*kvServer, string) {
+	t.Helper()
+	kv := &kvServer{values: values, index: 1, changed: make(chan struct{})}
+	srv := httptest.NewServer(kv)
+	t.Cleanup(srv.Close)
+	return kv, strings.TrimPrefix(srv.URL, "http://")
+}
+
+// set stores a value and advances the index
+func (kv *kvServer) set(key, val string) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.values[key] = val
+	kv.index++
+	close(kv.changed)
+	kv.changed = make(chan struct{})
+}
+
+func (kv *kvServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	kv.mu.Lock()
+	kv.tokens = append(kv.tokens, r.Header.Get("X-Consul-Token"))
+	changed := kv.changed
+	if r.URL.Query().Get("index") == strconv.Itoa(kv.index) {
+		kv.mu.Unlock()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		kv.mu.Lock()
+	}
+	defer kv.mu.Unlock()
+	var pairs []string
+	for k, v := range kv.values {
+		_, recurse := r.URL.Query()["recurse"]
+		if k == key || recurse && strings.HasPrefix(k, key) {
+			pairs = append(pairs, fmt.Sprintf(
+				`{"Key":%q,"Value":%q}`,
+				k,
+				base64.StdEncoding.EncodeToString([]byte(v)),
+			))
+		}
+	}
+	w.Header().Set("X-Consul-Index", strconv.Itoa(kv.index))
+	if len(pairs) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprintf(w, "[%s]", strings.Join(pairs, ","))
+}
+
+func TestPrefixSource(t *testing.T) {
+	kv, addr := newKVServer(t, map[string]string{
+		"app/dbhost":   "db.internal",
+		"app/dbport":   "6543",
+		"app/":         "",
+		"apple/dbhost": "other",
+	})
+	src, err := NewPrefixSource(addr, "s3cr3t", "/app")
+	if err != nil {
+		t.Fatalf("NewPrefixSource() error = %v", err)
+	}
+	values, err := src.Values()
+	if err != nil {
+		t.Fatalf("Values() error = %v", err)
+	}
+	want := map[string]string{"dbhost": "db.internal", "dbport": "6543"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Values() = %v, want %v", values, want)
+	}
+	if val, err := src.Get("dbhost"); err != nil || val != "db.internal" {
+		t.Errorf("Get() = %q, %v, want db.internal", val, err)
+	}
+	if _, err := src.Get("missing"); !errors.Is(err, remote.ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	for _, token := range kv.tokens {
+		if token != "s3cr3t" {
+			t.Errorf("X-Consul-Token = %q, want s3cr3t", token)
+		}
+	}
+}
+
+func TestPrefixSourceWatchPrefix(t *testing.T) {
+	kv, addr := newKVServer(t, map[string]string{"app/dbhost": "db.internal"})
+	src, err := NewPrefixSource(addr, "", "app")
+	if err != nil {
+		t.Fatalf("NewPrefixSource() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan map[string]string, 1)
+	src.WatchPrefix(ctx, func(values map[string]string) {
+		changes <- values
+	})
+	// Wait for the first query to be parked on the current index
+	for {
+		kv.mu.Lock()
+		n := len(kv.tokens)
+		kv.mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	kv.set("app/dbhost", "db2.internal")
+	select {
+	case values := <-changes:
+		if values["dbhost"] != "db2.internal" {
+			t.Errorf("onChange() values = %v, want dbhost=db2.internal", values)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("onChange() not called after the index advanced")
+	}
+}
+
+func TestSourceWatch(t *testing.T) {
+	kv, addr := newKVServer(t, map[string]string{"dbhost": "db.internal"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := NewSource(addr, WithContext(ctx))
+	ch, err := src.Watch("dbhost")
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	// Wait for the first query to be parked on the current index
+	for {
+		kv.mu.Lock()
+		n := len(kv.tokens)
+		kv.mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	kv.set("dbhost", "db2.internal")
+	select {
+	case val := <-ch:
+		if val != "db2.internal" {
+			t.Errorf("Watch() = %q, want %q", val, "db2.internal")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch() did not report the change")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("Watch() sent a value after ctx was cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch() channel not closed after ctx was cancelled")
+	}
+}
+
+// DBCfg for loading through Consul
+type DBCfg struct {
+	coil.Config
+	coil.DatabaseConfig
+}
+
+func TestWithPrefixSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/kv/app/dbhost" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(
+				w,
+				`[{"Key":"app/dbhost","Value":%q}]`,
+				base64.StdEncoding.EncodeToString([]byte("consul.internal")),
+			)
+		},
+	))
+	defer srv.Close()
+
+	c, err := coil.NewConfig(
+		&DBCfg{},
+		coil.WithMerge(false),
+		coil.WithArgs(nil),
+		WithPrefixSource(strings.TrimPrefix(srv.URL, "http://"), "", "app"),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg := c.(*DBCfg)
+	if cfg.DBHost != "consul.internal" {
+		t.Errorf("DBHost = %q, want the Consul value", cfg.DBHost)
+	}
+	if cfg.DBPort != 5432 {
+		t.Errorf("DBPort = %d, want the default", cfg.DBPort)
+	}
+}
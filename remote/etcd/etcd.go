@@ -0,0 +1,93 @@
+// Package etcd implements a coil remote source backed by etcd v3
+package etcd
+
+import (
+	"context"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/cvlstack/coil/remote"
+)
+
+// Option customises the etcd client configuration
+type Option func(*clientv3.Config)
+
+// WithContext sets the client's context, stopping Watch once ctx is done
+// and closing the channels it returned. Without it the watches run as long
+// as the program
+func WithContext(ctx context.Context) Option {
+	return func(cfg *clientv3.Config) {
+		cfg.Context = ctx
+	}
+}
+
+// source reads keys from etcd. An error creating the client is returned by
+// every call
+type source struct {
+	client *clientv3.Client
+	err    error
+}
+
+// NewSource returns a source reading keys from the etcd cluster at the
+// given endpoints
+func NewSource(endpoints []string, opts ...Option) remote.RemoteSource {
+	cfg := clientv3.Config{Endpoints: endpoints}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	client, err := clientv3.New(cfg)
+	return &source{client: client, err: err}
+}
+
+// Get returns the value stored under key
+func (s *source) Get(key string) (string, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext returns the value stored under key, giving up once ctx is
+// done
+func (s *source) GetContext(ctx context.Context, key string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", remote.ErrNotFound
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+// Watch returns a channel receiving the value of key each time it is put,
+// until the client's context is done. A value not yet received is replaced
+// by the next one. The channel is closed when the watch ends
+func (s *source) Watch(key string) (<-chan string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	ch := make(chan string, 1)
+	watch := s.client.Watch(s.client.Ctx(), key)
+	go func() {
+		defer close(ch)
+		for resp := range watch {
+			for _, ev := range resp.Events {
+				if ev.Type == clientv3.EventTypePut {
+					send(ch, string(ev.Kv.Value))
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// send hands val to ch without waiting for it to be received, replacing a
+// value still waiting. Only the watch goroutine sends
+func send(ch chan string, val string) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- val
+}
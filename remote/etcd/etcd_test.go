@@ -0,0 +1,13 @@
+package etcd
+
+import "testing"
+
+func TestNewSourceWithoutEndpoints(t *testing.T) {
+	src := NewSource(nil)
+	if _, err := src.Get("dbhost"); err == nil {
+		t.Error("Get() without endpoints should return an error")
+	}
+	if _, err := src.Watch("dbhost"); err == nil {
+		t.Error("Watch() without endpoints should return an error")
+	}
+}
@@ -0,0 +1,67 @@
+// Package remote defines the interface coil uses to read keys from remote
+// configuration stores such as etcd, Consul or AWS SSM. The stores are
+// implemented in sub-packages, so programs only pull in the clients they
+// use
+package remote
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by a RemoteSource for keys missing from the store
+var ErrNotFound = errors.New("key not found in remote source")
+
+// ErrUnreadable is wrapped around the errors of keys a RemoteSource could
+// not read, such as one it lacks permission for, when they need not fail
+// the load. The key keeps its value from the lower priority sources,
+// unless its field is required
+var ErrUnreadable = errors.New("key unreadable")
+
+// RemoteSource reads config values from a remote store. Keys are the flag
+// names of the config fields
+type RemoteSource interface {
+	// Get returns the value stored under key, or ErrNotFound
+	Get(key string) (string, error)
+	// Watch returns a channel receiving the new value each time the key
+	// changes in the store
+	Watch(key string) (<-chan string, error)
+}
+
+// ContextSource is implemented by remote sources whose reads can be
+// cancelled, so that loading with a deadline stops waiting on the store
+type ContextSource interface {
+	RemoteSource
+	// GetContext is like Get, giving up once ctx is done
+	GetContext(ctx context.Context, key string) (string, error)
+}
+
+// Get reads key from src, returning ctx.Err() once ctx is done. Sources
+// that do not implement ContextSource are read in a goroutine, which is
+// abandoned rather than stopped when ctx ends first
+func Get(ctx context.Context, src RemoteSource, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if cs, ok := src.(ContextSource); ok {
+		return cs.GetContext(ctx, key)
+	}
+	if ctx.Done() == nil {
+		return src.Get(key)
+	}
+	type result struct {
+		val string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		val, err := src.Get(key)
+		done <- result{val, err}
+	}()
+	select {
+	case r := <-done:
+		return r.val, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
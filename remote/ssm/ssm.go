@@ -0,0 +1,163 @@
+// Package ssm implements a coil remote source backed by the AWS Systems
+// Manager Parameter Store
+package ssm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+
+	"github.com/cvlstack/coil/remote"
+)
+
+const defaultPollInterval = 30 * time.Second
+
+// Option customises a Parameter Store source
+type Option func(*source)
+
+// WithPollInterval sets how often Watch checks the parameters for changes,
+// as the Parameter Store has no change notifications. Defaults to 30
+// seconds
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *source) {
+		s.pollInterval = interval
+	}
+}
+
+// WithContext stops Watch polling once ctx is done, closing the channels
+// it returned. Without it the poller runs as long as the program
+func WithContext(ctx context.Context) Option {
+	return func(s *source) {
+		s.ctx = ctx
+	}
+}
+
+// source reads parameters beneath a path prefix
+type source struct {
+	client       *ssm.Client
+	prefix       string
+	pollInterval time.Duration
+	ctx          context.Context
+
+	watchMu  sync.Mutex
+	watchers []*watcher
+}
+
+// watcher is a key passed to Watch and the last value sent for it
+type watcher struct {
+	key  string
+	last string
+	ch   chan string
+}
+
+// send hands val to the watcher without waiting for it to be received,
+// replacing a value still waiting, so one idle receiver cannot hold up
+// the others. Only the poller sends
+func (w *watcher) send(val string) {
+	select {
+	case <-w.ch:
+	default:
+	}
+	w.ch <- val
+}
+
+// NewSource returns a source reading the parameters beneath prefix, so the
+// key dbhost is read from <prefix>/dbhost. SecureString parameters are
+// decrypted
+func NewSource(
+	prefix string,
+	cfg aws.Config,
+	opts ...Option,
+) remote.RemoteSource {
+	s := &source{
+		client:       ssm.NewFromConfig(cfg),
+		prefix:       strings.TrimSuffix(prefix, "/"),
+		pollInterval: defaultPollInterval,
+		ctx:          context.Background(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Get returns the value of the parameter for key
+func (s *source) Get(key string) (string, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext returns the value of the parameter for key, giving up once
+// ctx is done
+func (s *source) GetContext(ctx context.Context, key string) (string, error) {
+	out, err := s.client.GetParameter(
+		ctx,
+		&ssm.GetParameterInput{
+			Name:           aws.String(s.prefix + "/" + key),
+			WithDecryption: aws.Bool(true),
+		},
+	)
+	var notFound *types.ParameterNotFound
+	if errors.As(err, &notFound) {
+		return "", remote.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Parameter.Value), nil
+}
+
+// Watch returns a channel receiving the value of key each time it
+// changes. A single poller per source reads every watched parameter each
+// poll interval, retrying failed polls. A value not yet received is
+// replaced by the next one
+func (s *source) Watch(key string) (<-chan string, error) {
+	last, err := s.Get(key)
+	if err != nil && !errors.Is(err, remote.ErrNotFound) {
+		return nil, err
+	}
+	ch := make(chan string, 1)
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	s.watchers = append(s.watchers, &watcher{key: key, last: last, ch: ch})
+	if len(s.watchers) == 1 {
+		go s.poll()
+	}
+	return ch, nil
+}
+
+// poll reads the watched parameters every poll interval and sends the
+// changed values to their watchers, until the source's context is done
+func (s *source) poll() {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.watchMu.Lock()
+			for _, w := range s.watchers {
+				close(w.ch)
+			}
+			s.watchers = nil
+			s.watchMu.Unlock()
+			return
+		case <-ticker.C:
+		}
+		s.watchMu.Lock()
+		watchers := append([]*watcher(nil), s.watchers...)
+		s.watchMu.Unlock()
+		for _, w := range watchers {
+			val, err := s.GetContext(s.ctx, w.key)
+			if err != nil || val == w.last {
+				continue
+			}
+			w.last = val
+			w.send(val)
+		}
+	}
+}
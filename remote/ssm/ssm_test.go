@@ -0,0 +1,109 @@
+package ssm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/cvlstack/coil/remote"
+)
+
+func TestSourceGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var in struct{ Name string }
+			json.NewDecoder(r.Body).Decode(&in)
+			w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+			if in.Name != "/app/dbhost" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"ParameterNotFound"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Parameter": map[string]string{
+					"Name":  in.Name,
+					"Value": "db.internal",
+				},
+			})
+		},
+	))
+	defer srv.Close()
+
+	src := NewSource("/app/", aws.Config{
+		Region:       "us-east-1",
+		Credentials:  aws.AnonymousCredentials{},
+		BaseEndpoint: aws.String(srv.URL),
+	})
+	val, err := src.Get("dbhost")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if val != "db.internal" {
+		t.Errorf("Get() = %q, want %q", val, "db.internal")
+	}
+	if _, err := src.Get("missing"); !errors.Is(err, remote.ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSourceWatch(t *testing.T) {
+	var value atomic.Value
+	value.Store("db.internal")
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var in struct{ Name string }
+			json.NewDecoder(r.Body).Decode(&in)
+			w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"Parameter": map[string]string{
+					"Name":  in.Name,
+					"Value": value.Load().(string),
+				},
+			})
+		},
+	))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := NewSource(
+		"/app",
+		aws.Config{
+			Region:       "us-east-1",
+			Credentials:  aws.AnonymousCredentials{},
+			BaseEndpoint: aws.String(srv.URL),
+		},
+		WithPollInterval(10*time.Millisecond),
+		WithContext(ctx),
+	)
+	ch, err := src.Watch("dbhost")
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	value.Store("db2.internal")
+	select {
+	case val := <-ch:
+		if val != "db2.internal" {
+			t.Errorf("Watch() = %q, want %q", val, "db2.internal")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch() did not report the changed parameter")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("Watch() sent a value after ctx was cancelled")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch() channel not closed after ctx was cancelled")
+	}
+}
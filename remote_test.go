@@ -0,0 +1,213 @@
+package coil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cvlstack/coil/remote"
+)
+
+// mapSource is a RemoteSource backed by a map
+type mapSource map[string]string
+
+func (m mapSource) Get(key string) (string, error) {
+	val, ok := m[key]
+	if !ok {
+		return "", remote.ErrNotFound
+	}
+	return val, nil
+}
+
+func (m mapSource) Watch(key string) (<-chan string, error) {
+	return nil, errors.New("not supported")
+}
+
+// RemoteCfg for remote source testing
+type RemoteCfg struct {
+	Config
+	DatabaseConfig
+}
+
+// errSource is a RemoteSource that cannot be reached
+type errSource struct{}
+
+func (errSource) Get(key string) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+func (errSource) Watch(key string) (<-chan string, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestWithRemoteSource(t *testing.T) {
+	origVal := os.Getenv("DBHOST")
+	defer restoreEnv("DBHOST", origVal)
+	os.Setenv("DBHOST", "env.example.com")
+
+	src := mapSource{"dbhost": "remote.example.com", "dbport": "6543"}
+	c, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--dbport=7000"}),
+		WithRemoteSource(src),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg := c.(*RemoteCfg)
+	if cfg.DBHost != "remote.example.com" {
+		t.Errorf("DBHost = %q, want the remote value", cfg.DBHost)
+	}
+	if cfg.DBPort != 7000 {
+		t.Errorf("DBPort = %d, want the flag value 7000", cfg.DBPort)
+	}
+	if cfg.DBDriver != "postgres" {
+		t.Errorf("DBDriver = %q, want the default", cfg.DBDriver)
+	}
+}
+
+func TestWithRemoteSourceError(t *testing.T) {
+	_, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs(nil),
+		WithRemoteSource(errSource{}),
+	)
+	if err == nil {
+		t.Error("NewConfig() with a failing source should return an error")
+	}
+}
+
+// unreadableSource is a RemoteSource denied every key
+type unreadableSource struct{}
+
+func (unreadableSource) Get(key string) (string, error) {
+	return "", fmt.Errorf("%w: permission denied", remote.ErrUnreadable)
+}
+
+func (unreadableSource) Watch(key string) (<-chan string, error) {
+	return nil, errors.New("not supported")
+}
+
+// RequiredRemoteCfg requires a key read from a remote source
+type RequiredRemoteCfg struct {
+	Config
+	Token string `type:"string" name:"remote_token" required:"true"`
+}
+
+func TestWithRemoteSourceUnreadable(t *testing.T) {
+	c, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs(nil),
+		WithRemoteSource(unreadableSource{}),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	if cfg := c.(*RemoteCfg); cfg.DBHost != "localhost" {
+		t.Errorf("DBHost = %q, want the default", cfg.DBHost)
+	}
+
+	_, err = NewConfig(
+		&RequiredRemoteCfg{},
+		WithMerge(false),
+		WithArgs(nil),
+		WithRemoteSource(unreadableSource{}),
+	)
+	if !errors.Is(err, remote.ErrUnreadable) {
+		t.Errorf("NewConfig() error = %v, want ErrUnreadable", err)
+	}
+}
+
+// slowSource is a RemoteSource whose reads block until release is closed
+type slowSource struct {
+	release chan struct{}
+}
+
+func (s slowSource) Get(key string) (string, error) {
+	<-s.release
+	return "", remote.ErrNotFound
+}
+
+func (s slowSource) Watch(key string) (<-chan string, error) {
+	return nil, errors.New("not supported")
+}
+
+// ctxSource is a remote.ContextSource recording the config found in the
+// context of its reads
+type ctxSource struct {
+	mapSource
+	seen *Configer
+}
+
+func (s ctxSource) GetContext(
+	ctx context.Context,
+	key string,
+) (string, error) {
+	*s.seen, _ = ctx.Value(ContextKey).(Configer)
+	return s.Get(key)
+}
+
+func TestNewConfigWithContext(t *testing.T) {
+	var seen Configer
+	src := ctxSource{mapSource{"dbhost": "remote.example.com"}, &seen}
+	cfg := &RemoteCfg{}
+	if _, err := NewConfigWithContext(
+		context.Background(),
+		cfg,
+		WithMerge(false),
+		WithArgs(nil),
+		WithRemoteSource(src),
+	); err != nil {
+		t.Fatalf("NewConfigWithContext() error = %v", err)
+	}
+	if cfg.DBHost != "remote.example.com" {
+		t.Errorf("DBHost = %q, want the remote value", cfg.DBHost)
+	}
+	if seen != cfg {
+		t.Errorf("ctx.Value(ContextKey) = %v, want the loading config", seen)
+	}
+}
+
+func TestNewConfigWithContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		20*time.Millisecond,
+	)
+	defer cancel()
+	start := time.Now()
+	_, err := NewConfigWithContext(
+		ctx,
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs(nil),
+		WithRemoteSource(slowSource{release}),
+	)
+	if err != context.DeadlineExceeded {
+		t.Errorf("NewConfigWithContext() error = %v, want %v",
+			err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("NewConfigWithContext() took %v after the deadline", elapsed)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = NewConfigWithContext(
+		ctx,
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs(nil),
+	)
+	if err != context.Canceled {
+		t.Errorf("NewConfigWithContext() error = %v, want %v",
+			err, context.Canceled)
+	}
+}
@@ -0,0 +1,37 @@
+package coil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cvlstack/coil/remote"
+)
+
+// applySecretsManager sets each key found in the secret at the same
+// priority as an env var: it overrides dotenv and config files, while a
+// set env var, a remote source or a flag given on the command line wins
+func applySecretsManager(
+	ctx context.Context,
+	c Configer,
+	src remote.RemoteSource,
+	o *options,
+) error {
+	if src == nil {
+		return nil
+	}
+	return overrideKeys(c, func(key string) (string, error) {
+		if def, _, _ := lookupField(c, key); envSet(def, o) {
+			return "", remote.ErrNotFound
+		}
+		val, err := remote.Get(ctx, src, key)
+		if err != nil && !errors.Is(err, remote.ErrNotFound) {
+			return "", fmt.Errorf(
+				"could not read %s from secrets manager: %w",
+				key,
+				err,
+			)
+		}
+		return val, err
+	})
+}
@@ -0,0 +1,53 @@
+package coil
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWithSecretsSource(t *testing.T) {
+	origVal := os.Getenv("DBHOST")
+	os.Setenv("DBHOST", "env.example.com")
+	t.Cleanup(func() { restoreEnv("DBHOST", origVal) })
+	secrets := mapSource{
+		"dbpass": "hunter2",
+		"dbhost": "secret.example.com",
+		"dbport": "6543",
+		"dbuser": "secret",
+	}
+	c, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--dbport=7000"}),
+		WithRemoteSource(mapSource{"dbuser": "remote"}),
+		WithSecretsSource(secrets),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	got := c.(*RemoteCfg)
+	if got.DBPass != "hunter2" {
+		t.Errorf("DBPass = %q, want the secret value", got.DBPass)
+	}
+	if got.DBHost != "env.example.com" {
+		t.Errorf("DBHost = %q, want the env value", got.DBHost)
+	}
+	if got.DBPort != 7000 {
+		t.Errorf("DBPort = %d, want the flag value 7000", got.DBPort)
+	}
+	if got.DBUser != "remote" {
+		t.Errorf("DBUser = %q, want the remote value", got.DBUser)
+	}
+}
+
+func TestWithSecretsSourceError(t *testing.T) {
+	_, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs([]string{}),
+		WithSecretsSource(errSource{}),
+	)
+	if err == nil {
+		t.Error("NewConfig() should fail when the secret cannot be read")
+	}
+}
@@ -0,0 +1,137 @@
+package coil
+
+import (
+	"reflect"
+
+	"github.com/spf13/viper"
+)
+
+// Snapshot is an opaque copy of a config's values, taken by
+// Config.Snapshot and written back by Config.RestoreSnapshot
+type Snapshot struct {
+	root     Configer
+	settings map[string]interface{}
+	values   map[string]reflect.Value
+}
+
+// Snapshot captures a deep copy of every config field along with the
+// parser's settings, e.g. to restore a baseline in test teardown
+func (c *Config) Snapshot() Snapshot {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s := Snapshot{
+		root:     c.root,
+		settings: make(map[string]interface{}),
+		values:   make(map[string]reflect.Value),
+	}
+	if c.root == nil {
+		return s
+	}
+	if c.viper != nil {
+		for _, key := range c.viper.AllKeys() {
+			s.settings[key] = copySetting(c.viper.Get(key))
+		}
+	}
+	walkConfig(
+		c.root,
+		func(def fieldDef, _ reflect.StructField, fv reflect.Value) {
+			if fv.CanInterface() {
+				s.values[def.Name] = deepCopy(fv)
+			}
+		},
+	)
+	return s
+}
+
+// RestoreSnapshot writes the values of a snapshot back into the config
+// under its write lock. Parser settings changed since the snapshot, such
+// as by MergeFromMap, are set back to their old values so that a reload
+// does not bring the changes back. Snapshots taken from another config
+// are ignored
+func (c *Config) RestoreSnapshot(s Snapshot) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.root == nil || s.root != c.root {
+		return
+	}
+	if c.viper != nil {
+		restoreSettings(c.viper, s.settings)
+	}
+	walkConfig(
+		c.root,
+		func(def fieldDef, _ reflect.StructField, fv reflect.Value) {
+			if val, ok := s.values[def.Name]; ok {
+				fv.Set(deepCopy(val))
+			}
+		},
+	)
+}
+
+// restoreSettings sets the parser's keys back to the snapshot's settings.
+// A nil override is ignored by viper, so changed keys first drop their
+// override and are only pinned when the other sources disagree with the
+// snapshot
+func restoreSettings(v *viper.Viper, settings map[string]interface{}) {
+	for _, key := range v.AllKeys() {
+		if _, ok := settings[key]; !ok {
+			v.Set(key, nil)
+		}
+	}
+	for key, val := range settings {
+		if reflect.DeepEqual(v.Get(key), val) {
+			continue
+		}
+		v.Set(key, nil)
+		if !reflect.DeepEqual(v.Get(key), val) {
+			v.Set(key, copySetting(val))
+		}
+	}
+}
+
+// copySetting copies a parser setting so the snapshot and the parser do
+// not share slices or maps
+func copySetting(val interface{}) interface{} {
+	if val == nil {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(val)).Interface()
+}
+
+// deepCopy copies a field value so later changes to slices, maps or
+// pointers do not leak into the copy
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(out, v)
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return out
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(v.Elem())
+		return out
+	default:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		return out
+	}
+}
@@ -0,0 +1,85 @@
+package coil
+
+import (
+	"reflect"
+	"testing"
+)
+
+// SnapshotCfg for snapshot testing
+type SnapshotCfg struct {
+	Config
+	Host   string            `type:"string"   name:"snap_host"   default:"localhost" desc:"Host"`
+	Tags   []string          `type:"[]string" name:"snap_tags"   default:"a,b"       desc:"Tags"`
+	Labels map[string]string `type:"map"      name:"snap_labels" default:"tier=web"  desc:"Labels"`
+	Count  *int              `type:"int"      name:"snap_count"                      desc:"Count"`
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	c, err := NewConfigFromMap(
+		map[string]interface{}{"snap_count": 3},
+		&SnapshotCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	cfg := c.(*SnapshotCfg)
+	snap := c.Snapshot()
+
+	cfg.Host = "changed"
+	cfg.Tags[0] = "mutated"
+	cfg.Labels["tier"] = "db"
+	*cfg.Count = 99
+
+	c.RestoreSnapshot(snap)
+	if cfg.Host != "localhost" {
+		t.Errorf("Host = %q, want %q", cfg.Host, "localhost")
+	}
+	if !reflect.DeepEqual(cfg.Tags, []string{"a", "b"}) {
+		t.Errorf("Tags = %v, want [a b]", cfg.Tags)
+	}
+	if cfg.Labels["tier"] != "web" {
+		t.Errorf("Labels = %v, want tier=web", cfg.Labels)
+	}
+	if cfg.Count == nil || *cfg.Count != 3 {
+		t.Errorf("Count = %v, want 3", cfg.Count)
+	}
+
+	// Restoring again must not share state with the previous restore
+	cfg.Tags[1] = "again"
+	c.RestoreSnapshot(snap)
+	if cfg.Tags[1] != "b" {
+		t.Errorf("Tags[1] = %q, want %q", cfg.Tags[1], "b")
+	}
+}
+
+func TestRestoreSnapshotFromOtherConfig(t *testing.T) {
+	a, _ := NewConfigFromMap(nil, &SnapshotCfg{})
+	b, _ := NewConfigFromMap(
+		map[string]interface{}{"snap_host": "other"},
+		&SnapshotCfg{},
+	)
+	a.RestoreSnapshot(b.Snapshot())
+	if got := a.(*SnapshotCfg).Host; got != "localhost" {
+		t.Errorf("Host = %q, want %q", got, "localhost")
+	}
+}
+
+func TestRestoreSnapshotParser(t *testing.T) {
+	c, err := NewConfigFromMap(nil, &SnapshotCfg{})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	snap := c.Snapshot()
+	merged := map[string]interface{}{"snap_host": "merged"}
+	if err := c.base().MergeFromMap(merged); err != nil {
+		t.Fatalf("MergeFromMap() error = %v", err)
+	}
+
+	c.RestoreSnapshot(snap)
+	if err := c.base().Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := c.(*SnapshotCfg).Host; got != "localhost" {
+		t.Errorf("Host after reload = %q, want %q", got, "localhost")
+	}
+}
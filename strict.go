@@ -0,0 +1,49 @@
+package coil
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// unknownEnvVars reports each set env var within the env prefix that no
+// key reads, e.g. a misspelt APP_DBHSOT, as ErrUnknownKey. Without an env
+// prefix there is no way to tell the config's variables apart from the
+// rest of the environment, so nothing is reported
+func unknownEnvVars(c Configer, o *options) ValidationErrors {
+	if o.envPrefix == "" {
+		return nil
+	}
+	known := map[string]bool{}
+	for _, name := range []string{"config", "env_file", "profile"} {
+		known[primaryEnv(fieldDef{Name: name}, o)] = true
+	}
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			known[primaryEnv(def, o)] = true
+			for _, alias := range def.EnvAliases {
+				known[alias] = true
+			}
+		},
+	)
+	prefix := strings.ToUpper(o.envPrefix + "_")
+	if o.envKeyReplacer != nil {
+		prefix = o.envKeyReplacer.Replace(prefix)
+	}
+	var unknown []string
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(strings.ToUpper(name), prefix) && !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	var errs ValidationErrors
+	for _, name := range unknown {
+		errs = append(errs, fmt.Errorf("%w: env var %s", ErrUnknownKey, name))
+	}
+	return errs
+}
@@ -0,0 +1,48 @@
+package coil
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWithStrictMode(t *testing.T) {
+	for key, val := range map[string]string{
+		"STRICT_DBHOST": "db.internal",
+		"STRICT_DBHSOT": "typo.internal",
+	} {
+		origVal := os.Getenv(key)
+		os.Setenv(key, val)
+		t.Cleanup(func() { restoreEnv(key, origVal) })
+	}
+	_, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs([]string{}),
+		WithEnvPrefix("strict"),
+		WithStrictMode(),
+	)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("NewConfig() error = %v, want ErrUnknownKey", err)
+	}
+	if !strings.Contains(err.Error(), "STRICT_DBHSOT") {
+		t.Errorf("NewConfig() error = %v, want it to name STRICT_DBHSOT", err)
+	}
+	if strings.Contains(err.Error(), "STRICT_DBHOST") {
+		t.Errorf("NewConfig() error = %v, reported a known key", err)
+	}
+
+	c, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs([]string{}),
+		WithEnvPrefix("strict"),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() without strict mode error = %v", err)
+	}
+	if got := c.(*RemoteCfg).DBHost; got != "db.internal" {
+		t.Errorf("DBHost = %q, want the env value", got)
+	}
+}
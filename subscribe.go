@@ -0,0 +1,80 @@
+package coil
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// eventBuffer is the capacity of every subscription channel
+const eventBuffer = 64
+
+// ConfigEvent describes a key whose value changed during a reload. Secret
+// values are redacted
+type ConfigEvent struct {
+	Key      string
+	OldValue interface{}
+	NewValue interface{}
+}
+
+// Subscribe returns a channel receiving one event per changed key whenever
+// the config is reloaded. The channel is buffered and never blocks a
+// reload; events that do not fit are dropped and counted by DroppedEvents.
+// It is closed once ctx is done
+func (c *Config) Subscribe(ctx context.Context) <-chan ConfigEvent {
+	ch := make(chan ConfigEvent, eventBuffer)
+	c.mu.Lock()
+	c.subscribers = append(c.subscribers, ch)
+	c.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.subscribers = slices.DeleteFunc(
+			c.subscribers,
+			func(sub chan ConfigEvent) bool { return sub == ch },
+		)
+		close(ch)
+	}()
+	return ch
+}
+
+// DroppedEvents returns how many events were dropped because a subscriber
+// was not keeping up
+func (c *Config) DroppedEvents() int64 {
+	return c.dropped.Load()
+}
+
+// liveValues captures the live values of the config for diffing
+func (c *Config) liveValues() map[string]interface{} {
+	return toMap(c.root, &exportOptions{secrets: true})
+}
+
+// publish sends an event for every key that differs between the snapshots.
+// The caller must hold the write lock
+func (c *Config) publish(old, new map[string]interface{}) {
+	if len(c.subscribers) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(new))
+	for key := range new {
+		if !reflect.DeepEqual(old[key], new[key]) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		event := ConfigEvent{Key: key, OldValue: old[key], NewValue: new[key]}
+		if isSecret(c.root, key) {
+			event.OldValue, event.NewValue = Redacted, Redacted
+		}
+		for _, ch := range c.subscribers {
+			select {
+			case ch <- event:
+			default:
+				c.dropped.Add(1)
+			}
+		}
+	}
+}
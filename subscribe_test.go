@@ -0,0 +1,90 @@
+package coil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// SubscribeCfg for subscription testing
+type SubscribeCfg struct {
+	Config
+	Host  string `type:"string" name:"sub_host"  default:"localhost" desc:"Host"`
+	Port  int    `type:"int"    name:"sub_port"  default:"80"        desc:"Port"`
+	Token string `type:"string" name:"sub_token" default:""          desc:"Token" secret:"true"`
+}
+
+// newSubscribeCfg loads a SubscribeCfg from a config file it can rewrite
+func newSubscribeCfg(t *testing.T) (*SubscribeCfg, func(string)) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("sub_host: a.example.com\nsub_token: one\n")
+	origArgs := os.Args
+	t.Cleanup(func() { os.Args = origArgs })
+	os.Args = []string{"app", "--config=" + path}
+	return MustNewConfig(&SubscribeCfg{}, WithMerge(false)).(*SubscribeCfg),
+		write
+}
+
+func TestSubscribe(t *testing.T) {
+	cfg, write := newSubscribeCfg(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	events := cfg.Subscribe(ctx)
+
+	write("sub_host: b.example.com\nsub_token: two\n")
+	if err := cfg.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	want := []ConfigEvent{
+		{"sub_host", "a.example.com", "b.example.com"},
+		{"sub_token", Redacted, Redacted},
+	}
+	for _, w := range want {
+		select {
+		case got := <-events:
+			if got != w {
+				t.Errorf("event = %+v, want %+v", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s event", w.Key)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("unexpected event after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Error("channel not closed after cancel")
+	}
+}
+
+func TestSubscribeDropsWhenFull(t *testing.T) {
+	cfg, write := newSubscribeCfg(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg.Subscribe(ctx)
+
+	// Each reload flips the host, so the unread buffer overflows
+	for i := 0; i < eventBuffer+10; i++ {
+		if i%2 == 0 {
+			write("sub_host: b.example.com\nsub_token: one\n")
+		} else {
+			write("sub_host: a.example.com\nsub_token: one\n")
+		}
+		if err := cfg.Reload(); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+	}
+	if got := cfg.DroppedEvents(); got != 10 {
+		t.Errorf("DroppedEvents() = %d, want 10", got)
+	}
+}
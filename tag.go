@@ -0,0 +1,145 @@
+package coil
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// tagKeys lists the keys understood in the coil tag, each of which can also
+// be given as a struct tag of its own
+var tagKeys = []string{
+	"name",
+	"type",
+	"default",
+	"desc",
+	"env",
+	"envaliases",
+	"prefix",
+	"required",
+	"secret",
+	"sep",
+	"layout",
+	"deprecated",
+	"newname",
+	"profile_default",
+	"oneof",
+	"min",
+	"max",
+	"secure",
+	"defaultfn",
+	"skip",
+	"encoding",
+	"minlen",
+	"maxlen",
+}
+
+// fieldTags returns the tag values of a field. A malformed coil tag is a
+// programming error and panics
+func fieldTags(field reflect.StructField) map[string]string {
+	tags, err := ParseTags(field.Tag)
+	if err != nil {
+		panic(fmt.Sprintf("coil: invalid tag on field %s: %v", field.Name, err))
+	}
+	return tags
+}
+
+// ParseTags returns the coil values of a struct tag, keyed by tag name.
+// Entries in the unified coil tag, e.g.
+// coil:"name=dbhost,type=string,default=localhost", take precedence over
+// the individual tags. A field excluded with skip:"true", coil:"-",
+// coil:"ignore" or name:"-" yields only skip=true
+func ParseTags(tag reflect.StructTag) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, key := range tagKeys {
+		if val, ok := tag.Lookup(key); ok {
+			tags[key] = val
+		}
+	}
+	if raw, ok := tag.Lookup("coil"); ok {
+		if raw == "-" || raw == "ignore" {
+			raw = "skip"
+		}
+		coilTags, err := parseCoilTag(raw)
+		if err != nil {
+			return nil, err
+		}
+		for key, val := range coilTags {
+			tags[key] = val
+		}
+	}
+	if tags["skip"] == "true" || tags["name"] == "-" {
+		return map[string]string{"skip": "true"}, nil
+	}
+	return tags, nil
+}
+
+// parseCoilTag splits a coil tag into its comma separated key=value
+// entries. Values containing commas can be wrapped in single or double
+// quotes, within which a backslash escapes the next character. A key
+// without a value, e.g. required, is set to true
+func parseCoilTag(tag string) (map[string]string, error) {
+	entries := make(map[string]string)
+	for rest := tag; rest != ""; {
+		var key, val string
+		end := strings.IndexAny(rest, "=,")
+		if end < 0 || rest[end] == ',' {
+			if end < 0 {
+				end = len(rest)
+			}
+			key, val = rest[:end], "true"
+			rest = strings.TrimPrefix(rest[end:], ",")
+		} else {
+			key = rest[:end]
+			var err error
+			val, rest, err = cutTagValue(rest[end+1:])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", strings.TrimSpace(key), err)
+			}
+		}
+		key = strings.TrimSpace(key)
+		if !isTagKey(key) {
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+		entries[key] = val
+	}
+	return entries, nil
+}
+
+// cutTagValue reads a possibly quoted value from the start of s, returning
+// it along with the remainder after the following comma
+func cutTagValue(s string) (val, rest string, err error) {
+	if s == "" || (s[0] != '\'' && s[0] != '"') {
+		val, rest, _ = strings.Cut(s, ",")
+		return val, rest, nil
+	}
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case c == quote:
+			rest = s[i+1:]
+			if rest != "" && rest[0] != ',' {
+				return "", "", errors.New("unexpected text after quoted value")
+			}
+			return b.String(), strings.TrimPrefix(rest, ","), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", errors.New("unterminated quote")
+}
+
+// isTagKey reports whether key is understood in the coil tag
+func isTagKey(key string) bool {
+	for _, k := range tagKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package coil
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// CoilTagCfg for unified tag testing
+type CoilTagCfg struct {
+	Config
+	Host    string   `coil:"name=tag_host,type=string,default=localhost,desc=Hostname"`
+	Hosts   []string `coil:"name=tag_hosts,type=[]string,default='a,b',desc='Hosts, comma separated'"`
+	Port    int      `coil:"name=tag_port,type=int,default=8080" name:"legacy_port" desc:"Port"`
+	Token   string   `coil:"name=tag_token,type=string,required,secret"`
+	Enabled bool     `type:"bool" name:"tag_enabled" default:"true" desc:"Legacy tags"`
+}
+
+func TestParseCoilTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want map[string]string
+	}{
+		{
+			"name=dbhost,type=string,default=localhost",
+			map[string]string{
+				"name":    "dbhost",
+				"type":    "string",
+				"default": "localhost",
+			},
+		},
+		{
+			`default='a,b',desc="say \"hi\", twice"`,
+			map[string]string{"default": "a,b", "desc": `say "hi", twice`},
+		},
+		{"required,name=x", map[string]string{"required": "true", "name": "x"}},
+		{"default=", map[string]string{"default": ""}},
+	}
+	for _, tt := range tests {
+		got, err := parseCoilTag(tt.tag)
+		if err != nil {
+			t.Errorf("parseCoilTag(%q) error = %v", tt.tag, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCoilTag(%q) = %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"nmae=x", "default='open", "default='a'b"} {
+		if _, err := parseCoilTag(bad); err == nil {
+			t.Errorf("parseCoilTag(%q) should return an error", bad)
+		}
+	}
+}
+
+func TestCoilTagFields(t *testing.T) {
+	c, err := NewConfigFromMap(
+		map[string]interface{}{"tag_token": "abc"},
+		&CoilTagCfg{},
+	)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() error = %v", err)
+	}
+	cfg := c.(*CoilTagCfg)
+	if cfg.Host != "localhost" {
+		t.Errorf("Host = %q, want %q", cfg.Host, "localhost")
+	}
+	if !reflect.DeepEqual(cfg.Hosts, []string{"a", "b"}) {
+		t.Errorf("Hosts = %v, want [a b]", cfg.Hosts)
+	}
+	// The coil tag wins over the legacy name tag
+	if cfg.Port != 8080 {
+		t.Errorf("Port = %d, want %d", cfg.Port, 8080)
+	}
+	if !cfg.Enabled {
+		t.Error("Enabled should default to true from legacy tags")
+	}
+	if ToMap(cfg)["tag_token"] != Redacted {
+		t.Error("tag_token should be redacted")
+	}
+	for _, key := range Keys(cfg) {
+		if key.Name == "legacy_port" {
+			t.Error("legacy_port registered despite coil tag")
+		}
+		if key.Name == "tag_token" && !key.Required {
+			t.Error("tag_token should be required")
+		}
+	}
+}
+
+func TestRequiredKeyMissing(t *testing.T) {
+	_, err := NewConfigFromMap(nil, &CoilTagCfg{})
+	if !errors.Is(err, ErrRequired) {
+		t.Errorf("NewConfigFromMap() error = %v, want ErrRequired", err)
+	}
+}
+
+// pluginSettings belongs to another package whose tags happen to use coil
+// key names for other purposes
+type pluginSettings struct {
+	Kind string `name:"kind" type:"plugin"`
+}
+
+// SkipCfg for skipped field testing
+type SkipCfg struct {
+	Config
+	Host    string         `type:"string" name:"skip_host" default:"localhost"`
+	Ignored string         `type:"string" name:"skip_ignored" default:"x" skip:"true"`
+	Dashed  string         `type:"string" name:"-"`
+	Plugin  pluginSettings `coil:"-"`
+	Backup  pluginSettings `coil:"ignore"`
+}
+
+func TestSkipTag(t *testing.T) {
+	c, err := NewConfig(&SkipCfg{}, WithMerge(false), WithArgs([]string{}))
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg := c.(*SkipCfg)
+	keys := Keys(cfg)
+	if len(keys) != 1 || keys[0].Name != "skip_host" {
+		t.Errorf("Keys() = %v, want only skip_host", keys)
+	}
+	if cfg.Ignored != "" {
+		t.Errorf("Ignored = %q, want it left alone", cfg.Ignored)
+	}
+	if cfg.flags.Lookup("skip_ignored") != nil {
+		t.Error("skip_ignored registered as a flag")
+	}
+
+	tags, err := ParseTags(`coil:"name=skip_host,skip"`)
+	if err != nil {
+		t.Fatalf("ParseTags() error = %v", err)
+	}
+	if want := map[string]string{"skip": "true"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("ParseTags() = %v, want %v", tags, want)
+	}
+}
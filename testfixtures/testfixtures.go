@@ -0,0 +1,137 @@
+// Package testfixtures provides pre-populated instances of the composable
+// coil configs for tests. Each fixture starts from the config's defaults,
+// overridden with values suited to tests, and is populated from a parser
+// of its own: fixtures never read the environment, os.Args or
+// pflag.CommandLine, and each call returns a new instance, so they are
+// safe to use in parallel tests
+package testfixtures
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/cvlstack/coil"
+)
+
+// fixture wraps a composable config so it can be populated by coil
+type fixture[T any] struct {
+	coil.Config
+	Values T
+}
+
+// newFixture returns a new T populated from its defaults and the values
+// keyed by flag name. The values are fixed, so an error is a bug in the
+// fixture and panics
+func newFixture[T any](values map[string]interface{}) *T {
+	f := &fixture[T]{}
+	if _, err := coil.NewConfigFromMap(values, f); err != nil {
+		panic(fmt.Sprintf("testfixtures: %T: %v", f.Values, err))
+	}
+	return &f.Values
+}
+
+// TestAPIServiceConfig returns an API service config listening on
+// localhost:8080
+func TestAPIServiceConfig() *coil.APIServiceConfig {
+	return newFixture[coil.APIServiceConfig](map[string]interface{}{
+		"name":    "test-api",
+		"build":   "test",
+		"host":    "localhost",
+		"port":    8080,
+		"timeout": "5s",
+	})
+}
+
+// TestDatabaseConfig returns a database config for an in-memory SQLite
+// database, whose DSN is :memory:
+func TestDatabaseConfig() *coil.DatabaseConfig {
+	return newFixture[coil.DatabaseConfig](map[string]interface{}{
+		"dbdriver": "sqlite",
+		"dbname":   ":memory:",
+		"dbuser":   "test",
+		"dbpass":   "test",
+	})
+}
+
+// TestLogConfig returns a log config writing text at debug level to the
+// returned buffer
+func TestLogConfig() (*coil.LogConfig, *bytes.Buffer) {
+	c := newFixture[coil.LogConfig](map[string]interface{}{
+		"log_level":        "debug",
+		"log_format":       "text",
+		"log_service_name": "test",
+		"log_environment":  "test",
+	})
+	var buf bytes.Buffer
+	*c = c.WithWriter(&buf)
+	return c, &buf
+}
+
+// TestRedisConfig returns a Redis config for localhost:6379 with short
+// timeouts and no retries
+func TestRedisConfig() *coil.RedisConfig {
+	return newFixture[coil.RedisConfig](map[string]interface{}{
+		"redis_max_retries":  0,
+		"redis_pool_size":    1,
+		"redis_dial_timeout": "100ms",
+	})
+}
+
+// TestCacheConfig returns an in-memory cache config holding up to 100
+// entries for a minute
+func TestCacheConfig() *coil.CacheConfig {
+	return newFixture[coil.CacheConfig](map[string]interface{}{
+		"cache_backend":     "memory",
+		"cache_ttl":         "1m",
+		"cache_max_entries": 100,
+	})
+}
+
+// TestGRPCConfig returns a gRPC client config for localhost:50051 without
+// TLS and with short reconnect delays
+func TestGRPCConfig() *coil.GRPCConfig {
+	return newFixture[coil.GRPCConfig](map[string]interface{}{
+		"grpc_tls":                 false,
+		"grpc_backoff_base_delay":  "10ms",
+		"grpc_backoff_max_delay":   "100ms",
+		"grpc_min_connect_timeout": "1s",
+	})
+}
+
+// TestMetricsConfig returns a metrics config listening on a random port
+// of 127.0.0.1
+func TestMetricsConfig() *coil.MetricsConfig {
+	return newFixture[coil.MetricsConfig](map[string]interface{}{
+		"metrics_host":      "127.0.0.1",
+		"metrics_port":      0,
+		"metrics_namespace": "test",
+	})
+}
+
+// TestRateLimitConfig returns a disabled rate limit config, which allows
+// every request
+func TestRateLimitConfig() *coil.RateLimitConfig {
+	return newFixture[coil.RateLimitConfig](map[string]interface{}{
+		"ratelimit_enabled": false,
+	})
+}
+
+// TestTLSConfig returns a TLS config without certificates that does not
+// verify peers, for test servers with self-signed certificates
+func TestTLSConfig() *coil.TLSConfig {
+	return newFixture[coil.TLSConfig](map[string]interface{}{
+		"tls_verify_peer": false,
+	})
+}
+
+// TestOAuthConfig returns an OAuth2 client config whose endpoints are on
+// localhost
+func TestOAuthConfig() *coil.OAuthConfig {
+	return newFixture[coil.OAuthConfig](map[string]interface{}{
+		"oauth_client_id":     "test-client",
+		"oauth_client_secret": "test-secret",
+		"oauth_token_url":     "http://localhost/oauth/token",
+		"oauth_auth_url":      "http://localhost/oauth/authorize",
+		"oauth_redirect_url":  "http://localhost/oauth/callback",
+	})
+}
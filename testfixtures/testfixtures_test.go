@@ -0,0 +1,74 @@
+package testfixtures
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestFixtures(t *testing.T) {
+	t.Run("api", func(t *testing.T) {
+		t.Parallel()
+		c := TestAPIServiceConfig()
+		if got := c.ListenAddr(); got != "localhost:8080" {
+			t.Errorf("ListenAddr() = %q, want localhost:8080", got)
+		}
+		if c.Version != "1.0.0" {
+			t.Errorf("Version = %q, want the default 1.0.0", c.Version)
+		}
+	})
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		c := TestDatabaseConfig()
+		if got := c.DSN(); got != ":memory:" {
+			t.Errorf("DSN() = %q, want :memory:", got)
+		}
+	})
+	t.Run("log", func(t *testing.T) {
+		t.Parallel()
+		c, buf := TestLogConfig()
+		slog.New(c.SlogHandler()).Debug("hello", "k", "v")
+		got := buf.String()
+		if !strings.Contains(got, "level=DEBUG msg=hello") ||
+			!strings.Contains(got, "service=test k=v") {
+			t.Errorf("log output = %q, want a text debug record", got)
+		}
+	})
+	t.Run("others", func(t *testing.T) {
+		t.Parallel()
+		if c := TestCacheConfig(); c.IsRedis() || c.MaxEntries != 100 {
+			t.Errorf("TestCacheConfig() = %+v", c)
+		}
+		if c := TestGRPCConfig(); c.Target() != "localhost:50051" {
+			t.Errorf("Target() = %q, want localhost:50051", c.Target())
+		}
+		if c := TestMetricsConfig(); c.ListenAddr() != "127.0.0.1:0" {
+			t.Errorf("ListenAddr() = %q, want 127.0.0.1:0", c.ListenAddr())
+		}
+		if c := TestRateLimitConfig(); !c.Limiter().Allow() {
+			t.Error("Limiter() should allow every request")
+		}
+		if c := TestRedisConfig(); c.Options().Addr != "localhost:6379" {
+			t.Errorf("Options().Addr = %q", c.Options().Addr)
+		}
+		if _, err := TestTLSConfig().Build(); err != nil {
+			t.Errorf("Build() error = %v", err)
+		}
+		if _, err := TestOAuthConfig().OAuth2Config(); err != nil {
+			t.Errorf("OAuth2Config() error = %v", err)
+		}
+	})
+}
+
+func TestFixturesIndependent(t *testing.T) {
+	a, b := TestDatabaseConfig(), TestDatabaseConfig()
+	a.DBName = "changed"
+	if b.DBName != ":memory:" {
+		t.Errorf("DBName = %q, fixtures should not share state", b.DBName)
+	}
+	if pflag.CommandLine.Lookup("dbname") != nil {
+		t.Error("fixtures should not register flags on pflag.CommandLine")
+	}
+}
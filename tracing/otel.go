@@ -0,0 +1,206 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/cvlstack/coil"
+)
+
+// Protocols accepted by otlp_protocol
+const (
+	ProtocolGRPC         = "grpc"
+	ProtocolHTTPProtobuf = "http/protobuf"
+	ProtocolHTTPJSON     = "http/json"
+)
+
+// OTelConfig represents a composable struct exporting traces, metrics and
+// logs to a single OTLP collector. The sampler, propagators and service
+// name come from the embedded TracingConfig, whose endpoint and insecure
+// settings are replaced by the OTLP ones, and MetricsEnabled from the
+// embedded MetricsConfig switches metric export
+type OTelConfig struct {
+	TracingConfig
+	coil.MetricsConfig
+
+	OTLPEndpoint string            `type:"string" name:"otlp_endpoint"     default:"localhost:4317" desc:"OTLP collector endpoint for traces, metrics and logs"`
+	OTLPHeaders  map[string]string `type:"map"    name:"otlp_headers"      default:""               desc:"Headers sent with every export, e.g. api-key=secret" secret:"true"`
+	OTLPProtocol string            `type:"string" name:"otlp_protocol"     default:"grpc"           desc:"OTLP protocol (grpc, http/protobuf, http/json)"       oneof:"grpc http/protobuf http/json"`
+	Insecure     bool              `type:"bool"   name:"otlp_insecure"     default:"false"          desc:"Connect to the collector without TLS"`
+	LogsEnabled  bool              `type:"bool"   name:"otlp_logs_enabled" default:"true"           desc:"Export logs to the collector"`
+}
+
+// Setup exports every enabled signal to the collector and installs the
+// tracer, meter and logger providers and the propagators globally. Logs
+// reach the collector through an OpenTelemetry log bridge, such as
+// otelslog, using the global logger provider. The returned function
+// flushes and stops every provider and should be deferred by the caller.
+// The http/json protocol is accepted by the tag but not implemented by the
+// OpenTelemetry Go exporters, so Setup reports it as an error
+func (c *OTelConfig) Setup(ctx context.Context) (func(), error) {
+	if c.OTLPProtocol == ProtocolHTTPJSON {
+		return nil, fmt.Errorf(
+			"otlp_protocol %q is not supported by the Go exporters",
+			c.OTLPProtocol,
+		)
+	}
+	propagator, err := c.propagator()
+	if err != nil {
+		return nil, err
+	}
+	sampler, err := c.sampler()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.resource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var stops []func(context.Context) error
+	shutdown := func() {
+		ctx, cancel := context.WithTimeout(
+			context.Background(),
+			shutdownTimeout,
+		)
+		defer cancel()
+		var errs []error
+		for i := len(stops) - 1; i >= 0; i-- {
+			errs = append(errs, stops[i](ctx))
+		}
+		if err := errors.Join(errs...); err != nil {
+			fmt.Fprintf(os.Stderr, "coil: telemetry shutdown: %v\n", err)
+		}
+	}
+
+	spans, err := c.spanExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tp := trace.NewTracerProvider(
+		trace.WithBatcher(spans),
+		trace.WithResource(res),
+		trace.WithSampler(sampler),
+	)
+	stops = append(stops, tp.Shutdown)
+
+	if c.MetricsEnabled {
+		metrics, err := c.metricExporter(ctx)
+		if err != nil {
+			shutdown()
+			return nil, err
+		}
+		mp := metric.NewMeterProvider(
+			metric.WithReader(metric.NewPeriodicReader(metrics)),
+			metric.WithResource(res),
+		)
+		stops = append(stops, mp.Shutdown)
+		otel.SetMeterProvider(mp)
+	}
+
+	if c.LogsEnabled {
+		logs, err := c.logExporter(ctx)
+		if err != nil {
+			shutdown()
+			return nil, err
+		}
+		lp := sdklog.NewLoggerProvider(
+			sdklog.WithProcessor(sdklog.NewBatchProcessor(logs)),
+			sdklog.WithResource(res),
+		)
+		stops = append(stops, lp.Shutdown)
+		global.SetLoggerProvider(lp)
+	}
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagator)
+	return shutdown, nil
+}
+
+// spanExporter returns the stdout exporter or the OTLP exporter for the
+// protocol
+func (c *OTelConfig) spanExporter(
+	ctx context.Context,
+) (trace.SpanExporter, error) {
+	if c.TracingStdout {
+		return c.TracingConfig.exporter(ctx)
+	}
+	if c.OTLPProtocol == ProtocolHTTPProtobuf {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(c.OTLPEndpoint),
+			otlptracehttp.WithHeaders(c.OTLPHeaders),
+		}
+		if c.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(c.OTLPEndpoint),
+		otlptracegrpc.WithHeaders(c.OTLPHeaders),
+	}
+	if c.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+// metricExporter returns the OTLP metric exporter for the protocol
+func (c *OTelConfig) metricExporter(
+	ctx context.Context,
+) (metric.Exporter, error) {
+	if c.OTLPProtocol == ProtocolHTTPProtobuf {
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(c.OTLPEndpoint),
+			otlpmetrichttp.WithHeaders(c.OTLPHeaders),
+		}
+		if c.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(c.OTLPEndpoint),
+		otlpmetricgrpc.WithHeaders(c.OTLPHeaders),
+	}
+	if c.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
+}
+
+// logExporter returns the OTLP log exporter for the protocol
+func (c *OTelConfig) logExporter(ctx context.Context) (sdklog.Exporter, error) {
+	if c.OTLPProtocol == ProtocolHTTPProtobuf {
+		opts := []otlploghttp.Option{
+			otlploghttp.WithEndpoint(c.OTLPEndpoint),
+			otlploghttp.WithHeaders(c.OTLPHeaders),
+		}
+		if c.Insecure {
+			opts = append(opts, otlploghttp.WithInsecure())
+		}
+		return otlploghttp.New(ctx, opts...)
+	}
+	opts := []otlploggrpc.Option{
+		otlploggrpc.WithEndpoint(c.OTLPEndpoint),
+		otlploggrpc.WithHeaders(c.OTLPHeaders),
+	}
+	if c.Insecure {
+		opts = append(opts, otlploggrpc.WithInsecure())
+	}
+	return otlploggrpc.New(ctx, opts...)
+}
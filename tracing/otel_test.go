@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+
+	"github.com/cvlstack/coil"
+)
+
+// OTelCfg for tag testing
+type OTelCfg struct {
+	coil.Config
+	OTel OTelConfig
+}
+
+func TestOTelConfigDefaults(t *testing.T) {
+	cfg, err := coil.ParseArgs(&OTelCfg{}, []string{
+		"--otlp_headers=api-key=secret",
+		"--otlp_protocol=http/protobuf",
+	})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	o := &cfg.(*OTelCfg).OTel
+	if o.OTLPEndpoint != "localhost:4317" {
+		t.Errorf("OTLPEndpoint = %q", o.OTLPEndpoint)
+	}
+	if o.OTLPProtocol != ProtocolHTTPProtobuf {
+		t.Errorf("OTLPProtocol = %q, want http/protobuf", o.OTLPProtocol)
+	}
+	if o.OTLPHeaders["api-key"] != "secret" {
+		t.Errorf("OTLPHeaders = %v, want api-key=secret", o.OTLPHeaders)
+	}
+	if !o.MetricsEnabled || !o.LogsEnabled || o.TracingSampler != SamplerAlways {
+		t.Errorf("OTelConfig = %+v, want metrics, logs and always sampler", o)
+	}
+
+	args := []string{"--otlp_protocol=udp"}
+	if _, err := coil.ParseArgs(&OTelCfg{}, args); err == nil {
+		t.Errorf("ParseArgs(%q) should return an error", args)
+	}
+}
+
+func TestOTelConfigSetup(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths = map[string]string{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			paths[r.URL.Path] = r.Header.Get("Api-Key")
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/x-protobuf")
+		},
+	))
+	defer srv.Close()
+
+	cfg := OTelConfig{
+		TracingConfig: TracingConfig{
+			TracingServiceName: "billing",
+			TracingSampler:     SamplerAlways,
+			TracingPropagators: []string{"tracecontext"},
+		},
+		MetricsConfig: coil.MetricsConfig{MetricsEnabled: true},
+		OTLPEndpoint:  strings.TrimPrefix(srv.URL, "http://"),
+		OTLPHeaders:   map[string]string{"api-key": "secret"},
+		OTLPProtocol:  ProtocolHTTPProtobuf,
+		Insecure:      true,
+		LogsEnabled:   true,
+	}
+	shutdown, err := cfg.Setup(context.Background())
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	ctx := context.Background()
+	_, span := otel.Tracer("test").Start(ctx, "op")
+	span.End()
+	counter, err := otel.Meter("test").Int64Counter("requests")
+	if err != nil {
+		t.Fatalf("Int64Counter() error = %v", err)
+	}
+	counter.Add(ctx, 1)
+	var record log.Record
+	record.SetBody(attribute.StringValue("hello"))
+	global.GetLoggerProvider().Logger("test").Emit(ctx, record)
+	shutdown()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/v1/traces", "/v1/metrics", "/v1/logs"} {
+		key, ok := paths[path]
+		if !ok {
+			t.Errorf("nothing exported to %s", path)
+		} else if key != "secret" {
+			t.Errorf("%s api-key header = %q, want secret", path, key)
+		}
+	}
+}
+
+func TestOTelConfigSetupErrors(t *testing.T) {
+	cfg := OTelConfig{OTLPProtocol: ProtocolHTTPJSON}
+	if _, err := cfg.Setup(context.Background()); err == nil {
+		t.Error("Setup() with http/json should return an error")
+	}
+	cfg = OTelConfig{
+		TracingConfig: TracingConfig{TracingPropagators: []string{"b3"}},
+		OTLPProtocol:  ProtocolGRPC,
+	}
+	if _, err := cfg.Setup(context.Background()); err == nil {
+		t.Error("Setup() with an unknown propagator should return an error")
+	}
+}
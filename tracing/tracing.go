@@ -0,0 +1,158 @@
+// Package tracing provides composable coil configs for OpenTelemetry
+// tracing, and for traces, metrics and logs together
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Samplers accepted by tracing_sampler
+const (
+	SamplerAlways = "always"
+	SamplerNever  = "never"
+	SamplerRatio  = "ratio"
+)
+
+// shutdownTimeout bounds how long the shutdown function waits for spans to
+// be flushed
+var shutdownTimeout = 5 * time.Second
+
+// TracingConfig represents a composable struct for OTLP trace export
+type TracingConfig struct {
+	TracingEndpoint    string   `type:"string"   name:"tracing_endpoint"     default:"localhost:4317"      desc:"OTLP gRPC collector endpoint"`
+	TracingInsecure    bool     `type:"bool"     name:"tracing_insecure"     default:"false"               desc:"Connect to the collector without TLS"`
+	TracingServiceName string   `type:"string"   name:"tracing_service_name" default:""                    desc:"Service name recorded on every span"`
+	TracingSampler     string   `type:"string"   name:"tracing_sampler"      default:"always"              desc:"Sampler (always, never, ratio)"                  oneof:"always never ratio"`
+	TracingSampleRatio float64  `type:"float64"  name:"tracing_sample_ratio" default:"1"                   desc:"Fraction of traces sampled by the ratio sampler"`
+	TracingPropagators []string `type:"[]string" name:"tracing_propagators"  default:"tracecontext,baggage" desc:"Context propagation formats (tracecontext, baggage)"`
+	TracingStdout      bool     `type:"bool"     name:"tracing_stdout"       default:"false"               desc:"Write spans to stdout instead of the collector"`
+}
+
+// Validate checks the sample ratio and propagation formats
+func (c TracingConfig) Validate() error {
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		return fmt.Errorf(
+			"tracing_sample_ratio %v is not between 0 and 1",
+			c.TracingSampleRatio,
+		)
+	}
+	_, err := c.propagator()
+	return err
+}
+
+// NewTracerProvider sets up the exporter, resource, sampler and batch span
+// processor and installs the configured propagators globally. The returned
+// function flushes and stops the provider and should be deferred by the
+// caller. Installing the provider with otel.SetTracerProvider is left to
+// the caller
+func (c TracingConfig) NewTracerProvider(
+	ctx context.Context,
+) (*trace.TracerProvider, func(), error) {
+	propagator, err := c.propagator()
+	if err != nil {
+		return nil, nil, err
+	}
+	sampler, err := c.sampler()
+	if err != nil {
+		return nil, nil, err
+	}
+	exporter, err := c.exporter(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := c.resource(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	tp := trace.NewTracerProvider(
+		trace.WithBatcher(exporter),
+		trace.WithResource(res),
+		trace.WithSampler(sampler),
+	)
+	otel.SetTextMapPropagator(propagator)
+	shutdown := func() {
+		ctx, cancel := context.WithTimeout(
+			context.Background(),
+			shutdownTimeout,
+		)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "coil: tracing shutdown: %v\n", err)
+		}
+	}
+	return tp, shutdown, nil
+}
+
+// exporter returns the stdout exporter or the OTLP gRPC exporter
+func (c TracingConfig) exporter(ctx context.Context) (trace.SpanExporter, error) {
+	if c.TracingStdout {
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	}
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(c.TracingEndpoint),
+	}
+	if c.TracingInsecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+// resource describes the service, including OTEL_RESOURCE_ATTRIBUTES
+func (c TracingConfig) resource(ctx context.Context) (*resource.Resource, error) {
+	opts := []resource.Option{
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	}
+	if c.TracingServiceName != "" {
+		opts = append(opts, resource.WithAttributes(
+			attribute.String("service.name", c.TracingServiceName),
+		))
+	}
+	return resource.New(ctx, opts...)
+}
+
+// sampler returns the configured sampler, which follows the sampling
+// decision of a remote parent
+func (c TracingConfig) sampler() (trace.Sampler, error) {
+	var root trace.Sampler
+	switch strings.ToLower(c.TracingSampler) {
+	case SamplerAlways, "":
+		root = trace.AlwaysSample()
+	case SamplerNever:
+		root = trace.NeverSample()
+	case SamplerRatio:
+		root = trace.TraceIDRatioBased(c.TracingSampleRatio)
+	default:
+		return nil, fmt.Errorf("unsupported sampler %q", c.TracingSampler)
+	}
+	return trace.ParentBased(root), nil
+}
+
+// propagator combines the configured propagation formats
+func (c TracingConfig) propagator() (propagation.TextMapPropagator, error) {
+	var props []propagation.TextMapPropagator
+	for _, name := range c.TracingPropagators {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case "tracecontext":
+			props = append(props, propagation.TraceContext{})
+		case "baggage":
+			props = append(props, propagation.Baggage{})
+		default:
+			return nil, fmt.Errorf("unsupported propagator %q", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(props...), nil
+}
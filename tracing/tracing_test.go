@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+
+	"github.com/cvlstack/coil"
+)
+
+// TracingCfg for tag testing
+type TracingCfg struct {
+	coil.Config
+	Tracing TracingConfig
+}
+
+func TestTracingConfigDefaults(t *testing.T) {
+	cfg, err := coil.ParseArgs(&TracingCfg{}, []string{
+		"--tracing_service_name=billing",
+		"--tracing_sampler=ratio",
+		"--tracing_sample_ratio=0.25",
+	})
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	tracing := cfg.(*TracingCfg).Tracing
+	if tracing.TracingEndpoint != "localhost:4317" {
+		t.Errorf("TracingEndpoint = %q", tracing.TracingEndpoint)
+	}
+	if tracing.TracingSampler != SamplerRatio ||
+		tracing.TracingSampleRatio != 0.25 {
+		t.Errorf(
+			"sampler = %q %v, want ratio 0.25",
+			tracing.TracingSampler,
+			tracing.TracingSampleRatio,
+		)
+	}
+	if len(tracing.TracingPropagators) != 2 {
+		t.Errorf("TracingPropagators = %q", tracing.TracingPropagators)
+	}
+}
+
+func TestTracingConfigValidate(t *testing.T) {
+	for _, args := range [][]string{
+		{"--tracing_sample_ratio=1.5"},
+		{"--tracing_propagators=b3"},
+	} {
+		_, err := coil.ParseArgs(&TracingCfg{}, args)
+		var errs coil.ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("ParseArgs(%q) error = %v, want ValidationErrors", args, err)
+		}
+	}
+
+	// The sampler flag rejects unknown values as it is parsed, while the
+	// env var is caught by validation
+	args := []string{"--tracing_sampler=sometimes"}
+	if _, err := coil.ParseArgs(&TracingCfg{}, args); err == nil {
+		t.Errorf("ParseArgs(%q) should return an error", args)
+	}
+	t.Setenv("TRACING_SAMPLER", "sometimes")
+	_, err := coil.ParseArgs(&TracingCfg{}, nil)
+	var errs coil.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Errorf("ParseArgs() error = %v, want ValidationErrors", err)
+	}
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	cfg := TracingConfig{
+		TracingServiceName: "billing",
+		TracingSampler:     SamplerNever,
+		TracingPropagators: []string{"tracecontext"},
+		TracingStdout:      true,
+	}
+	tp, shutdown, err := cfg.NewTracerProvider(context.Background())
+	if err != nil {
+		t.Fatalf("NewTracerProvider() error = %v", err)
+	}
+	defer shutdown()
+	_, span := tp.Tracer("test").Start(context.Background(), "op")
+	if span.SpanContext().IsSampled() {
+		t.Error("span sampled with the never sampler")
+	}
+	span.End()
+	// The composite propagator returns its fields in no particular order
+	fields := otel.GetTextMapPropagator().Fields()
+	sort.Strings(fields)
+	if len(fields) != 2 || fields[0] != "traceparent" {
+		t.Errorf("propagator fields = %q, want tracecontext", fields)
+	}
+
+	cfg.TracingStdout = false
+	cfg.TracingInsecure = true
+	_, shutdown, err = cfg.NewTracerProvider(context.Background())
+	if err != nil {
+		t.Fatalf("NewTracerProvider() with OTLP error = %v", err)
+	}
+	shutdown()
+}
@@ -0,0 +1,282 @@
+package coil
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// ErrRequired is wrapped by the error reported for a required key that no
+// source supplied
+var ErrRequired = errors.New("required config key not set")
+
+// Validator is implemented by config structs that check their own values
+// once they have been populated
+type Validator interface {
+	Validate() error
+}
+
+// ValidationErrors aggregates every failure reported while validating a
+// config, so all problems can be fixed at once
+type ValidationErrors []error
+
+// Error joins the individual failures
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap exposes the individual failures to errors.Is and errors.As
+func (e ValidationErrors) Unwrap() []error {
+	return e
+}
+
+// Validate calls Validate on the config and on every nested struct that
+// implements Validator, returning ValidationErrors if any of them fail.
+// Like any promoted method, an embedded struct's Validate is not called
+// separately when the struct embedding it defines its own
+func Validate(c Configer) error {
+	var errs ValidationErrors
+	validateValue(reflect.ValueOf(c), false, &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateValue validates a pointer to a struct, unless promoted is set,
+// and recurses into its struct fields
+func validateValue(v reflect.Value, promoted bool, errs *ValidationErrors) {
+	validator, isValidator := v.Interface().(Validator)
+	if isValidator && !promoted {
+		if err := validator.Validate(); err != nil {
+			if nested, ok := err.(ValidationErrors); ok {
+				*errs = append(*errs, nested...)
+			} else {
+				*errs = append(*errs, err)
+			}
+		}
+	}
+	elem := v.Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		field := elem.Type().Field(i)
+		fv := elem.Field(i)
+		if fv.Kind() != reflect.Struct || !field.IsExported() ||
+			!isConfigField(field) {
+			continue
+		}
+		// The parent's Validate already covers, or overrides, the one
+		// promoted from an embedded struct
+		validateValue(fv.Addr(), field.Anonymous && isValidator, errs)
+	}
+}
+
+// missingRequired reports every key tagged required that was not supplied
+// by a flag, env var, config file or other source
+func missingRequired(c Configer) ValidationErrors {
+	var errs ValidationErrors
+	parser := c.getParser()
+	walkConfig(
+		c,
+		func(def fieldDef, _ reflect.StructField, _ reflect.Value) {
+			if def.Required && !parser.IsSet(def.Name) {
+				errs = append(errs, fmt.Errorf("%w: %s", ErrRequired, def.Name))
+			}
+		},
+	)
+	return errs
+}
+
+// invalidValues reports every time, ip, cidr, url, bytes, json, uint8 and
+// octal field whose supplied value does not parse, every oneof field set
+// to a value not listed, every integer field given a value too wide for
+// it, every numeric field outside its min and max and every bytes field
+// outside its minlen and maxlen
+func invalidValues(c Configer) ValidationErrors {
+	var errs ValidationErrors
+	parser := c.getParser()
+	walkConfig(
+		c,
+		func(def fieldDef, field reflect.StructField, fv reflect.Value) {
+			var err error
+			switch field.Type {
+			case timeType:
+				val := fieldValue(parser, def)
+				if _, err = parseTime(val, def.Layout); err != nil {
+					err = fmt.Errorf(
+						"%q does not match layout %q",
+						fmt.Sprint(val),
+						def.Layout,
+					)
+				}
+			case ipType:
+				_, err = parseIP(fieldValue(parser, def))
+			case ipNetType:
+				_, err = parseCIDR(fieldValue(parser, def))
+			case urlType:
+				_, err = parseURL(fieldValue(parser, def), def.Secure)
+			}
+			if def.Type == "bytes" {
+				var b []byte
+				b, err = parseBytes(fieldValue(parser, def), def.Encoding)
+				if err == nil && (def.MinLen != "" || def.MaxLen != "") {
+					err = checkLen(len(b), def)
+				}
+			}
+			if def.Type == "json" {
+				_, err = parseJSON(fieldValue(parser, def), field.Type)
+			}
+			if def.Type == "uint8" || def.Type == "octal" {
+				_, err = parseUnsigned(
+					fieldValue(parser, def),
+					def.Type,
+					uintBits(def.Kind),
+				)
+			}
+			if def.Type == "text" && parser.IsSet(def.Name) {
+				_, err = parseText(field.Type, parser.GetString(def.Name))
+			}
+			if err == nil && parser.IsSet(def.Name) &&
+				def.Type != "uint8" && def.Type != "octal" {
+				err = checkWidth(field.Type, parser.Get(def.Name))
+			}
+			if err == nil && len(def.OneOf) > 0 {
+				err = checkOneOf(fmt.Sprint(fieldValue(parser, def)), def.OneOf)
+			}
+			if err == nil && (def.Min != "" || def.Max != "") {
+				err = checkRange(fv, def)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", def.Name, err))
+			}
+		},
+	)
+	return errs
+}
+
+// checkWidth reports an integer value that does not fit in the bit width
+// of the field's type, which would otherwise be dropped when the field is
+// assigned. Values that are not numbers are left to the field's own parsing
+func checkWidth(t reflect.Type, val interface{}) error {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		n, err := cast.ToInt64E(val)
+		if err == nil && reflect.Zero(t).OverflowInt(n) {
+			return fmt.Errorf("%d does not fit in %d bits", n, t.Bits())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		n, err := cast.ToUint64E(val)
+		if err == nil && reflect.Zero(t).OverflowUint(n) {
+			return fmt.Errorf("%d does not fit in %d bits", n, t.Bits())
+		}
+	}
+	return nil
+}
+
+// checkOneOf reports whether val, unless empty, is one of the allowed values
+func checkOneOf(val string, allowed []string) error {
+	if val == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if val == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not one of %s", val, strings.Join(allowed, ", "))
+}
+
+// checkRange reports whether the populated value of an int, uint, float
+// or duration field lies within its min and max tags. Unset pointer
+// fields are not checked
+func checkRange(fv reflect.Value, def fieldDef) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	parseInt := func(s string) (int64, error) {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+			d := time.Duration(fv.Int())
+			return checkBounds(d, def, ParseDurationLenient)
+		}
+		return checkBounds(fv.Int(), def, parseInt)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return checkBounds(int64(fv.Uint()), def, parseInt)
+	case reflect.Float32, reflect.Float64:
+		return checkBounds(fv.Float(), def, func(s string) (float64, error) {
+			return strconv.ParseFloat(s, 64)
+		})
+	}
+	return nil
+}
+
+// checkLen reports whether the decoded length n of a bytes field lies
+// within its minlen and maxlen tags
+func checkLen(n int, def fieldDef) error {
+	if def.MinLen != "" {
+		min, err := strconv.Atoi(def.MinLen)
+		if err != nil {
+			return fmt.Errorf("invalid minlen tag %q: %w", def.MinLen, err)
+		}
+		if n < min {
+			return fmt.Errorf("%d bytes is below the minimum of %d", n, min)
+		}
+	}
+	if def.MaxLen != "" {
+		max, err := strconv.Atoi(def.MaxLen)
+		if err != nil {
+			return fmt.Errorf("invalid maxlen tag %q: %w", def.MaxLen, err)
+		}
+		if n > max {
+			return fmt.Errorf("%d bytes is above the maximum of %d", n, max)
+		}
+	}
+	return nil
+}
+
+// checkBounds compares val with the min and max tags, parsed by parse
+func checkBounds[T cmp.Ordered](
+	val T,
+	def fieldDef,
+	parse func(string) (T, error),
+) error {
+	if def.Min != "" {
+		min, err := parse(def.Min)
+		if err != nil {
+			return fmt.Errorf("invalid min tag %q: %w", def.Min, err)
+		}
+		if val < min {
+			return fmt.Errorf("%v is below the minimum of %s", val, def.Min)
+		}
+	}
+	if def.Max != "" {
+		max, err := parse(def.Max)
+		if err != nil {
+			return fmt.Errorf("invalid max tag %q: %w", def.Max, err)
+		}
+		if val > max {
+			return fmt.Errorf("%v is above the maximum of %s", val, def.Max)
+		}
+	}
+	return nil
+}
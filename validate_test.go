@@ -0,0 +1,176 @@
+package coil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// PortRange fails validation outside the TCP port range
+type PortRange struct {
+	Port int `type:"int" name:"port" default:"8080" desc:"Port"`
+}
+
+func (p PortRange) Validate() error {
+	if p.Port < 1 || p.Port > 65535 {
+		return fmt.Errorf("port %d out of range", p.Port)
+	}
+	return nil
+}
+
+// LevelCheck fails validation for unknown log levels
+type LevelCheck struct {
+	Level string `type:"string" name:"level" default:"info" desc:"Level"`
+}
+
+func (l *LevelCheck) Validate() error {
+	switch l.Level {
+	case "trace", "debug", "info", "warn", "error", "fatal":
+		return nil
+	}
+	return fmt.Errorf("unknown level %q", l.Level)
+}
+
+// ValidatedCfg for validation testing
+type ValidatedCfg struct {
+	Config
+	Primary   PortRange  `prefix:"val_primary"`
+	Secondary PortRange  `prefix:"val_secondary"`
+	Log       LevelCheck `prefix:"val"`
+}
+
+func TestValidateAggregatesErrors(t *testing.T) {
+	keys := []string{"VAL_PRIMARY_PORT", "VAL_SECONDARY_PORT", "VAL_LEVEL"}
+	origVals := make(map[string]string)
+	for _, key := range keys {
+		origVals[key] = os.Getenv(key)
+	}
+	defer func() {
+		for key, val := range origVals {
+			restoreEnv(key, val)
+		}
+	}()
+	os.Setenv("VAL_PRIMARY_PORT", "0")
+	os.Setenv("VAL_SECONDARY_PORT", "70000")
+	os.Setenv("VAL_LEVEL", "verbose")
+
+	_, err := NewConfig(&ValidatedCfg{}, WithMerge(false))
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("NewConfig() error = %v, want ValidationErrors", err)
+	}
+	if len(verrs) != 3 {
+		t.Errorf("len(ValidationErrors) = %d, want 3: %v", len(verrs), err)
+	}
+	for _, want := range []string{"port 0", "port 70000", `"verbose"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestValidateDefaultsPass(t *testing.T) {
+	if _, err := NewConfig(&ValidatedCfg{}, WithMerge(false)); err != nil {
+		t.Errorf("NewConfig() error = %v", err)
+	}
+}
+
+// OverridingCfg embeds a validator and defines its own Validate
+type OverridingCfg struct {
+	Config
+	PortRange
+	calls int
+}
+
+func (c *OverridingCfg) Validate() error {
+	c.calls++
+	return nil
+}
+
+func TestValidateEmbeddedOverride(t *testing.T) {
+	cfg := &OverridingCfg{PortRange: PortRange{Port: 0}}
+	// The outer Validate overrides the promoted one, so the invalid port
+	// is the outer config's responsibility
+	if err := Validate(cfg); err != nil {
+		t.Errorf("Validate() error = %v, want nil", err)
+	}
+	if cfg.calls != 1 {
+		t.Errorf("Validate() called %d times, want 1", cfg.calls)
+	}
+}
+
+// RangeCfg for min and max testing
+type RangeCfg struct {
+	Config
+	Port    int           `type:"int"      name:"range_port"    default:"8080" min:"1" max:"65535"`
+	Workers uint          `type:"uint"     name:"range_workers" default:"4"    min:"1"`
+	Ratio   float64       `type:"float64"  name:"range_ratio"   default:"0.5"  min:"0" max:"1"`
+	Timeout time.Duration `type:"duration" name:"range_timeout" default:"5s"   min:"1s" max:"1m"`
+	Retries *int          `type:"int"      name:"range_retries"                min:"1"`
+}
+
+func TestMinMax(t *testing.T) {
+	if _, err := ParseArgs(&RangeCfg{}, nil); err != nil {
+		t.Errorf("ParseArgs() with defaults error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "int above max",
+			args: []string{"--range_port=70000"},
+			want: "range_port: 70000 is above the maximum of 65535",
+		},
+		{
+			name: "int below min",
+			args: []string{"--range_port=0"},
+			want: "range_port: 0 is below the minimum of 1",
+		},
+		{
+			name: "uint below min",
+			args: []string{"--range_workers=0"},
+			want: "range_workers: 0 is below the minimum of 1",
+		},
+		{
+			name: "float above max",
+			args: []string{"--range_ratio=1.5"},
+			want: "range_ratio: 1.5 is above the maximum of 1",
+		},
+		{
+			name: "duration below min",
+			args: []string{"--range_timeout=10ms"},
+			want: "range_timeout: 10ms is below the minimum of 1s",
+		},
+		{
+			name: "pointer below min",
+			args: []string{"--range_retries=0"},
+			want: "range_retries: 0 is below the minimum of 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseArgs(&RangeCfg{}, tt.args)
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("ParseArgs() error = %v, want ValidationErrors", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseArgs() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// Values from the environment are checked the same way
+	t.Setenv("RANGE_TIMEOUT", "2m")
+	_, err := ParseArgs(&RangeCfg{}, nil)
+	want := "range_timeout: 2m0s is above the maximum of 1m"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("ParseArgs() error = %v, want %q", err, want)
+	}
+}
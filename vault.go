@@ -0,0 +1,139 @@
+package coil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+
+	"github.com/cvlstack/coil/remote"
+)
+
+// vaultRefresh is how often WithVaultRenewal checks the secrets when the
+// token does not expire
+var vaultRefresh = 5 * time.Minute
+
+// vaultSource reads the key-value secret at a Vault path
+type vaultSource struct {
+	addr  string
+	token string
+	path  string
+
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+// client returns a Vault client for the source's address and token
+func (v *vaultSource) client() (*vault.Client, error) {
+	cfg := vault.DefaultConfig()
+	cfg.Address = v.addr
+	client, err := vault.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(v.token)
+	return client, nil
+}
+
+// fetch reads the secret map, keyed by lower case name, giving up once ctx
+// is done. Both KV v1 paths and KV v2 paths such as secret/data/app are
+// supported
+func (v *vaultSource) fetch(ctx context.Context) (map[string]string, error) {
+	client, err := v.client()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.Logical().ReadWithContext(ctx, v.path)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("no secret at %s", v.path)
+	}
+	data := secret.Data
+	// KV v2 nests the values beneath data, next to the version metadata
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	secrets := make(map[string]string, len(data))
+	for key, val := range data {
+		secrets[strings.ToLower(key)] = fmt.Sprint(val)
+	}
+	return secrets, nil
+}
+
+// current returns the secrets last applied to the config
+func (v *vaultSource) current() map[string]string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.secrets
+}
+
+// applyVault overrides each key found in the Vault secret, matching the
+// secret's key names case-insensitively
+func applyVault(ctx context.Context, c Configer, v *vaultSource) error {
+	if v == nil {
+		return nil
+	}
+	secrets, err := v.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read vault secret: %w", err)
+	}
+	v.mu.Lock()
+	v.secrets = secrets
+	v.mu.Unlock()
+	return overrideKeys(c, func(key string) (string, error) {
+		val, ok := secrets[strings.ToLower(key)]
+		if !ok {
+			return "", remote.ErrNotFound
+		}
+		return val, nil
+	})
+}
+
+// renewVault renews the Vault token before it expires and reloads the
+// config whenever the secret changes, until ctx is done
+func renewVault(ctx context.Context, c Configer, v *vaultSource) {
+	client, err := v.client()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "coil: vault renewal: %v\n", err)
+		return
+	}
+	for {
+		wait, renewable := vaultRefresh, false
+		if self, err := client.Auth().Token().LookupSelf(); err == nil {
+			if ttl, _ := self.TokenTTL(); ttl > 0 {
+				wait = ttl * 2 / 3
+			}
+			renewable, _ = self.TokenIsRenewable()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if renewable {
+			if _, err := client.Auth().Token().RenewSelf(0); err != nil {
+				fmt.Fprintf(os.Stderr, "coil: vault renewal: %v\n", err)
+			}
+		}
+		secrets, err := v.fetch(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "coil: vault renewal: %v\n", err)
+			continue
+		}
+		if reflect.DeepEqual(secrets, v.current()) {
+			continue
+		}
+		if err := c.base().Reload(); err != nil {
+			fmt.Fprintf(os.Stderr, "coil: vault renewal: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package coil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// vaultServer serves data as the secret at every path, wrapped the way
+// Vault's KV v2 engine does
+func vaultServer(t *testing.T, data map[string]interface{}) *httptest.Server {
+	t.Helper()
+	return rotatingVaultServer(t, func() map[string]interface{} {
+		return data
+	})
+}
+
+// rotatingVaultServer is vaultServer with the secret read from data on
+// each request, so a test can rotate it
+func rotatingVaultServer(t *testing.T, data func() map[string]interface{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Vault-Token") != "s.test" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data":     data(),
+					"metadata": map[string]interface{}{"version": 1},
+				},
+			})
+		},
+	))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWithVaultSource(t *testing.T) {
+	srv := vaultServer(t, map[string]interface{}{
+		"DBPass": "hunter2",
+		"DBHOST": "vault.example.com",
+		"dbport": "6543",
+	})
+	c, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--dbport=7000"}),
+		WithVaultSource(srv.URL, "s.test", "secret/data/app"),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	cfg := c.(*RemoteCfg)
+	if cfg.DBPass != "hunter2" {
+		t.Errorf("DBPass = %q, want the vault value", cfg.DBPass)
+	}
+	if cfg.DBHost != "vault.example.com" {
+		t.Errorf("DBHost = %q, want the vault value", cfg.DBHost)
+	}
+	if cfg.DBPort != 7000 {
+		t.Errorf("DBPort = %d, want the flag value 7000", cfg.DBPort)
+	}
+}
+
+func TestWithVaultSourceError(t *testing.T) {
+	srv := vaultServer(t, nil)
+	_, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs(nil),
+		WithVaultSource(srv.URL, "s.wrong", "secret/data/app"),
+	)
+	if err == nil {
+		t.Error("NewConfig() with a rejected token should return an error")
+	}
+}
+
+func TestRenewVault(t *testing.T) {
+	old := vaultRefresh
+	vaultRefresh = 10 * time.Millisecond
+	t.Cleanup(func() { vaultRefresh = old })
+
+	var mu sync.Mutex
+	secret := map[string]interface{}{"dbpass": "hunter2"}
+	srv := rotatingVaultServer(t, func() map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return secret
+	})
+	c, err := NewConfig(
+		&RemoteCfg{},
+		WithMerge(false),
+		WithArgs(nil),
+		WithVaultSource(srv.URL, "s.test", "secret/data/app"),
+	)
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		renewVault(ctx, c, c.base().opts.vault)
+		close(done)
+	}()
+
+	mu.Lock()
+	secret = map[string]interface{}{"dbpass": "correct horse"}
+	mu.Unlock()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if got, _ := GetString(c, "dbpass"); got == "correct horse" {
+			break
+		}
+		if time.Now().After(deadline) {
+			got, _ := GetString(c, "dbpass")
+			t.Fatalf("dbpass = %q after rotation, want the new secret", got)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("renewVault did not return after ctx was cancelled")
+	}
+}
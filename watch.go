@@ -0,0 +1,87 @@
+package coil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchFile reloads the config whenever the config file at path is written
+// or replaced, until ctx is done. path becomes the file the config reads,
+// and holds the values already loaded until it next changes. A second
+// call stops the previous watcher. Subscribe reports the changed keys,
+// while reload errors are printed to stderr and the last values kept
+func (c *Config) WatchFile(ctx context.Context, path string) error {
+	if c.root == nil || c.viper == nil {
+		return errors.New("config has not been loaded")
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("could not watch config file: %w", err)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not watch config file: %w", err)
+	}
+	// Editors often replace the file rather than writing it, so the
+	// directory is watched for it to reappear
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("could not watch config file: %w", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.reloadMu.Lock()
+	c.viper.SetConfigFile(path)
+	c.reloadMu.Unlock()
+	c.mu.Lock()
+	if c.stopWatch != nil {
+		c.stopWatch()
+	}
+	c.stopWatch = cancel
+	c.mu.Unlock()
+	go c.watch(ctx, watcher, path)
+	return nil
+}
+
+// watch reloads the config on each event for path until ctx is done
+func (c *Config) watch(
+	ctx context.Context,
+	watcher *fsnotify.Watcher,
+	path string,
+) {
+	defer watcher.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != path ||
+				!event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+			if err := c.Reload(); err != nil {
+				fmt.Fprintf(
+					os.Stderr,
+					"coil: could not reload %s: %v\n",
+					path,
+					err,
+				)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "coil: watching %s: %v\n", path, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package coil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("reload_host: first.example.com\n")
+	cfg := MustNewConfig(
+		&ReloadCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--config=" + path}),
+	).(*ReloadCfg)
+
+	missing := filepath.Join(dir, "missing.yaml")
+	if err := cfg.WatchFile(context.Background(), missing); err == nil {
+		t.Error("WatchFile() of a missing file should return an error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := cfg.Subscribe(ctx)
+	// A second watcher replaces the first, which would otherwise reload
+	// the file twice per change
+	if err := cfg.WatchFile(ctx, path); err != nil {
+		t.Fatalf("WatchFile() error = %v", err)
+	}
+	if err := cfg.WatchFile(ctx, path); err != nil {
+		t.Fatalf("WatchFile() error = %v", err)
+	}
+
+	write("reload_host: second.example.com\n")
+	select {
+	case event := <-events:
+		if event.Key != "reload_host" || event.NewValue != "second.example.com" {
+			t.Errorf("event = %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the file changed")
+	}
+	cfg.RLock()
+	host := cfg.Host
+	cfg.RUnlock()
+	if host != "second.example.com" {
+		t.Errorf("Host = %q, want %q", host, "second.example.com")
+	}
+}
+
+func TestWatchFileStops(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("reload_port: 8080\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := MustNewConfig(
+		&ReloadCfg{},
+		WithMerge(false),
+		WithArgs([]string{"--config=" + path}),
+	).(*ReloadCfg)
+	var reloads int
+	cfg.AfterReload(func() { reloads++ })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := cfg.WatchFile(ctx, path); err != nil {
+		t.Fatalf("WatchFile() error = %v", err)
+	}
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("reload_port: 9090\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	cfg.RLock()
+	defer cfg.RUnlock()
+	if reloads != 0 || cfg.Port != 8080 {
+		t.Errorf("reloaded %d times after cancel, Port = %d", reloads, cfg.Port)
+	}
+}
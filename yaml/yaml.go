@@ -0,0 +1,30 @@
+// Package yaml populates a coil config from a YAML file alone, for services
+// whose command line belongs to a framework, such as Kubernetes operators,
+// and cannot carry coil flags
+package yaml
+
+import (
+	"fmt"
+	"os"
+
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/cvlstack/coil"
+)
+
+// Load populates c from the YAML file at path, whose top level keys are
+// flag names. No flags are registered or parsed and the environment is
+// not read; keys missing from the file take their defaults. Keys that are
+// not registered by the config return coil.ErrUnknownKey
+func Load(path string, c coil.Configer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read configuration file: %w", err)
+	}
+	values := map[string]interface{}{}
+	if err := yamlv3.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	_, err = coil.NewConfigFromMap(values, c)
+	return err
+}
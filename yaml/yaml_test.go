@@ -0,0 +1,79 @@
+package yaml
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cvlstack/coil"
+)
+
+// AppCfg for YAML loading
+type AppCfg struct {
+	coil.Config
+	coil.DatabaseConfig
+	Labels map[string]string `type:"map" name:"labels"`
+}
+
+func writeFile(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	origVal, ok := os.LookupEnv("DBNAME")
+	os.Setenv("DBNAME", "from-env")
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv("DBNAME", origVal)
+		} else {
+			os.Unsetenv("DBNAME")
+		}
+	})
+	path := writeFile(t, `
+dbhost: db.internal
+dbport: 6543
+dbconnlifetime: 10m
+labels:
+  team: orders
+`)
+	cfg := &AppCfg{}
+	if err := Load(path, cfg); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.DBHost != "db.internal" || cfg.DBPort != 6543 {
+		t.Errorf("DB = %s:%d, want db.internal:6543", cfg.DBHost, cfg.DBPort)
+	}
+	if cfg.ConnMaxLifetime != 10*time.Minute {
+		t.Errorf("ConnMaxLifetime = %v, want 10m", cfg.ConnMaxLifetime)
+	}
+	if cfg.Labels["team"] != "orders" {
+		t.Errorf("Labels = %v, want team=orders", cfg.Labels)
+	}
+	if cfg.DBDriver != "postgres" {
+		t.Errorf("DBDriver = %q, want the default", cfg.DBDriver)
+	}
+	if cfg.DBName != "" {
+		t.Errorf("DBName = %q, want the env to be ignored", cfg.DBName)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	err := Load(writeFile(t, "dbhsot: db.internal\n"), &AppCfg{})
+	if !errors.Is(err, coil.ErrUnknownKey) {
+		t.Errorf("Load() error = %v, want ErrUnknownKey", err)
+	}
+	if err := Load(writeFile(t, "dbhost: [\n"), &AppCfg{}); err == nil {
+		t.Error("Load() with invalid YAML should fail")
+	}
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	if err := Load(missing, &AppCfg{}); err == nil {
+		t.Error("Load() with a missing file should fail")
+	}
+}